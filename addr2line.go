@@ -0,0 +1,200 @@
+package main
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type addrFrame struct {
+	Function string
+	File     string
+	Line     uint64
+}
+
+/* innermost first chain of subprogram and inlined_subroutine entries covering addr */
+func (d *dwarfData) scopeChain(root *dwarfEntry, addr uint64) []*dwarfEntry {
+	var chain []*dwarfEntry
+	e := root
+	for e != nil {
+		var next *dwarfEntry
+		for _, c := range e.Children {
+			ranges := d.entryRanges(c)
+			if ranges == nil {
+				switch c.Tag {
+				case dwarf.TagNamespace, dwarf.TagModule, dwarf.TagClassType, dwarf.TagStructType, dwarf.TagLexDwarfBlock:
+					/* no pc range of their own, definitions may still nest inside */
+					if sub := d.scopeChain(c, addr); sub != nil {
+						return append(sub, chain...)
+					}
+				}
+				continue
+			}
+			if rangesContain(ranges, addr) {
+				next = c
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		if next.Tag == dwarf.TagSubprogram || next.Tag == dwarf.TagInlinedSubroutine {
+			chain = append([]*dwarfEntry{next}, chain...)
+		}
+		e = next
+	}
+	return chain
+}
+
+func (d *dwarfData) unitForAddr(addr uint64) *dwarfUnit {
+	for _, u := range d.Units {
+		if u.Root != nil && rangesContain(d.entryRanges(u.Root), addr) {
+			return u
+		}
+	}
+
+	/* some producers omit unit ranges, fall back to the line tables */
+	for _, u := range d.Units {
+		if lt := d.unitLineTable(u); lt != nil && lt.lookup(addr) != nil {
+			return u
+		}
+	}
+	return nil
+}
+
+/* symbolizes addr into frames, innermost inlined function first */
+func (d *dwarfData) addrFrames(addr uint64) []addrFrame {
+	u := d.unitForAddr(addr)
+	if u == nil {
+		return nil
+	}
+
+	compDir := d.stringVal(u.Root, dwarf.AttrCompDir)
+	lt := d.unitLineTable(u)
+
+	var frames []addrFrame
+	inner := addrFrame{File: "??"}
+	if lt != nil {
+		if r := lt.lookup(addr); r != nil {
+			inner.File = lt.filePath(r.File, compDir)
+			inner.Line = r.Line
+		}
+	}
+
+	chain := d.scopeChain(u.Root, addr)
+	if len(chain) == 0 {
+		inner.Function = "??"
+		return []addrFrame{inner}
+	}
+
+	loc := inner
+	for i, e := range chain {
+		loc.Function = d.funcName(e)
+		if loc.Function == "" {
+			loc.Function = "??"
+		}
+		frames = append(frames, loc)
+
+		if i+1 < len(chain) && e.Tag == dwarf.TagInlinedSubroutine {
+			/* the caller's location is the call site recorded on the inlined instance */
+			loc = addrFrame{File: "??"}
+			if f, ok := e.uintVal(dwarf.AttrCallFile); ok && lt != nil {
+				loc.File = lt.filePath(f, compDir)
+			}
+			loc.Line, _ = e.uintVal(dwarf.AttrCallLine)
+		}
+	}
+	return frames
+}
+
+/* closest preceding symbol, used for addresses the DWARF does not cover */
+func symbolForAddr(elfFs *elfFile, addr uint64) (string, uint64, bool) {
+	var best string
+	var bestVal uint64
+	found := false
+
+	check := func(name string, value, size uint64, info uint8, shndx uint16) {
+		t := elf.ST_TYPE(info)
+		if t != elf.STT_FUNC && t != elf.STT_OBJECT && t != elf.STT_NOTYPE || name == "" {
+			return
+		}
+		if elf.SectionIndex(shndx) == elf.SHN_UNDEF || addr < value || addr >= value+size && addr != value {
+			return
+		}
+		if !found || value > bestVal {
+			best, bestVal, found = name, value, true
+		}
+	}
+
+	for _, s := range elfFs.Symbols {
+		switch sym := s.(type) {
+		case *elf.Sym32:
+			check(elfFs.SymbolsName[sym.Name], uint64(sym.Value), uint64(sym.Size), sym.Info, sym.Shndx)
+		case *elf.Sym64:
+			check(elfFs.SymbolsName[sym.Name], sym.Value, sym.Size, sym.Info, sym.Shndx)
+		}
+	}
+	for _, s := range elfFs.DynSymbols {
+		switch sym := s.(type) {
+		case *elf.Sym32:
+			check(elfFs.DynSymbolsName[sym.Name], uint64(sym.Value), uint64(sym.Size), sym.Info, sym.Shndx)
+		case *elf.Sym64:
+			check(elfFs.DynSymbolsName[sym.Name], sym.Value, sym.Size, sym.Info, sym.Shndx)
+		}
+	}
+	return best, addr - bestVal, found
+}
+
+func (elfFs *elfFile) loadAllSymbols() {
+	elfFs.loadDynSymbols()
+	elfFs.loadSymtab()
+}
+
+func printAddr2line(elfFs *elfFile, addrs []string) {
+	d, err := loadDwarf(elfFs)
+	checkError(err)
+	elfFs.loadAllSymbols()
+
+	width := 16
+	if elfFs.FileHdr.Arch == elf.ELFCLASS32 {
+		width = 8
+	}
+
+	missing := 0
+	for _, a := range addrs {
+		addr, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(a), "0x"), 16, 64)
+		if err != nil {
+			fmt.Printf("Invalid address %s\n", a)
+			missing++
+			continue
+		}
+
+		var frames []addrFrame
+		if d != nil {
+			frames = d.addrFrames(addr)
+		}
+		if frames == nil {
+			if name, _, ok := symbolForAddr(elfFs, addr); ok {
+				frames = []addrFrame{{Function: name, File: "??"}}
+			}
+		}
+		if frames == nil {
+			frames = []addrFrame{{Function: "??", File: "??"}}
+			missing++
+		}
+
+		for i, fr := range frames {
+			prefix := fmt.Sprintf("0x%0*x: ", width, addr)
+			if i > 0 {
+				prefix = " (inlined by) "
+			}
+			fmt.Printf("%s%s at %s:%d\n", prefix, displayName(fr.Function), fr.File, fr.Line)
+		}
+	}
+	/* ?? is still printed for each, as addr2line does, but the target fails like --struct does */
+	if missing > 0 {
+		checkError(fmt.Errorf("%d of %d addresses not resolved", missing, len(addrs)))
+	}
+}
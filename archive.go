@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	arMagic     = "!<arch>\n"
+	arThinMagic = "!<thin>\n"
+	arHdrSize   = 60
+)
+
+type arMember struct {
+	Name    string
+	HdrOff  int64 // offset of the member header, what the symbol index points at
+	DataOff int64
+	Size    int64
+}
+
+type arSymbol struct {
+	Name   string
+	HdrOff uint64
+}
+
+type arArchive struct {
+	Path      string
+	Thin      bool
+	Members   []arMember
+	Symbols   []arSymbol
+	NamesSize int // bytes of symbol names in the index
+	Index64   bool
+}
+
+/* archive members are parsed in place through a section of the archive file */
+type sectionElf struct {
+	*io.SectionReader
+}
+
+func (sectionElf) Close() error { return nil }
+
+func isArchive(magic []byte) bool {
+	return bytes.HasPrefix(magic, []byte(arMagic)) || bytes.HasPrefix(magic, []byte(arThinMagic))
+}
+
+/* parses the member headers, the long name table and the symbol index of a System V/GNU archive */
+func parseArchive(fh io.ReaderAt, size int64, path string) (*arArchive, error) {
+	magic := make([]byte, len(arMagic))
+	if _, err := fh.ReadAt(magic, 0); err != nil {
+		return nil, err
+	}
+	ar := &arArchive{Path: path, Thin: string(magic) == arThinMagic}
+
+	var longNames []byte
+	var index []byte
+	off := int64(len(arMagic))
+	for off+arHdrSize <= size {
+		hdr := make([]byte, arHdrSize)
+		if _, err := fh.ReadAt(hdr, off); err != nil {
+			return nil, err
+		}
+		if string(hdr[58:60]) != "`\n" {
+			return nil, fmt.Errorf("bad member header at offset 0x%x", off)
+		}
+		msize, err := strconv.ParseInt(strings.TrimSpace(string(hdr[48:58])), 10, 64)
+		if err != nil || msize < 0 {
+			return nil, fmt.Errorf("bad member size at offset 0x%x", off)
+		}
+		name := strings.TrimRight(string(hdr[:16]), " ")
+		m := arMember{HdrOff: off, DataOff: off + arHdrSize, Size: msize}
+
+		/* thin archives only store the index and long name table, members stay in their own files */
+		stored := msize
+		special := name == "/" || name == "/SYM64/" || name == "//"
+		if ar.Thin && !special {
+			stored = 0
+		}
+		if m.DataOff+stored > size {
+			return nil, fmt.Errorf("member at offset 0x%x runs past the end of the archive", off)
+		}
+		readData := func() ([]byte, error) {
+			data := make([]byte, stored)
+			_, err := fh.ReadAt(data, m.DataOff)
+			return data, err
+		}
+
+		switch {
+		case name == "/" || name == "/SYM64/":
+			if index, err = readData(); err != nil {
+				return nil, err
+			}
+			ar.Index64 = name == "/SYM64/"
+		case name == "//":
+			if longNames, err = readData(); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(name, "/") && len(name) > 1:
+			n, err := strconv.Atoi(name[1:])
+			if err != nil || n >= len(longNames) {
+				return nil, fmt.Errorf("bad long name reference %s", name)
+			}
+			end := bytes.Index(longNames[n:], []byte("/\n"))
+			if end < 0 {
+				end = len(longNames) - n
+			}
+			m.Name = string(longNames[n : n+end])
+		case strings.HasPrefix(name, "#1/"):
+			/* BSD style, the name is stored in front of the member data */
+			n, err := strconv.Atoi(name[3:])
+			if err != nil || int64(n) > msize {
+				return nil, fmt.Errorf("bad BSD name %s", name)
+			}
+			buf := make([]byte, n)
+			if _, err := fh.ReadAt(buf, m.DataOff); err != nil {
+				return nil, err
+			}
+			m.Name = string(bytes.TrimRight(buf, "\x00"))
+			m.DataOff += int64(n)
+			m.Size -= int64(n)
+		default:
+			m.Name = strings.TrimSuffix(name, "/")
+		}
+		if !special {
+			ar.Members = append(ar.Members, m)
+		}
+
+		off = m.HdrOff + arHdrSize + stored
+		off += off & 1
+	}
+
+	if index != nil {
+		ar.Symbols, ar.NamesSize = parseArIndex(index, ar.Index64)
+	}
+	return ar, nil
+}
+
+/* big endian count, member offsets, then the NUL terminated names in the same order */
+func parseArIndex(data []byte, is64 bool) ([]arSymbol, int) {
+	w := 4
+	if is64 {
+		w = 8
+	}
+	word := func(i int) uint64 {
+		if is64 {
+			return binary.BigEndian.Uint64(data[i:])
+		}
+		return uint64(binary.BigEndian.Uint32(data[i:]))
+	}
+	if len(data) < w {
+		return nil, 0
+	}
+	count := word(0)
+	if count >= uint64(len(data)/w) {
+		return nil, 0
+	}
+
+	var syms []arSymbol
+	names := data[w+int(count)*w:]
+	for i := 0; i < int(count); i++ {
+		end := bytes.IndexByte(names, 0)
+		if end < 0 {
+			break
+		}
+		syms = append(syms, arSymbol{Name: string(names[:end]), HdrOff: word(w + i*w)})
+		names = names[end+1:]
+	}
+	return syms, len(data) - w - int(count)*w
+}
+
+func (ar *arArchive) memberAt(hdrOff uint64) *arMember {
+	for i := range ar.Members {
+		if uint64(ar.Members[i].HdrOff) == hdrOff {
+			return &ar.Members[i]
+		}
+	}
+	return nil
+}
+
+/* lib.a(member.o), or lib.a[member.o] for the external members of a thin archive as binutils prints them */
+func (ar *arArchive) memberName(m *arMember) string {
+	if ar.Thin {
+		return fmt.Sprintf("%s[%s]", ar.Path, m.Name)
+	}
+	return fmt.Sprintf("%s(%s)", ar.Path, m.Name)
+}
+
+/* opens a member as an ELF file, thin archive members are opened relative to the archive */
+func (ar *arArchive) openMember(m arMember, fh io.ReaderAt) (*elfFile, error) {
+	name := ar.memberName(&m)
+	if ar.Thin {
+		path := m.Name
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(ar.Path), path)
+		}
+		mf, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		/* the caller closes the member once processed, any failure before that, a panic on a truncated header included, closes it here */
+		handed := false
+		defer func() {
+			if !handed {
+				mf.Close()
+			}
+		}()
+		elfFs, err := openElfReader(mf, name)
+		if err != nil {
+			return nil, errors.New("not an ELF object")
+		}
+		handed = true
+		return elfFs, nil
+	}
+
+	elfFs, err := openElfReader(sectionElf{io.NewSectionReader(fh, m.DataOff, m.Size)}, name)
+	if err != nil {
+		return nil, errors.New("not an ELF object")
+	}
+	return elfFs, nil
+}
+
+func printArchiveIndex(ar *arArchive) {
+	if ar.Symbols == nil {
+		fmt.Printf("%s has no archive index\n", ar.Path)
+		return
+	}
+	fmt.Printf("Index of archive %s: (%d entries, 0x%x bytes in the symbol table)\n", ar.Path, len(ar.Symbols), ar.NamesSize)
+
+	var cur uint64
+	for i, s := range ar.Symbols {
+		if i == 0 || s.HdrOff != cur {
+			cur = s.HdrOff
+			if m := ar.memberAt(cur); m != nil {
+				fmt.Printf("Contents of binary %s at offset 0x%x\n", ar.memberName(m), cur)
+			} else {
+				fmt.Printf("Index entries point at offset 0x%x, which is not a member header\n", cur)
+			}
+		}
+		fmt.Printf("\t%s\n", s.Name)
+	}
+}
+
+/* runs the selected views over every member of an archive, reporting whether all of them succeeded */
+func processArchive(fh *os.File, path string, opts displayOptions) (ok bool) {
+	ok = true
+	st, err := fh.Stat()
+	checkError(err)
+	ar, err := parseArchive(fh, st.Size(), path)
+	checkError(err)
+
+	if opts.ArchiveIndex {
+		printArchiveIndex(ar)
+	}
+	if !opts.memberViews() {
+		return ok
+	}
+
+	for _, m := range ar.Members {
+		name := ar.memberName(&m)
+		if opts.Long == "--nm" {
+			/* nm heads each member with its bare name */
+			fmt.Printf("\n%s:\n", m.Name)
+		} else {
+			fmt.Printf("\nFile: %s\n", name)
+		}
+		member, err := ar.openMember(m, fh)
+		if err != nil {
+			fmt.Printf("Skipping %s: %v\n", m.Name, err)
+			continue
+		}
+		if !runGuarded(name, func() { processElf(member, opts) }) {
+			ok = false
+		}
+		member.Fh.Close()
+	}
+	return ok
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"debug/elf"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sad0p/go-readelf/symlookup"
+)
+
+/* a definition other objects may bind to, as the objects defining the same name are compared */
+type exportedDef struct {
+	Obj     *ldObject
+	Version string
+}
+
+/* the dynamic symbols of a loaded object by name, so lookups in it do not go back to the file */
+type ldSymbols struct {
+	tab    *symlookup.Table
+	byName map[string][]uint32
+}
+
+func (t *ldTree) symbols(o *ldObject) *ldSymbols {
+	if s, ok := t.syms[o]; ok {
+		return s
+	}
+	tab, err := loadLookupTable(o.File)
+	if err == symlookup.ErrNoDynsym {
+		tab = &symlookup.Table{}
+	} else {
+		checkError(err)
+	}
+	s := &ldSymbols{tab: tab, byName: map[string][]uint32{}}
+	for i := 1; i < len(tab.Symbols); i++ {
+		s.byName[tab.Symbols[i].Name] = append(s.byName[tab.Symbols[i].Name], uint32(i))
+	}
+	if t.syms == nil {
+		t.syms = map[*ldObject]*ldSymbols{}
+	}
+	t.syms[o] = s
+	return s
+}
+
+// lookupIn finds name in one object with the matching rules of --lookup,
+// through an index by name rather than the hash tables, which lead to the
+// same definitions in any file the linker wrote.
+func (t *ldTree) lookupIn(o *ldObject, name, version string) (symlookup.Match, bool) {
+	s := t.symbols(o)
+	var fb symlookup.Fallback
+	for _, i := range s.byName[name] {
+		if s.tab.CheckMatch(i, name, version, &fb) {
+			return s.tab.MatchAt(i, ".dynsym"), true
+		}
+	}
+	if i, ok := fb.Index(); ok {
+		return s.tab.MatchAt(i, ".dynsym"), true
+	}
+	return symlookup.Match{}, false
+}
+
+// resolve looks name up in the global scope the way ld.so does for a
+// reference from obj: the loaded objects in load order, obj itself first
+// when it was linked with -Bsymbolic. A protected definition in obj wins
+// over whatever the scope found, references from inside the defining
+// object cannot be interposed.
+func (t *ldTree) resolve(name, version string, obj *ldObject) (*ldObject, symlookup.Match, bool) {
+	if obj != nil && obj.Symbolic {
+		if m, ok := t.lookupIn(obj, name, version); ok {
+			return obj, m, true
+		}
+	}
+	for _, o := range t.Order {
+		if o.File == nil {
+			continue
+		}
+		m, ok := t.lookupIn(o, name, version)
+		if !ok {
+			continue
+		}
+		if obj != nil && o != obj {
+			if own, ok := t.lookupIn(obj, name, version); ok && elf.ST_VISIBILITY(own.Symbol.Other) == elf.STV_PROTECTED {
+				return obj, own, true
+			}
+		}
+		return o, m, true
+	}
+	return nil, symlookup.Match{}, false
+}
+
+/* the undefined dynamic symbols of obj, with the version each one asks for */
+func undefinedRefs(obj *ldObject, fn func(s elfSymbol, version string)) {
+	elfFs := obj.File
+	elfFs.loadDynSymbols()
+	vers := loadSymVersions(elfFs)
+	for i := uint32(1); i < uint32(len(elfFs.DynSymbols)); i++ {
+		s, ok := getSymbol(i, dynSym, elfFs)
+		if !ok || s.Name == "" || elf.SectionIndex(s.Shndx) != elf.SHN_UNDEF || elf.ST_BIND(s.Info) == elf.STB_LOCAL {
+			continue
+		}
+		version := ""
+		if vs, found := vers.symVersion(i); found && vs&^versymHidden > versymGlobal {
+			version = vers.Names[vs&^versymHidden]
+		}
+		fn(s, version)
+	}
+}
+
+/* the default definitions each object exports, by name, objects in load order */
+func (t *ldTree) exportedDefs() map[string][]exportedDef {
+	defs := map[string][]exportedDef{}
+	for _, o := range t.Order {
+		if o.File == nil {
+			continue
+		}
+		elfFs := o.File
+		elfFs.loadDynSymbols()
+		vers := loadSymVersions(elfFs)
+		for i := uint32(1); i < uint32(len(elfFs.DynSymbols)); i++ {
+			s, ok := getSymbol(i, dynSym, elfFs)
+			if !ok || s.Name == "" || elf.SectionIndex(s.Shndx) == elf.SHN_UNDEF || !symlookup.Bindable(elf.ST_TYPE(s.Info)) ||
+				elf.ST_BIND(s.Info) == elf.STB_LOCAL || s.Value == 0 && elf.ST_TYPE(s.Info) != elf.STT_TLS {
+				continue
+			}
+			d := exportedDef{Obj: o}
+			if vs, found := vers.symVersion(i); found {
+				if vs&versymHidden != 0 {
+					continue
+				}
+				if vs > versymGlobal {
+					d.Version = vers.Names[vs]
+				}
+			}
+			if list := defs[s.Name]; len(list) == 0 || list[len(list)-1].Obj != o {
+				defs[s.Name] = append(list, d)
+			}
+		}
+	}
+	return defs
+}
+
+/* the names the target takes over from its libraries through copy relocations */
+func copyRelocated(elfFs *elfFile) map[string]bool {
+	copies := map[string]bool{}
+	elfFs.getRelocations()
+	for k, v := range elfFs.Rels {
+		if getSectionHeader(getSectionHeader(k, elfFs).Link, elfFs).Type != elf.SHT_DYNSYM {
+			continue
+		}
+		for _, e := range relocEntries(v, elfFs.FileHdr.Machine) {
+			if s, ok := getSymbol(e.Sym, dynSym, elfFs); ok && relocKind(e.Type, elfFs.FileHdr.Machine) == relocCopy {
+				copies[s.Name] = true
+			}
+		}
+	}
+	return copies
+}
+
+// printBindings is --bindings: the libraries found as --ldd finds them, then
+// the object each undefined dynamic symbol of the target binds to, looked
+// up in load order with the symbol's version, every undefined reference of
+// any loaded object that nothing satisfies and the names several objects
+// define. For those the references of each defining object are followed:
+// the ones bound elsewhere are interposed, while -Bsymbolic, protected
+// visibility or a version only one of them has keep others to their own.
+func printBindings(elfFs *elfFile, sysroot string) {
+	if loadDynamic(elfFs) == nil {
+		fmt.Println("not a dynamic executable")
+		return
+	}
+	t := loadDeps(elfFs, sysroot)
+	name := func(o *ldObject) string {
+		if o == nil {
+			return "not found"
+		}
+		return t.showPath(o.Path)
+	}
+
+	fmt.Printf("Bindings of %s:\n", elfFs.Path)
+	fmt.Printf("%-40s %-16s %s\n", "Symbol", "Version", "Bound to")
+	undefinedRefs(t.Root, func(s elfSymbol, version string) {
+		o, _, ok := t.resolve(s.Name, version, t.Root)
+		where := name(o)
+		if !ok && elf.ST_BIND(s.Info) == elf.STB_WEAK {
+			where = "unresolved weak, stays 0"
+		}
+		fmt.Printf("%-40s %-16s %s\n", displayName(s.Name), yesNo(version != "", version, "-"), where)
+	})
+
+	var unresolved []string
+	for _, o := range t.Order {
+		if o.File == nil {
+			continue
+		}
+		undefinedRefs(o, func(s elfSymbol, version string) {
+			if _, _, ok := t.resolve(s.Name, version, o); !ok && elf.ST_BIND(s.Info) != elf.STB_WEAK {
+				ref := displayName(s.Name)
+				if version != "" {
+					ref += "@" + version
+				}
+				unresolved = append(unresolved, fmt.Sprintf("%s: %s", name(o), ref))
+			}
+		})
+	}
+	if t.Missing > 0 {
+		fmt.Printf("\n%d needed libraries were not found, the symbols they define are missing below\n", t.Missing)
+	}
+	if len(unresolved) == 0 {
+		fmt.Println("\nNo unresolved symbols")
+	} else {
+		fmt.Println("\nUnresolved symbols:")
+		for _, u := range unresolved {
+			fmt.Printf("    %s\n", u)
+		}
+	}
+
+	copies := copyRelocated(elfFs)
+	defs := t.exportedDefs()
+	var names []string
+	for n, list := range defs {
+		if len(list) > 1 {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+
+	shown := 0
+	for _, n := range names {
+		var rows []string
+		interposed := false
+		for i, d := range defs[n] {
+			o, m, ok := t.resolve(n, d.Version, d.Obj)
+			var status string
+			switch {
+			case !ok:
+				continue
+			case o != d.Obj:
+				status = "interposed by " + name(o)
+				if o == t.Root && copies[n] {
+					status += " (copy relocation)"
+				}
+				interposed = true
+			case i == 0:
+				status = "used"
+			case elf.ST_VISIBILITY(m.Symbol.Other) == elf.STV_PROTECTED:
+				status = "kept, protected"
+				interposed = true
+			case d.Obj.Symbolic:
+				status = "kept, DT_SYMBOLIC"
+				interposed = true
+			default:
+				status = "kept, version " + yesNo(d.Version != "", d.Version, "-")
+			}
+			rows = append(rows, fmt.Sprintf("    %-48s %-16s %s", name(d.Obj), yesNo(d.Version != "", d.Version, "-"), status))
+		}
+		if !interposed {
+			continue
+		}
+		if shown == 0 {
+			fmt.Println("\nSymbols defined by more than one object:")
+		}
+		shown++
+		fmt.Println(displayName(n))
+		fmt.Println(strings.Join(rows, "\n"))
+	}
+	if shown == 0 {
+		fmt.Println("\nNo interposed symbols")
+	}
+}
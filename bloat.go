@@ -0,0 +1,479 @@
+package main
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+/* data sources --bloat-by accepts, the first is the default */
+var bloatSources = []string{"sections", "segments", "symbols", "compileunits", "files"}
+
+const bloatUnattributed = "[Unattributed]"
+
+type bloatRange struct {
+	Lo, Hi uint64
+	Label  string
+}
+
+// rangeMap hands out byte ranges to labels. Layers are added from the most to
+// the least precise: a range only claims the bytes no earlier layer took, and
+// within a layer the range starting first wins overlaps.
+type rangeMap struct {
+	covered []bloatRange // sorted and disjoint
+}
+
+func (m *rangeMap) addLayer(layer []bloatRange) {
+	sort.SliceStable(layer, func(a, b int) bool { return layer[a].Lo < layer[b].Lo })
+
+	var add []bloatRange
+	var end uint64
+	j := 0
+	for _, r := range layer {
+		r.Lo = max(r.Lo, end)
+		if r.Lo >= r.Hi {
+			continue
+		}
+		end = r.Hi
+
+		for j < len(m.covered) && m.covered[j].Hi <= r.Lo {
+			j++
+		}
+		lo := r.Lo
+		for k := j; lo < r.Hi; k++ {
+			if k == len(m.covered) || m.covered[k].Lo >= r.Hi {
+				add = append(add, bloatRange{lo, r.Hi, r.Label})
+				break
+			}
+			if m.covered[k].Lo > lo {
+				add = append(add, bloatRange{lo, m.covered[k].Lo, r.Label})
+			}
+			lo = max(lo, m.covered[k].Hi)
+		}
+	}
+
+	merged := make([]bloatRange, 0, len(m.covered)+len(add))
+	i, k := 0, 0
+	for i < len(m.covered) || k < len(add) {
+		if k == len(add) || i < len(m.covered) && m.covered[i].Lo < add[k].Lo {
+			merged = append(merged, m.covered[i])
+			i++
+		} else {
+			merged = append(merged, add[k])
+			k++
+		}
+	}
+	m.covered = merged
+}
+
+/* bytes per label, ranges outside the domain carry no label and are not counted */
+func (m *rangeMap) tally() map[string]uint64 {
+	sizes := map[string]uint64{}
+	for _, r := range m.covered {
+		if r.Label != "" {
+			sizes[r.Label] += r.Hi - r.Lo
+		}
+	}
+	return sizes
+}
+
+/* the file and the mapped image of one binary, each as layers of labelled ranges */
+type bloatMaps struct {
+	File, VM   rangeMap
+	FileSize   uint64
+	VMSize     uint64
+	loads      []progHeader
+	fileDomain []bloatRange
+	vmDomain   []bloatRange
+}
+
+/* everything outside domain is claimed first under no label so that later layers are clipped to it */
+func outside(domain []bloatRange) []bloatRange {
+	var out []bloatRange
+	var lo uint64
+	for _, d := range domain {
+		if d.Lo > lo {
+			out = append(out, bloatRange{lo, d.Lo, ""})
+		}
+		lo = max(lo, d.Hi)
+	}
+	return append(out, bloatRange{lo, math.MaxUint64, ""})
+}
+
+/* union of the ranges, sorted */
+func unionRanges(rs []bloatRange) []bloatRange {
+	sort.Slice(rs, func(a, b int) bool { return rs[a].Lo < rs[b].Lo })
+	var out []bloatRange
+	for _, r := range rs {
+		if r.Lo >= r.Hi {
+			continue
+		}
+		if n := len(out); n > 0 && r.Lo <= out[n-1].Hi {
+			out[n-1].Hi = max(out[n-1].Hi, r.Hi)
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+func newBloatMaps(elfFs *elfFile) *bloatMaps {
+	m := &bloatMaps{}
+	end, err := elfFs.Fh.Seek(0, io.SeekEnd)
+	checkError(err)
+	m.FileSize = uint64(end)
+	m.fileDomain = []bloatRange{{0, m.FileSize, ""}}
+
+	elfFs.getProgHeaders()
+	var vm []bloatRange
+	for i := 0; i < numProgHeaders(elfFs); i++ {
+		if ph := getProgHeader(i, elfFs); ph.Type == elf.PT_LOAD {
+			m.loads = append(m.loads, ph)
+			vm = append(vm, bloatRange{ph.Vaddr, ph.Vaddr + ph.Memsz, ""})
+		}
+	}
+	m.vmDomain = unionRanges(vm)
+	for _, r := range m.vmDomain {
+		m.VMSize += r.Hi - r.Lo
+	}
+
+	m.File.addLayer(outside(m.fileDomain))
+	m.VM.addLayer(outside(m.vmDomain))
+	return m
+}
+
+/* the mapped addresses the file bytes [lo, hi) are loaded at */
+func (m *bloatMaps) fileToVM(lo, hi uint64, label string) []bloatRange {
+	var out []bloatRange
+	for _, ph := range m.loads {
+		l, h := max(lo, ph.Off), min(hi, ph.Off+ph.Filesz)
+		if l < h {
+			out = append(out, bloatRange{ph.Vaddr + l - ph.Off, ph.Vaddr + h - ph.Off, label})
+		}
+	}
+	return out
+}
+
+/* the file bytes backing the addresses [lo, hi), bss has none */
+func (m *bloatMaps) vmToFile(lo, hi uint64, label string) []bloatRange {
+	var out []bloatRange
+	for _, ph := range m.loads {
+		l, h := max(lo, ph.Vaddr), min(hi, ph.Vaddr+ph.Filesz)
+		if l < h {
+			out = append(out, bloatRange{ph.Off + l - ph.Vaddr, ph.Off + h - ph.Vaddr, label})
+		}
+	}
+	return out
+}
+
+/* adds ranges known by address, with the file bytes behind them */
+func (m *bloatMaps) addVMLayer(vm []bloatRange) {
+	var file []bloatRange
+	for _, r := range vm {
+		file = append(file, m.vmToFile(r.Lo, r.Hi, r.Label)...)
+	}
+	m.File.addLayer(file)
+	m.VM.addLayer(vm)
+}
+
+func (m *bloatMaps) headerLayer(elfFs *elfFile) {
+	var ehsize, phoff, phsize, shoff, shsize uint64
+	switch h := elfFs.Hdr.(type) {
+	case *elf.Header64:
+		ehsize, phoff, phsize = uint64(h.Ehsize), h.Phoff, uint64(h.Phentsize)*uint64(h.Phnum)
+		shoff, shsize = h.Shoff, uint64(h.Shentsize)*uint64(len(elfFs.ElfSections.SectionName))
+	case *elf.Header32:
+		ehsize, phoff, phsize = uint64(h.Ehsize), uint64(h.Phoff), uint64(h.Phentsize)*uint64(h.Phnum)
+		shoff, shsize = uint64(h.Shoff), uint64(h.Shentsize)*uint64(len(elfFs.ElfSections.SectionName))
+	}
+	file := []bloatRange{{0, ehsize, "[ELF Header]"}, {phoff, phoff + phsize, "[Program Headers]"},
+		{shoff, shoff + shsize, "[Section Headers]"}}
+	var vm []bloatRange
+	for _, r := range file {
+		vm = append(vm, m.fileToVM(r.Lo, r.Hi, r.Label)...)
+	}
+	m.File.addLayer(file)
+	m.VM.addLayer(vm)
+}
+
+/* sections under their own name, or as "[section .name]" when they only fill in around a finer source */
+func (m *bloatMaps) sectionLayer(elfFs *elfFile, fallback bool) {
+	var file, vm []bloatRange
+	for i := uint32(1); i < uint32(len(elfFs.ElfSections.SectionName)); i++ {
+		sh := getSectionHeader(i, elfFs)
+		label := sh.Name
+		if fallback {
+			label = "[section " + sh.Name + "]"
+		}
+		if sh.Type != elf.SHT_NOBITS {
+			file = append(file, bloatRange{sh.Off, sh.Off + sh.Size, label})
+		}
+		/* .tbss takes no room of its own in the image */
+		if sh.Flags&elf.SHF_ALLOC != 0 && !(sh.Type == elf.SHT_NOBITS && sh.Flags&elf.SHF_TLS != 0) && len(m.loads) > 0 {
+			vm = append(vm, bloatRange{sh.Addr, sh.Addr + sh.Size, label})
+		}
+	}
+	m.File.addLayer(file)
+	m.VM.addLayer(vm)
+}
+
+func (m *bloatMaps) segmentLayer() {
+	var file, vm []bloatRange
+	for i, ph := range m.loads {
+		label := fmt.Sprintf("LOAD #%d [%s]", i, strings.TrimSpace(progFlagsKey(ph.Flags)))
+		file = append(file, bloatRange{ph.Off, ph.Off + ph.Filesz, label})
+		vm = append(vm, bloatRange{ph.Vaddr, ph.Vaddr + ph.Memsz, label})
+	}
+	m.File.addLayer(file)
+	m.VM.addLayer(vm)
+}
+
+// symbolLayer attributes the [value, value+size) range of every sized
+// function and data symbol of .symtab, or of .dynsym when there is no symbol
+// table anywhere. In object files values are offsets into their section.
+func (m *bloatMaps) symbolLayer(elfFs *elfFile) {
+	elfFs.loadAllSymbols()
+	symType, n := sym, len(elfFs.Symbols)
+	if n == 0 {
+		symType, n = dynSym, len(elfFs.DynSymbols)
+	}
+	reloc := elfHeaderType(elfFs) == elf.ET_REL
+
+	var file, vm []bloatRange
+	for i := uint32(1); i < uint32(n); i++ {
+		s, ok := getSymbol(i, symType, elfFs)
+		if !ok || s.Size == 0 || s.Name == "" || elf.SectionIndex(s.Shndx) == elf.SHN_UNDEF || s.Shndx >= uint16(elf.SHN_LORESERVE) {
+			continue
+		}
+		switch elf.ST_TYPE(s.Info) {
+		case elf.STT_FUNC, elf.STT_OBJECT, elf.STT_NOTYPE, sttGNUIFunc:
+		default:
+			continue
+		}
+		label := displayName(s.Name)
+		if !reloc {
+			vm = append(vm, bloatRange{s.Value, s.Value + s.Size, label})
+			continue
+		}
+		sh := getSectionHeader(uint32(s.Shndx), elfFs)
+		if sh.Type != elf.SHT_NOBITS && s.Value < sh.Size {
+			file = append(file, bloatRange{sh.Off + s.Value, sh.Off + min(s.Value+s.Size, sh.Size), label})
+		}
+	}
+	if reloc {
+		m.File.addLayer(file)
+		return
+	}
+	m.addVMLayer(vm)
+}
+
+func elfHeaderType(elfFs *elfFile) elf.Type {
+	switch h := elfFs.Hdr.(type) {
+	case *elf.Header64:
+		return elf.Type(h.Type)
+	case *elf.Header32:
+		return elf.Type(h.Type)
+	}
+	return elf.ET_NONE
+}
+
+// dwarfLayer attributes code to compile units, or with files set to the
+// source file of each line table row. Units without ranges of their own are
+// covered through their line table.
+func (m *bloatMaps) dwarfLayer(d *dwarfData, files bool) {
+	var vm []bloatRange
+	for _, u := range d.Units {
+		if u.Root == nil {
+			continue
+		}
+		name := d.stringVal(u.Root, dwarf.AttrName)
+		lt := d.unitLineTable(u)
+		if !files {
+			if ranges := d.entryRanges(u.Root); len(ranges) > 0 {
+				for _, r := range ranges {
+					vm = append(vm, bloatRange{r.Low, r.High, name})
+				}
+				continue
+			}
+		}
+		if lt == nil {
+			continue
+		}
+		compDir := d.stringVal(u.Root, dwarf.AttrCompDir)
+		for i := 0; i+1 < len(lt.Rows); i++ {
+			r := lt.Rows[i]
+			if r.EndSequence || lt.Rows[i+1].Address <= r.Address {
+				continue
+			}
+			label := name
+			if files {
+				label = lt.filePath(r.File, compDir)
+			}
+			vm = append(vm, bloatRange{r.Address, lt.Rows[i+1].Address, label})
+		}
+	}
+	m.addVMLayer(vm)
+}
+
+/* builds the maps of one binary for a data source, the attributed bytes per label come out of tally */
+func bloatAnalyze(elfFs *elfFile, by string) *bloatMaps {
+	elfFs.getSections()
+	m := newBloatMaps(elfFs)
+
+	switch by {
+	case "sections":
+		m.sectionLayer(elfFs, false)
+	case "segments":
+		m.segmentLayer()
+	case "symbols":
+		m.symbolLayer(elfFs)
+	case "compileunits", "files":
+		d, err := loadDwarf(elfFs)
+		checkError(err)
+		if d == nil {
+			fmt.Printf("No DWARF debug info found in %s, only sections are shown\n", elfFs.Path)
+		} else if elfHeaderType(elfFs) != elf.ET_REL {
+			m.dwarfLayer(d, by == "files")
+		}
+	}
+	m.headerLayer(elfFs)
+	if by != "sections" {
+		m.sectionLayer(elfFs, true)
+	}
+	m.File.addLayer([]bloatRange{{0, m.FileSize, bloatUnattributed}})
+	var gaps []bloatRange
+	for _, r := range m.vmDomain {
+		gaps = append(gaps, bloatRange{r.Lo, r.Hi, bloatUnattributed})
+	}
+	m.VM.addLayer(gaps)
+	return m
+}
+
+type bloatRow struct {
+	Label      string
+	File, VM   int64
+	OldF, OldV uint64 // diff mode only
+}
+
+/* largest key first, the remainder past limit folded into one "[N Others]" row */
+func bloatTop(rows []bloatRow, limit int, key func(bloatRow) int64) []bloatRow {
+	sort.SliceStable(rows, func(a, b int) bool {
+		ka, kb := key(rows[a]), key(rows[b])
+		if ka != kb {
+			return ka > kb
+		}
+		return rows[a].Label < rows[b].Label
+	})
+	if limit <= 0 || len(rows) <= limit {
+		return rows
+	}
+	other := bloatRow{Label: fmt.Sprintf("[%d Others]", len(rows)-limit)}
+	for _, r := range rows[limit:] {
+		other.File += r.File
+		other.VM += r.VM
+		other.OldF += r.OldF
+		other.OldV += r.OldV
+	}
+	return append(rows[:limit:limit], other)
+}
+
+func percent(part, whole uint64) string {
+	if whole == 0 {
+		return "   -  "
+	}
+	return fmt.Sprintf("%5.1f%%", float64(part)*100/float64(whole))
+}
+
+// printBloat is --bloat: every byte of the file and of the mapped image
+// attributed to a section, segment, symbol, compile unit or source file.
+// Bytes no finer source claims fall back to their section and to the ELF
+// headers, what remains is listed as [Unattributed].
+func printBloat(elfFs *elfFile, by string, limit int) {
+	m := bloatAnalyze(elfFs, by)
+	files, vms := m.File.tally(), m.VM.tally()
+
+	var rows []bloatRow
+	for label := range labels(files, vms) {
+		rows = append(rows, bloatRow{Label: label, File: int64(files[label]), VM: int64(vms[label])})
+	}
+	rows = bloatTop(rows, limit, func(r bloatRow) int64 { return max(r.File, r.VM) })
+
+	fmt.Printf("Bloat of %s by %s:\n", elfFs.Path, by)
+	fmt.Printf("  %17s  %17s\n", "FILE SIZE", "VM SIZE")
+	for _, r := range rows {
+		fmt.Printf("  %10d %6s  %10d %6s  %s\n", r.File, percent(uint64(r.File), m.FileSize), r.VM, percent(uint64(r.VM), m.VMSize), r.Label)
+	}
+	fmt.Printf("  %10d %6s  %10d %6s  %s\n", m.FileSize, percent(m.FileSize, m.FileSize), m.VMSize, percent(m.VMSize, m.VMSize), "TOTAL")
+}
+
+func labels(maps ...map[string]uint64) map[string]bool {
+	keys := map[string]bool{}
+	for _, m := range maps {
+		for k := range m {
+			keys[k] = true
+		}
+	}
+	return keys
+}
+
+func deltaString(now, old uint64) (string, string) {
+	d := fmt.Sprintf("%+d", int64(now)-int64(old))
+	switch {
+	case now == old:
+		return d, ""
+	case old == 0:
+		return d, "[NEW]"
+	case now == 0:
+		return d, "[DEL]"
+	}
+	return d, fmt.Sprintf("%+.1f%%", (float64(now)-float64(old))*100/float64(old))
+}
+
+// printBloatDiff is --bloat-diff: the change per label from the target,
+// the old build, to newPath, largest change first. Labels whose size did not
+// change are left out.
+func printBloatDiff(elfFs *elfFile, newPath, by string, limit int) {
+	fh, err := os.Open(newPath)
+	checkError(err)
+	newFs, err := openElfReader(fh, newPath)
+	checkError(err)
+	defer newFs.Fh.Close()
+
+	old, now := bloatAnalyze(elfFs, by), bloatAnalyze(newFs, by)
+	oldF, oldV := old.File.tally(), old.VM.tally()
+	newF, newV := now.File.tally(), now.VM.tally()
+
+	var rows []bloatRow
+	for label := range labels(oldF, oldV, newF, newV) {
+		r := bloatRow{Label: label, File: int64(newF[label]), VM: int64(newV[label]), OldF: oldF[label], OldV: oldV[label]}
+		if uint64(r.File) != r.OldF || uint64(r.VM) != r.OldV {
+			rows = append(rows, r)
+		}
+	}
+	abs := func(v int64) int64 {
+		if v < 0 {
+			return -v
+		}
+		return v
+	}
+	rows = bloatTop(rows, limit, func(r bloatRow) int64 {
+		return max(abs(r.File-int64(r.OldF)), abs(r.VM-int64(r.OldV)))
+	})
+
+	fmt.Printf("Bloat diff %s -> %s by %s:\n", elfFs.Path, newPath, by)
+	fmt.Printf("  %19s  %19s\n", "FILE SIZE", "VM SIZE")
+	row := func(label string, file, oldFile, vm, oldVM uint64) {
+		fd, fp := deltaString(file, oldFile)
+		vd, vp := deltaString(vm, oldVM)
+		fmt.Printf("  %10s %8s  %10s %8s  %s\n", fd, fp, vd, vp, label)
+	}
+	for _, r := range rows {
+		row(r.Label, uint64(r.File), r.OldF, uint64(r.VM), r.OldV)
+	}
+	row("TOTAL", now.FileSize, old.FileSize, now.VMSize, old.VMSize)
+}
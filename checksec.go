@@ -0,0 +1,241 @@
+package main
+
+import (
+	"debug/elf"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+/* GNU property note entries, missing from debug/elf */
+const (
+	gnuPropertyX86Feature1And = 0xc0000002
+	gnuPropertyX86IBT         = 0x1
+	gnuPropertyX86SHSTK       = 0x2
+)
+
+/* the hardening of one file, as --checksec reports it */
+type checksecReport struct {
+	File      string   `json:"file"`
+	RELRO     string   `json:"relro"` // "full", "partial" or "none"
+	Canary    bool     `json:"canary"`
+	NX        bool     `json:"nx"`
+	PIE       string   `json:"pie"` // "pie", "dso", "no" or "rel"
+	RPATH     []string `json:"rpath"`
+	RUNPATH   []string `json:"runpath"`
+	TEXTREL   bool     `json:"textrel"`
+	Fortified []string `json:"fortified"` // the *_chk functions used
+	IBT       bool     `json:"ibt"`
+	SHSTK     bool     `json:"shstk"`
+	ExecStack bool     `json:"exec_stack"`
+}
+
+/* the x86 feature bits every input agreed on, from the NT_GNU_PROPERTY_TYPE_0 note */
+func x86Features(elfFs *elfFile) uint32 {
+	align := uint64(8)
+	if elfFs.FileHdr.Arch == elf.ELFCLASS32 {
+		align = 4
+	}
+	for _, ndx := range getSectionByType(elf.SHT_NOTE, elfFs) {
+		for _, n := range parseNotes(getSectionData(ndx, elfFs), elfFs) {
+			if n.Name != "GNU" || n.Type != ntGNUPropertyType0 {
+				continue
+			}
+			b := &dwarfBuf{data: n.Desc, order: elfFs.FileHdr.Endianness}
+			for b.off+8 <= uint64(len(n.Desc)) {
+				typ, size := b.u32(), uint64(b.u32())
+				data := b.bytes(size)
+				b.skip((align - size%align) % align)
+				if b.err == nil && typ == gnuPropertyX86Feature1And && size >= 4 {
+					return elfFs.FileHdr.Endianness.Uint32(data)
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// checksec inspects one file. RELRO is full when PT_GNU_RELRO comes with
+// BIND_NOW, NX and the executable stack follow PT_GNU_STACK (or the
+// .note.GNU-stack section of an object), a missing one meaning an
+// executable stack. The canary and FORTIFY_SOURCE are judged by the
+// __stack_chk_fail and __*_chk symbols the file imports, or defines when it
+// is statically linked.
+func checksec(elfFs *elfFile) checksecReport {
+	r := checksecReport{File: elfFs.Path, RELRO: "none", RPATH: []string{}, RUNPATH: []string{}, Fortified: []string{}}
+	dyn := loadDynamic(elfFs)
+	typ := elfHeaderType(elfFs)
+
+	elfFs.getProgHeaders()
+	relro, gnuStack := false, false
+	for i := 0; i < numProgHeaders(elfFs); i++ {
+		ph := getProgHeader(i, elfFs)
+		switch ph.Type {
+		case elf.PT_GNU_RELRO:
+			relro = true
+		case elf.PT_GNU_STACK:
+			gnuStack = true
+			r.ExecStack = ph.Flags&elf.PF_X != 0
+		}
+	}
+	if typ == elf.ET_REL {
+		ndx := getSectionNdx(".note.GNU-stack", elfFs)
+		r.ExecStack = ndx == 0 || getSectionHeader(ndx, elfFs).Flags&elf.SHF_EXECINSTR != 0
+	} else if !gnuStack {
+		r.ExecStack = true
+	}
+	r.NX = !r.ExecStack
+
+	_, dtBindNow := dyn.value(elf.DT_BIND_NOW)
+	switch {
+	case relro && (dtBindNow || dyn.hasFlag(elf.DF_BIND_NOW) || dyn.hasFlag1(elf.DF_1_NOW)):
+		r.RELRO = "full"
+	case relro:
+		r.RELRO = "partial"
+	}
+
+	_, interp := getProgHeaderByType(elf.PT_INTERP, elfFs)
+	switch {
+	case typ == elf.ET_REL:
+		r.PIE = "rel"
+	case typ == elf.ET_DYN && (dyn.hasFlag1(elf.DF_1_PIE) || interp):
+		r.PIE = "pie"
+	case typ == elf.ET_DYN:
+		r.PIE = "dso"
+	default:
+		r.PIE = "no"
+	}
+
+	r.RPATH = append(r.RPATH, dyn.strings(elf.DT_RPATH)...)
+	r.RUNPATH = append(r.RUNPATH, dyn.strings(elf.DT_RUNPATH)...)
+	_, dtTextrel := dyn.value(elf.DT_TEXTREL)
+	r.TEXTREL = dtTextrel || dyn.hasFlag(elf.DF_TEXTREL)
+
+	/* only the file's own tables, a debug file found elsewhere says nothing about the build */
+	_, dynamic := getProgHeaderByType(elf.PT_DYNAMIC, elfFs)
+	dynamic = dynamic || interp
+	fortified := map[string]bool{}
+	scan := func(symType int, n int) {
+		for i := uint32(1); i < uint32(n); i++ {
+			s, ok := getSymbol(i, symType, elfFs)
+			switch {
+			/* a dynamic libc defining __stack_chk_fail and the *_chk functions is not built with them, a static binary links them in */
+			case !ok || dynamic && elf.SectionIndex(s.Shndx) != elf.SHN_UNDEF:
+			case s.Name == "__stack_chk_fail" || s.Name == "__stack_chk_guard" || s.Name == "__intel_security_cookie":
+				r.Canary = true
+			case strings.HasPrefix(s.Name, "__") && strings.HasSuffix(s.Name, "_chk"):
+				fortified[s.Name] = true
+			}
+		}
+	}
+	elfFs.loadDynSymbols()
+	scan(dynSym, len(elfFs.DynSymbols))
+	if ndx := getSectionNdx(".symtab", elfFs); ndx != 0 {
+		elfFs.loadSymbols(ndx, getSectionHeader(ndx, elfFs).Link, sym)
+		scan(sym, len(elfFs.Symbols))
+	}
+	for name := range fortified {
+		r.Fortified = append(r.Fortified, name)
+	}
+	sort.Strings(r.Fortified)
+
+	if m := elfFs.FileHdr.Machine; m == elf.EM_X86_64 || m == elf.EM_386 {
+		f := x86Features(elfFs)
+		r.IBT, r.SHSTK = f&gnuPropertyX86IBT != 0, f&gnuPropertyX86SHSTK != 0
+	}
+	return r
+}
+
+/* the first program header of type t */
+func getProgHeaderByType(t elf.ProgType, elfFs *elfFile) (progHeader, bool) {
+	for i := 0; i < numProgHeaders(elfFs); i++ {
+		if ph := getProgHeader(i, elfFs); ph.Type == t {
+			return ph, true
+		}
+	}
+	return progHeader{}, false
+}
+
+func yesNo(b bool, yes, no string) string {
+	if b {
+		return yes
+	}
+	return no
+}
+
+/* what a file that could not be checked reports instead */
+type checksecError struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+func printChecksecRow(r checksecReport) {
+	relro := map[string]string{"full": "Full RELRO", "partial": "Partial RELRO", "none": "No RELRO"}[r.RELRO]
+	pie := map[string]string{"pie": "PIE enabled", "dso": "DSO", "no": "No PIE", "rel": "REL"}[r.PIE]
+	rpath := yesNo(len(r.RPATH) > 0, "RPATH", "No RPATH")
+	runpath := yesNo(len(r.RUNPATH) > 0, "RUNPATH", "No RUNPATH")
+	fortify := yesNo(len(r.Fortified) > 0, fmt.Sprintf("Yes (%d)", len(r.Fortified)), "No")
+	var cet []string
+	if r.IBT {
+		cet = append(cet, "IBT")
+	}
+	if r.SHSTK {
+		cet = append(cet, "SHSTK")
+	}
+	if len(cet) == 0 {
+		cet = append(cet, "No")
+	}
+	fmt.Printf("%-14s %-16s %-12s %-12s %-9s %-11s %-8s %-9s %-10s %-10s %s\n", relro,
+		yesNo(r.Canary, "Canary found", "No canary"), yesNo(r.NX, "NX enabled", "NX disabled"), pie, rpath, runpath,
+		yesNo(r.TEXTREL, "TEXTREL", "No"), fortify, strings.Join(cet, ","), yesNo(r.ExecStack, "Yes", "No"), r.File)
+}
+
+// printChecksec is --checksec: the hardening of every file given, one row
+// each or, with --checksec=json, an array of reports. Files that cannot be
+// read get a row, or an object, carrying the error.
+func printChecksec(files []string, format string) bool {
+	var reports []interface{}
+	ok := true
+	for _, file := range files {
+		var r checksecReport
+		err := catchError(func() {
+			fh, err := os.Open(file)
+			checkError(err)
+			defer fh.Close()
+			var magic [8]byte
+			fh.ReadAt(magic[:], 0)
+			if isArchive(magic[:]) {
+				checkError(fmt.Errorf("%s is an archive, check the objects it was built from", file))
+			}
+			elfFs, err := openElfReader(fh, file)
+			checkError(err)
+			r = checksec(elfFs)
+		})
+		if err != nil {
+			reports = append(reports, checksecError{file, err.Error()})
+			ok = false
+			continue
+		}
+		reports = append(reports, r)
+	}
+
+	if format == "json" {
+		out, err := json.MarshalIndent(reports, "", "  ")
+		checkError(err)
+		fmt.Println(string(out))
+		return ok
+	}
+	fmt.Printf("%-14s %-16s %-12s %-12s %-9s %-11s %-8s %-9s %-10s %-10s %s\n",
+		"RELRO", "STACK CANARY", "NX", "PIE", "RPATH", "RUNPATH", "TEXTREL", "FORTIFY", "CET", "EXECSTACK", "FILE")
+	for _, r := range reports {
+		switch r := r.(type) {
+		case checksecReport:
+			printChecksecRow(r)
+		case checksecError:
+			fmt.Printf("Error: %s: %s\n", r.File, r.Error)
+		}
+	}
+	return ok
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// The fixtures are built from one x86-64 file defining __stack_chk_fail and
+// __printf_chk: chk-static is it linked with ld -static, chk-lib.so with
+// ld -shared, and chk-use is a PIE importing both from chk-lib.so.
+func TestChecksecCanaryFortify(t *testing.T) {
+	tests := []struct {
+		file      string
+		canary    bool
+		fortified []string
+	}{
+		{"testdata/chk-static", true, []string{"__printf_chk"}},
+		{"testdata/chk-lib.so", false, []string{}},
+		{"testdata/chk-use", true, []string{"__printf_chk"}},
+	}
+	for _, tt := range tests {
+		elfFs, err := openElfFile(tt.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := checksec(elfFs)
+		elfFs.Fh.Close()
+		if r.Canary != tt.canary || !reflect.DeepEqual(r.Fortified, tt.fortified) {
+			t.Errorf("%s: canary %v, fortified %q, want %v, %q", tt.file, r.Canary, r.Fortified, tt.canary, tt.fortified)
+		}
+	}
+}
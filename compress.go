@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/* compression header of an SHF_COMPRESSED section or of a legacy .zdebug section */
+type chdrInfo struct {
+	Type      elf.CompressionType
+	Size      uint64 // uncompressed size
+	Addralign uint64
+	HdrSize   int
+	Legacy    bool
+}
+
+/* .zdebug_* sections written by older toolchains start with "ZLIB" and a big endian size */
+func isLegacyCompressed(sh sectionHeader) bool {
+	return strings.HasPrefix(sh.Name, ".zdebug")
+}
+
+func parseChdr(sh sectionHeader, raw []byte, elfFs *elfFile) (chdrInfo, bool) {
+	var c chdrInfo
+	if sh.Flags&elf.SHF_COMPRESSED != 0 {
+		order := elfFs.FileHdr.Endianness
+		switch elfFs.FileHdr.Arch {
+		case elf.ELFCLASS32:
+			if len(raw) < 12 {
+				return c, false
+			}
+			c.Type = elf.CompressionType(order.Uint32(raw))
+			c.Size = uint64(order.Uint32(raw[4:]))
+			c.Addralign = uint64(order.Uint32(raw[8:]))
+			c.HdrSize = 12
+		case elf.ELFCLASS64:
+			if len(raw) < 24 {
+				return c, false
+			}
+			c.Type = elf.CompressionType(order.Uint32(raw))
+			c.Size = order.Uint64(raw[8:])
+			c.Addralign = order.Uint64(raw[16:])
+			c.HdrSize = 24
+		}
+		return c, true
+	}
+
+	if isLegacyCompressed(sh) && len(raw) >= 12 && string(raw[:4]) == "ZLIB" {
+		c.Type = elf.COMPRESS_ZLIB
+		c.Size = binary.BigEndian.Uint64(raw[4:])
+		c.Addralign = sh.Addralign
+		c.HdrSize = 12
+		c.Legacy = true
+		return c, true
+	}
+	return c, false
+}
+
+/* reads only the compression header, for listings that do not need the contents */
+func getSectionChdr(ndx uint32, elfFs *elfFile) (chdrInfo, bool) {
+	sh := getSectionHeader(ndx, elfFs)
+	if sh.Type == elf.SHT_NOBITS || sh.Flags&elf.SHF_COMPRESSED == 0 && !isLegacyCompressed(sh) {
+		return chdrInfo{}, false
+	}
+	n := sh.Size
+	if n > 24 {
+		n = 24
+	}
+	raw := make([]byte, n)
+	if _, err := elfFs.Fh.ReadAt(raw, int64(sh.Off)); err != nil {
+		return chdrInfo{}, false
+	}
+	return parseChdr(sh, raw, elfFs)
+}
+
+func decompressSection(c chdrInfo, raw []byte) ([]byte, error) {
+	payload := raw[c.HdrSize:]
+	var out []byte
+	var err error
+	switch c.Type {
+	case elf.COMPRESS_ZLIB:
+		var zr io.ReadCloser
+		if zr, err = zlib.NewReader(bytes.NewReader(payload)); err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		out, err = io.ReadAll(zr)
+	case elf.COMPRESS_ZSTD:
+		out, err = zstdDecompress(payload)
+	default:
+		return nil, fmt.Errorf("unsupported compression type %d", uint32(c.Type))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(out)) != c.Size {
+		return nil, fmt.Errorf("decompressed to %d bytes, header says %d", len(out), c.Size)
+	}
+	return out, nil
+}
+
+func compressionName(t elf.CompressionType) string {
+	switch t {
+	case elf.COMPRESS_ZLIB:
+		return "ZLIB"
+	case elf.COMPRESS_ZSTD:
+		return "ZSTD"
+	}
+	return fmt.Sprintf("0x%x", uint32(t))
+}
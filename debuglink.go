@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GNU note types, debug/elf only knows the core file ones
+const (
+	ntGNUABITag        = 1
+	ntGNUHWCap         = 2
+	ntGNUBuildID       = 3
+	ntGNUGoldVersion   = 4
+	ntGNUPropertyType0 = 5
+)
+
+/* roots searched for separate debug files, extended with --debug-dir */
+var debugDirs = []string{"/usr/lib/debug"}
+
+type elfNote struct {
+	Name string
+	Type uint32
+	Desc []byte
+}
+
+/* splits an SHT_NOTE section or PT_NOTE segment into its entries */
+func parseNotes(data []byte, elfFs *elfFile) []elfNote {
+	var notes []elfNote
+	b := &dwarfBuf{data: data, order: elfFs.FileHdr.Endianness}
+	for b.off+12 <= uint64(len(data)) {
+		namesz := uint64(b.u32())
+		descsz := uint64(b.u32())
+		n := elfNote{Type: b.u32()}
+		name := b.bytes(namesz)
+		b.skip((4 - namesz%4) % 4)
+		n.Desc = b.bytes(descsz)
+		b.skip((4 - descsz%4) % 4)
+		if b.err != nil {
+			break
+		}
+		n.Name = string(bytes.TrimRight(name, "\x00"))
+		notes = append(notes, n)
+	}
+	return notes
+}
+
+func getBuildID(elfFs *elfFile) []byte {
+	for _, ndx := range getSectionByType(elf.SHT_NOTE, elfFs) {
+		for _, n := range parseNotes(getSectionData(ndx, elfFs), elfFs) {
+			if n.Name == "GNU" && n.Type == ntGNUBuildID {
+				return n.Desc
+			}
+		}
+	}
+	return nil
+}
+
+/* .gnu_debuglink holds the debug file name, padding to 4 bytes and a CRC32 of the whole file */
+func getDebuglink(elfFs *elfFile) (string, uint32, bool) {
+	data := getSectionDataByName(".gnu_debuglink", elfFs)
+	if data == nil {
+		return "", 0, false
+	}
+	end := bytes.IndexByte(data, 0)
+	if end <= 0 {
+		return "", 0, false
+	}
+	crcOff := (end + 4) &^ 3
+	if crcOff+4 > len(data) {
+		return "", 0, false
+	}
+	return string(data[:end]), elfFs.FileHdr.Endianness.Uint32(data[crcOff:]), true
+}
+
+/* .gnu_debugaltlink names the dwz common file followed by its build-id */
+func getDebugAltlink(elfFs *elfFile) (string, []byte, bool) {
+	data := getSectionDataByName(".gnu_debugaltlink", elfFs)
+	if data == nil {
+		return "", nil, false
+	}
+	end := bytes.IndexByte(data, 0)
+	if end <= 0 {
+		return "", nil, false
+	}
+	return string(data[:end]), data[end+1:], true
+}
+
+func fileCRC32(path string) (uint32, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer fh.Close()
+
+	h := crc32.NewIEEE()
+	if _, err := io.Copy(h, fh); err != nil {
+		return 0, err
+	}
+	return h.Sum32(), nil
+}
+
+/* opens an auxiliary ELF file, unlike main() it reports problems instead of exiting */
+func openElfFile(path string) (*elfFile, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return openElfReader(fh, path)
+}
+
+type memElf struct {
+	*bytes.Reader
+}
+
+func (memElf) Close() error { return nil }
+
+/* opens an ELF image held in memory, name is only used in messages */
+func openElfBytes(data []byte, name string) (*elfFile, error) {
+	return openElfReader(memElf{bytes.NewReader(data)}, name)
+}
+
+func openElfReader(fh elfReader, name string) (*elfFile, error) {
+	elfFs := &elfFile{Fh: fh, Path: name}
+	if _, err := io.ReadFull(elfFs.Fh, elfFs.Ident[:]); err != nil || !isElf(elfFs.Ident[:4]) {
+		elfFs.Fh.Close()
+		return nil, fmt.Errorf("%s is not an Elf binary", name)
+	}
+	switch elf.Class(elfFs.Ident[elf.EI_CLASS]) {
+	case elf.ELFCLASS32, elf.ELFCLASS64:
+	default:
+		elfFs.Fh.Close()
+		return nil, fmt.Errorf("%s has an invalid Elf class", name)
+	}
+
+	elfFs.setArch()
+	elfFs.mapHeader()
+	elfFs.getSections()
+	return elfFs, nil
+}
+
+func buildIDPath(root string, id []byte) string {
+	if len(id) < 2 {
+		return ""
+	}
+	h := hex.EncodeToString(id)
+	return filepath.Join(root, ".build-id", h[:2], h[2:]+".debug")
+}
+
+func isSameFile(a, b string) bool {
+	sa, errA := os.Stat(a)
+	sb, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(sa, sb)
+}
+
+type debugCandidate struct {
+	Path      string
+	ByBuildID bool
+}
+
+// gdb's search order: build-id under each root, then the debuglink name next to
+// the binary, in its .debug directory and mirrored under each root
+
+func debugFileCandidates(elfFs *elfFile) []debugCandidate {
+	var candidates []debugCandidate
+	if id := getBuildID(elfFs); id != nil {
+		for _, root := range debugDirs {
+			candidates = append(candidates, debugCandidate{buildIDPath(root, id), true})
+		}
+	}
+
+	if name, _, ok := getDebuglink(elfFs); ok && elfFs.Path != "" {
+		abs, err := filepath.Abs(elfFs.Path)
+		if err != nil {
+			abs = elfFs.Path
+		}
+		dir := filepath.Dir(abs)
+		candidates = append(candidates, debugCandidate{filepath.Join(dir, name), false})
+		candidates = append(candidates, debugCandidate{filepath.Join(dir, ".debug", name), false})
+		for _, root := range debugDirs {
+			candidates = append(candidates, debugCandidate{filepath.Join(root, dir, name), false})
+		}
+	}
+	return candidates
+}
+
+/* build-id hits must carry the same build-id, debuglink hits must match the recorded CRC */
+func verifyDebugFile(elfFs *elfFile, c debugCandidate) (*elfFile, error) {
+	if elfFs.Path != "" && isSameFile(elfFs.Path, c.Path) {
+		return nil, errors.New("candidate is the target itself")
+	}
+
+	if !c.ByBuildID {
+		_, crc, _ := getDebuglink(elfFs)
+		sum, err := fileCRC32(c.Path)
+		if err != nil {
+			return nil, err
+		}
+		if sum != crc {
+			return nil, fmt.Errorf("CRC mismatch (0x%08x, expected 0x%08x)", sum, crc)
+		}
+	}
+
+	dbg, err := openElfFile(c.Path)
+	if err != nil {
+		return nil, err
+	}
+	if c.ByBuildID && !bytes.Equal(getBuildID(elfFs), getBuildID(dbg)) {
+		dbg.Fh.Close()
+		return nil, errors.New("build-id mismatch")
+	}
+	return dbg, nil
+}
+
+/* locates and opens the separate debug file of a stripped binary, the result is cached */
+func (elfFs *elfFile) getDebugFile() *elfFile {
+	if elfFs.debugSearched {
+		return elfFs.Debug
+	}
+	elfFs.debugSearched = true
+	if len(elfFs.ElfSections.SectionName) == 0 {
+		elfFs.getSections()
+	}
+
+	for _, c := range debugFileCandidates(elfFs) {
+		if _, err := os.Stat(c.Path); err != nil {
+			continue
+		}
+		dbg, err := verifyDebugFile(elfFs, c)
+		if err != nil {
+			fmt.Printf("Warning: ignoring debug file %s: %v\n", c.Path, err)
+			continue
+		}
+		elfFs.Debug = dbg
+		return dbg
+	}
+	return nil
+}
+
+/* locates the dwz common file named by .gnu_debugaltlink, relative names resolve against the linking file */
+func getAltDebugFile(elfFs *elfFile) *elfFile {
+	name, id, ok := getDebugAltlink(elfFs)
+	if !ok {
+		return nil
+	}
+
+	var candidates []string
+	if filepath.IsAbs(name) {
+		candidates = append(candidates, name)
+		for _, root := range debugDirs {
+			candidates = append(candidates, filepath.Join(root, name))
+		}
+	} else if elfFs.Path != "" {
+		candidates = append(candidates, filepath.Join(filepath.Dir(elfFs.Path), name))
+	}
+	for _, root := range debugDirs {
+		if p := buildIDPath(root, id); p != "" {
+			candidates = append(candidates, p)
+		}
+	}
+
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		alt, err := openElfFile(path)
+		if err != nil {
+			continue
+		}
+		if aid := getBuildID(alt); aid != nil && !bytes.Equal(aid, id) {
+			alt.Fh.Close()
+			continue
+		}
+		return alt
+	}
+	return nil
+}
+
+/* decodes the MiniDebugInfo of .gnu_debugdata, an xz compressed ELF carrying only a .symtab */
+func (elfFs *elfFile) getMiniDebugInfo() *elfFile {
+	if elfFs.miniSearched {
+		return elfFs.MiniDebug
+	}
+	elfFs.miniSearched = true
+
+	data := getSectionDataByName(".gnu_debugdata", elfFs)
+	if data == nil {
+		return nil
+	}
+	raw, err := xzDecompress(data)
+	if err != nil {
+		fmt.Printf("Warning: cannot decompress .gnu_debugdata: %v\n", err)
+		return nil
+	}
+	mini, err := openElfBytes(raw, elfFs.Path+"(.gnu_debugdata)")
+	if err != nil {
+		fmt.Printf("Warning: ignoring .gnu_debugdata: %v\n", err)
+		return nil
+	}
+	elfFs.MiniDebug = mini
+	return mini
+}
+
+/* loads .symtab, falling back to the separate debug file and then to MiniDebugInfo when the target is stripped */
+func (elfFs *elfFile) loadSymtab() *elfFile {
+	src := elfFs
+	ndx := getSectionNdx(".symtab", elfFs)
+	if ndx == 0 {
+		if dbg := elfFs.getDebugFile(); dbg != nil {
+			src = dbg
+			ndx = getSectionNdx(".symtab", dbg)
+		}
+	}
+	if ndx == 0 {
+		if mini := elfFs.getMiniDebugInfo(); mini != nil {
+			src = mini
+			ndx = getSectionNdx(".symtab", mini)
+		}
+	}
+	if ndx == 0 {
+		return nil
+	}
+
+	src.loadSymbols(ndx, getSectionNdx(".strtab", src), sym)
+	elfFs.Symbols, elfFs.SymbolsName = src.Symbols, src.SymbolsName
+	return src
+}
+
+func printDebugLink(elfFs *elfFile) {
+	elfFs.getSections()
+
+	if id := getBuildID(elfFs); id != nil {
+		fmt.Printf("Build ID: %x\n", id)
+	} else {
+		fmt.Println("Build ID: none")
+	}
+	if name, crc, ok := getDebuglink(elfFs); ok {
+		fmt.Printf("Debug link: %s (CRC32 0x%08x)\n", name, crc)
+	} else {
+		fmt.Println("Debug link: none")
+	}
+	if name, id, ok := getDebugAltlink(elfFs); ok {
+		fmt.Printf("Debug alt link: %s (build-id %x)\n", name, id)
+	}
+
+	fmt.Println("Search paths:")
+	for _, c := range debugFileCandidates(elfFs) {
+		status := "missing"
+		if _, err := os.Stat(c.Path); err == nil {
+			status = "present"
+		}
+		fmt.Printf("  %s [%s]\n", c.Path, status)
+	}
+
+	if dbg := elfFs.getDebugFile(); dbg != nil {
+		fmt.Printf("Separate debug file: %s\n", dbg.Path)
+		if alt := getAltDebugFile(dbg); alt != nil {
+			fmt.Printf("Alternate debug file: %s\n", alt.Path)
+		}
+	} else {
+		fmt.Println("Separate debug file: not found")
+	}
+}
+
+/* pulls --debug-dir=DIR arguments out of argv, each adds a root to search ahead of the default */
+func extractDebugDirs(args []string) []string {
+	var rest []string
+	var dirs []string
+	for _, a := range args {
+		if strings.HasPrefix(a, "--debug-dir=") {
+			dirs = append(dirs, strings.TrimPrefix(a, "--debug-dir="))
+			continue
+		}
+		rest = append(rest, a)
+	}
+	if dirs != nil {
+		debugDirs = append(dirs, debugDirs...)
+	}
+	return rest
+}
@@ -0,0 +1,84 @@
+// Package demangle turns Itanium C++ ABI and Rust (legacy and v0) symbol
+// names back into source form. Output follows GNU c++filt, so
+// _ZNSt6vectorIiSaIiEE9push_backERKi reads
+// std::vector<int, std::allocator<int> >::push_back(int const&).
+package demangle
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrNotMangled is returned for names that use none of the supported manglings.
+	ErrNotMangled = errors.New("demangle: not a mangled name")
+	// ErrInvalid is returned for names that look mangled but do not parse.
+	ErrInvalid = errors.New("demangle: invalid mangled name")
+)
+
+// Option changes how Demangle and Filter print names.
+type Option int
+
+const (
+	// NoVerbose prints names as nm -C and readelf -C do rather than c++filt:
+	// Ss, Si, So and Sd read std::string, std::istream, std::ostream and
+	// std::iostream, and Rust hashes and crate disambiguators are left out.
+	NoVerbose Option = iota + 1
+)
+
+// Demangle returns the demangled form of name. Rust legacy symbols share the
+// _ZN prefix with C++ and are recognised by their trailing hash segment, as
+// c++filt does.
+func Demangle(name string, opts ...Option) (string, error) {
+	verbose := true
+	for _, o := range opts {
+		if o == NoVerbose {
+			verbose = false
+		}
+	}
+	switch {
+	case strings.HasPrefix(name, "_R"):
+		return demangleRustV0(name, verbose)
+	case strings.HasPrefix(name, "_ZN"):
+		if s, ok := demangleRustLegacy(name, verbose); ok {
+			return s, nil
+		}
+		return demangleItanium(name, verbose)
+	case strings.HasPrefix(name, "_Z"):
+		return demangleItanium(name, verbose)
+	case strings.HasPrefix(name, "_GLOBAL_"):
+		return demangleGlobalCtor(name, opts)
+	}
+	return "", ErrNotMangled
+}
+
+// Filter returns the demangled form of name, or name itself when it is not a
+// mangled name or fails to parse. A symbol version suffix (@VERSION or
+// @@VERSION) is kept as it is.
+func Filter(name string, opts ...Option) string {
+	base, version := name, ""
+	if i := strings.IndexByte(name, '@'); i > 0 {
+		base, version = name[:i], name[i:]
+	}
+	if s, err := Demangle(base, opts...); err == nil {
+		return s + version
+	}
+	return name
+}
+
+/* _GLOBAL_[._$][ID]_ introduces the static constructors and destructors GCC emits per file */
+func demangleGlobalCtor(name string, opts []Option) (string, error) {
+	if len(name) < 11 || !strings.ContainsRune("._$", rune(name[8])) || name[10] != '_' {
+		return "", ErrNotMangled
+	}
+	var kind string
+	switch name[9] {
+	case 'I':
+		kind = "global constructors keyed to "
+	case 'D':
+		kind = "global destructors keyed to "
+	default:
+		return "", ErrNotMangled
+	}
+	return kind + Filter(name[11:], opts...), nil
+}
@@ -0,0 +1,94 @@
+package demangle
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+/* each non-comment line of a corpus file is a mangled name, a tab and what c++filt prints for it */
+func testCorpus(t *testing.T, file string) {
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	n := 0
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		mangled, want, ok := strings.Cut(line, "\t")
+		if !ok {
+			t.Fatalf("%s: malformed line %q", file, line)
+		}
+		if got := Filter(mangled); got != want {
+			t.Errorf("%s\n got  %s\n want %s", mangled, got, want)
+		}
+		n++
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n == 0 {
+		t.Fatalf("%s: no test cases", file)
+	}
+}
+
+func TestItanium(t *testing.T) { testCorpus(t, "testdata/itanium.txt") }
+
+func TestRust(t *testing.T) { testCorpus(t, "testdata/rust.txt") }
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"main", ErrNotMangled},
+		{"_GLOBAL__sub_I_main.cpp", ErrNotMangled},
+		{"_Z", ErrInvalid},
+		{"_ZN3foo", ErrInvalid},
+		{"_Z1fQ", ErrInvalid},
+		{"_RNvC", ErrInvalid},
+	}
+	for _, tt := range tests {
+		if _, err := Demangle(tt.name); !errors.Is(err, tt.err) {
+			t.Errorf("Demangle(%q) error = %v, want %v", tt.name, err, tt.err)
+		}
+		if got := Filter(tt.name); got != tt.name {
+			t.Errorf("Filter(%q) = %q, want it unchanged", tt.name, got)
+		}
+	}
+}
+
+/* expected output from GNU nm -C, which demangles without DMGL_VERBOSE */
+func TestNoVerbose(t *testing.T) {
+	tests := []struct{ mangled, nm, cxxfilt string }{
+		{"_ZNKSs4findERKSsm",
+			"std::string::find(std::string const&, unsigned long) const",
+			"std::basic_string<char, std::char_traits<char>, std::allocator<char> >::find(std::basic_string<char, std::char_traits<char>, std::allocator<char> > const&, unsigned long) const"},
+		{"_ZNSdD0Ev",
+			"std::basic_iostream<char, std::char_traits<char> >::~basic_iostream()",
+			"std::basic_iostream<char, std::char_traits<char> >::~basic_iostream()"},
+		{"_ZN3std6thread6Thread4name17hd5c07ccb0936e14bE",
+			"std::thread::Thread::name",
+			"std::thread::Thread::name::hd5c07ccb0936e14b"},
+		{"_RNvCsj4CZ6flxxfE_7___rustc12___rust_alloc",
+			"__rustc::__rust_alloc",
+			"__rustc[de2ca18b4c54d5b8]::__rust_alloc"},
+	}
+	for _, tt := range tests {
+		if got := Filter(tt.mangled, NoVerbose); got != tt.nm {
+			t.Errorf("%s without verbose\n got  %s\n want %s", tt.mangled, got, tt.nm)
+		}
+		if got := Filter(tt.mangled); got != tt.cxxfilt {
+			t.Errorf("%s\n got  %s\n want %s", tt.mangled, got, tt.cxxfilt)
+		}
+	}
+}
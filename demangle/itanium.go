@@ -0,0 +1,1994 @@
+package demangle
+
+import (
+	"strconv"
+	"strings"
+)
+
+// The Itanium demangler parses into a small tree and prints it afterwards,
+// since substitutions and template parameters refer back to earlier parts of
+// the name. Types print in two halves around the declarator: "void (*" and
+// ")(int)" for a function pointer.
+
+type node interface {
+	printLeft(p *printer)
+	printRight(p *printer)
+}
+
+type printer struct {
+	buf     []byte
+	lastc   byte // last byte written, which survives a comma taken back
+	packIdx int
+	packMax int // noPack outside a pack expansion
+
+	templates [][]node // arguments of the template functions being printed, innermost last
+	lambda    bool     // T_ in a generic lambda's parameters prints as auto:N
+}
+
+const noPack = -1
+
+func (p *printer) str(s string) {
+	if s != "" {
+		p.buf = append(p.buf, s...)
+		p.lastc = s[len(s)-1]
+	}
+}
+
+/* c++filt decides on "> >" by this, so after an empty pack it sees the comma's space */
+func (p *printer) last() byte { return p.lastc }
+
+func (p *printer) print(n node) {
+	n.printLeft(p)
+	n.printRight(p)
+}
+
+/* comma separated, dropping the comma in front of an empty pack expansion */
+func (p *printer) list(nodes []node) {
+	first := true
+	for _, n := range nodes {
+		before := len(p.buf)
+		if !first {
+			p.str(", ")
+		}
+		after := len(p.buf)
+		p.print(n)
+		if len(p.buf) == after {
+			p.buf = p.buf[:before]
+			continue
+		}
+		first = false
+	}
+}
+
+/* what a template parameter or pack stands for at this point of printing, nil for an exhausted pack */
+func (p *printer) resolve(n node) node {
+	save := p.templates
+	defer func() { p.templates = save }()
+	for {
+		switch t := n.(type) {
+		case *templateParamNode:
+			n, p.templates = t.lookup(p)
+		case *paramPackNode:
+			if n = t.current(p); n == nil {
+				return nil
+			}
+		default:
+			return n
+		}
+	}
+}
+
+/* whether a type prints a part after the declarator, arrays and functions */
+func hasRight(n node, p *printer) bool {
+	switch t := p.resolve(n).(type) {
+	case *funcTypeNode, *arrayNode, *encodingNode:
+		return true
+	case *pointerNode:
+		return hasRight(t.pointee, p)
+	case *refNode:
+		return hasRight(t.pointee, p)
+	case *ptrToMemberNode:
+		return hasRight(t.member, p)
+	case *qualNode:
+		return hasRight(t.child, p)
+	}
+	return false
+}
+
+func isFunction(n node, p *printer) bool {
+	switch t := p.resolve(n).(type) {
+	case *funcTypeNode:
+		return true
+	case *qualNode:
+		return isFunction(t.child, p)
+	}
+	return false
+}
+
+func isArray(n node, p *printer) bool {
+	switch t := p.resolve(n).(type) {
+	case *arrayNode:
+		return true
+	case *qualNode:
+		return isArray(t.child, p)
+	}
+	return false
+}
+
+type nameNode struct{ name string }
+
+func (n *nameNode) printLeft(p *printer)  { p.str(n.name) }
+func (n *nameNode) printRight(p *printer) {}
+
+/* builtin types, kept apart from names because expressions parenthesise them */
+type builtinNode struct{ name string }
+
+func (n *builtinNode) printLeft(p *printer)  { p.str(n.name) }
+func (n *builtinNode) printRight(p *printer) {}
+
+type nestedNameNode struct{ qual, name node }
+
+func (n *nestedNameNode) printLeft(p *printer) {
+	p.print(n.qual)
+	p.str("::")
+	p.print(n.name)
+}
+func (n *nestedNameNode) printRight(p *printer) {}
+
+type templateArgsNode struct{ args []node }
+
+func (n *templateArgsNode) printLeft(p *printer) {
+	if p.last() == '<' {
+		p.str(" ")
+	}
+	p.str("<")
+	p.list(n.args)
+	if p.last() == '>' {
+		p.str(" ")
+	}
+	p.str(">")
+}
+func (n *templateArgsNode) printRight(p *printer) {}
+
+type nameWithArgsNode struct {
+	name node
+	args *templateArgsNode
+}
+
+func (n *nameWithArgsNode) printLeft(p *printer) {
+	p.print(n.name)
+	p.print(n.args)
+}
+func (n *nameWithArgsNode) printRight(p *printer) {}
+
+/* the abbreviations S[absiod] stand for */
+type specialSubNode struct{ name, base string }
+
+func (n *specialSubNode) printLeft(p *printer)  { p.str(n.name) }
+func (n *specialSubNode) printRight(p *printer) {}
+
+type ctorDtorNode struct {
+	base string
+	dtor bool
+}
+
+func (n *ctorDtorNode) printLeft(p *printer) {
+	if n.dtor {
+		p.str("~")
+	}
+	p.str(n.base)
+}
+func (n *ctorDtorNode) printRight(p *printer) {}
+
+type abiTagNode struct {
+	base node
+	tag  string
+}
+
+func (n *abiTagNode) printLeft(p *printer) {
+	p.print(n.base)
+	p.str("[abi:" + n.tag + "]")
+}
+func (n *abiTagNode) printRight(p *printer) {}
+
+type qualNode struct {
+	child node
+	quals string
+}
+
+func (n *qualNode) printLeft(p *printer) {
+	n.child.printLeft(p)
+	if !isFunction(n.child, p) {
+		p.str(n.extra(p))
+	}
+}
+
+/* the qualifiers the child, say a const template argument, does not print already */
+func (n *qualNode) extra(p *printer) string {
+	inner, ok := p.resolve(n.child).(*qualNode)
+	if !ok {
+		return n.quals
+	}
+	var out string
+	for _, q := range strings.Fields(n.quals) {
+		if !contains(strings.Fields(inner.quals), q) {
+			out += " " + q
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *qualNode) printRight(p *printer) {
+	n.child.printRight(p)
+	if isFunction(n.child, p) {
+		p.str(n.quals)
+	}
+}
+
+type pointerNode struct {
+	pointee node
+	sym     string // "*", or " _Complex" and " _Imaginary" which print the same way
+}
+
+func (n *pointerNode) printLeft(p *printer) {
+	n.pointee.printLeft(p)
+	if isArray(n.pointee, p) {
+		p.str(" ")
+	}
+	if isArray(n.pointee, p) || isFunction(n.pointee, p) {
+		p.str("(")
+	}
+	p.str(n.sym)
+}
+
+func (n *pointerNode) printRight(p *printer) {
+	if isArray(n.pointee, p) || isFunction(n.pointee, p) {
+		p.str(")")
+	}
+	n.pointee.printRight(p)
+}
+
+type refNode struct {
+	pointee node
+	rvalue  bool
+}
+
+/* references to references collapse, any & in the chain makes it an lvalue reference */
+func (n *refNode) collapse(p *printer) (node, string) {
+	var pointee node = n
+	rvalue := true
+	for {
+		r, ok := p.resolve(pointee).(*refNode)
+		if !ok {
+			break
+		}
+		rvalue = rvalue && r.rvalue
+		pointee = r.pointee
+	}
+	if rvalue {
+		return pointee, "&&"
+	}
+	return pointee, "&"
+}
+
+func (n *refNode) printLeft(p *printer) {
+	pointee, sym := n.collapse(p)
+	pointee.printLeft(p)
+	if isArray(pointee, p) {
+		p.str(" ")
+	}
+	if isArray(pointee, p) || isFunction(pointee, p) {
+		p.str("(")
+	}
+	p.str(sym)
+}
+
+func (n *refNode) printRight(p *printer) {
+	pointee, _ := n.collapse(p)
+	if isArray(pointee, p) || isFunction(pointee, p) {
+		p.str(")")
+	}
+	pointee.printRight(p)
+}
+
+type ptrToMemberNode struct{ class, member node }
+
+func (n *ptrToMemberNode) printLeft(p *printer) {
+	n.member.printLeft(p)
+	if isArray(n.member, p) || isFunction(n.member, p) {
+		p.str("(")
+	} else {
+		p.str(" ")
+	}
+	p.print(n.class)
+	p.str("::*")
+}
+
+func (n *ptrToMemberNode) printRight(p *printer) {
+	if isArray(n.member, p) || isFunction(n.member, p) {
+		p.str(")")
+	}
+	n.member.printRight(p)
+}
+
+type funcTypeNode struct {
+	ret    node
+	params []node
+	quals  string // cv and ref qualifiers of member function types
+	spec   node   // exception specification, nil for none
+}
+
+func (n *funcTypeNode) printLeft(p *printer) {
+	n.ret.printLeft(p)
+	/* a return type that opened its own parenthesis, void (*(int))(), takes no space */
+	if !hasRight(n.ret, p) {
+		p.str(" ")
+	}
+}
+
+func (n *funcTypeNode) printRight(p *printer) {
+	p.str("(")
+	p.list(n.params)
+	p.str(")")
+	if n.spec != nil {
+		p.str(" ")
+		p.print(n.spec)
+	}
+	n.ret.printRight(p)
+	p.str(n.quals)
+}
+
+type arrayNode struct {
+	elem node
+	dim  node // nil for an unknown bound
+}
+
+func (n *arrayNode) printLeft(p *printer) { n.elem.printLeft(p) }
+
+func (n *arrayNode) printRight(p *printer) {
+	if p.last() != ']' {
+		p.str(" ")
+	}
+	p.str("[")
+	if n.dim != nil {
+		p.print(n.dim)
+	}
+	p.str("]")
+	n.elem.printRight(p)
+}
+
+type vectorNode struct {
+	elem node
+	dim  node
+}
+
+func (n *vectorNode) printLeft(p *printer) {
+	p.print(n.elem)
+	p.str(" __vector(")
+	p.print(n.dim)
+	p.str(")")
+}
+func (n *vectorNode) printRight(p *printer) {}
+
+/* a function: optional return type (templates), name, parameters and member qualifiers */
+type encodingNode struct {
+	ret    node
+	name   node
+	params []node
+	quals  string
+	tmpl   []node // the function's template arguments, nil when it is no template
+}
+
+func (n *encodingNode) push(p *printer) func() {
+	if n.tmpl == nil {
+		return func() {}
+	}
+	p.templates = append(p.templates, n.tmpl)
+	return func() { p.templates = p.templates[:len(p.templates)-1] }
+}
+
+func (n *encodingNode) printLeft(p *printer) {
+	defer n.push(p)()
+	if n.ret != nil {
+		n.ret.printLeft(p)
+		if !hasRight(n.ret, p) {
+			p.str(" ")
+		}
+	}
+	p.print(n.name)
+}
+
+func (n *encodingNode) printRight(p *printer) {
+	defer n.push(p)()
+	p.str("(")
+	p.list(n.params)
+	p.str(")")
+	if n.ret != nil {
+		n.ret.printRight(p)
+	}
+	p.str(n.quals)
+}
+
+/* "vtable for ", "guard variable for " and the like in front of a name */
+type specialNameNode struct {
+	prefix string
+	child  node
+}
+
+func (n *specialNameNode) printLeft(p *printer) {
+	p.str(n.prefix)
+	p.print(n.child)
+}
+func (n *specialNameNode) printRight(p *printer) {}
+
+type ctorVtableNode struct{ first, second node }
+
+func (n *ctorVtableNode) printLeft(p *printer) {
+	p.str("construction vtable for ")
+	p.print(n.second)
+	p.str("-in-")
+	p.print(n.first)
+}
+func (n *ctorVtableNode) printRight(p *printer) {}
+
+type localNameNode struct{ encoding, entity node }
+
+func (n *localNameNode) printLeft(p *printer) {
+	p.print(n.encoding)
+	p.str("::")
+	p.print(n.entity)
+}
+func (n *localNameNode) printRight(p *printer) {}
+
+/* the elements of a J...E template argument pack, printed in place */
+type argPackNode struct{ elems []node }
+
+func (n *argPackNode) printLeft(p *printer)  { p.list(n.elems) }
+func (n *argPackNode) printRight(p *printer) {}
+
+/* a template parameter standing for a pack, one element at a time inside an expansion */
+type paramPackNode struct{ elems []node }
+
+func (n *paramPackNode) current(p *printer) node {
+	if p.packMax == noPack {
+		p.packMax = len(n.elems)
+		p.packIdx = 0
+	}
+	if p.packIdx < len(n.elems) {
+		return n.elems[p.packIdx]
+	}
+	return nil
+}
+
+func (n *paramPackNode) printLeft(p *printer) {
+	if e := n.current(p); e != nil {
+		e.printLeft(p)
+	}
+}
+
+func (n *paramPackNode) printRight(p *printer) {
+	if e := n.current(p); e != nil {
+		e.printRight(p)
+	}
+}
+
+type packExpansionNode struct{ child node }
+
+func (n *packExpansionNode) printLeft(p *printer) {
+	saveIdx, saveMax := p.packIdx, p.packMax
+	defer func() { p.packIdx, p.packMax = saveIdx, saveMax }()
+	p.packIdx, p.packMax = 0, noPack
+
+	start := len(p.buf)
+	p.print(n.child)
+	switch p.packMax {
+	case noPack:
+		p.str("...")
+		return
+	case 0:
+		p.buf = p.buf[:start]
+		return
+	}
+	for i := 1; i < p.packMax; i++ {
+		p.str(", ")
+		p.packIdx = i
+		p.print(n.child)
+	}
+}
+func (n *packExpansionNode) printRight(p *printer) {}
+
+// templateParamNode is a T_ reference. Like c++filt it is looked up when
+// printing, in the arguments of the innermost template function being
+// printed, so a substitution of a T_ from a local name's function reads as the
+// outer function's argument. ref is the argument at the point of parsing,
+// filled in later for the forward references of conversion operators.
+/* sizeof... of a template parameter pack, printed as its length once the pack is known */
+type sizeofPackNode struct{ pack *templateParamNode }
+
+func (n *sizeofPackNode) printLeft(p *printer) {
+	if arg, _ := n.pack.lookup(p); arg != nil {
+		switch a := arg.(type) {
+		case *argPackNode:
+			p.str(strconv.Itoa(len(a.elems)))
+			return
+		case *paramPackNode:
+			p.str(strconv.Itoa(len(a.elems)))
+			return
+		}
+	}
+	p.str("sizeof...(")
+	p.print(n.pack)
+	p.str(")")
+}
+func (n *sizeofPackNode) printRight(p *printer) {}
+
+type templateParamNode struct {
+	index int
+	ref   node
+}
+
+/* the argument and the template context it is to be printed in */
+func (n *templateParamNode) lookup(p *printer) (node, [][]node) {
+	if k := len(p.templates); k > 0 && n.index < len(p.templates[k-1]) {
+		return p.templates[k-1][n.index], p.templates[:k-1]
+	}
+	if n.ref == nil {
+		return &nameNode{"T_"}, p.templates
+	}
+	return n.ref, p.templates
+}
+
+func (n *templateParamNode) printLeft(p *printer) {
+	if p.lambda {
+		p.str("auto:" + strconv.Itoa(n.index+1))
+		return
+	}
+	arg, ctx := n.lookup(p)
+	save := p.templates
+	p.templates = ctx
+	arg.printLeft(p)
+	p.templates = save
+}
+
+func (n *templateParamNode) printRight(p *printer) {
+	if p.lambda {
+		return
+	}
+	arg, ctx := n.lookup(p)
+	save := p.templates
+	p.templates = ctx
+	arg.printRight(p)
+	p.templates = save
+}
+
+type lambdaNode struct {
+	params []node
+	num    int
+}
+
+func (n *lambdaNode) printLeft(p *printer) {
+	p.str("{lambda(")
+	save := p.lambda
+	p.lambda = true
+	p.list(n.params)
+	p.lambda = save
+	p.str(")#" + strconv.Itoa(n.num) + "}")
+}
+func (n *lambdaNode) printRight(p *printer) {}
+
+/* expression nodes, printed with c++filt's parenthesisation */
+
+type exprNode struct {
+	op   string
+	args []node
+	kind int
+}
+
+const (
+	exprPrefix    = iota // op arg
+	exprBinary           // arg op arg
+	exprTernary          // a?b:c
+	exprCall             // f(args)
+	exprCast             // (type)(arg)
+	exprNamedCast        // static_cast<type>(arg)
+	exprParens           // op(arg) without the simple operand shortcut
+	exprBraced           // type{args}
+	exprIndex            // a[b]
+)
+
+/* operands that c++filt prints without wrapping parentheses */
+func simpleExpr(n node) bool {
+	switch n.(type) {
+	case *nameNode, *nestedNameNode, *fnParamNode, *initListNode:
+		return true
+	}
+	return false
+}
+
+func (p *printer) subexpr(n node) {
+	if simpleExpr(n) {
+		p.print(n)
+		return
+	}
+	p.str("(")
+	p.print(n)
+	p.str(")")
+}
+
+func (n *exprNode) printLeft(p *printer) {
+	switch n.kind {
+	case exprPrefix:
+		p.str(n.op)
+		if n.op == "::" {
+			p.print(n.args[0])
+		} else {
+			p.subexpr(n.args[0])
+		}
+	case exprParens:
+		p.str(n.op)
+		p.str("(")
+		p.print(n.args[0])
+		p.str(")")
+	case exprBinary:
+		if n.op == ">" {
+			p.str("(")
+		}
+		p.subexpr(n.args[0])
+		p.str(n.op)
+		p.subexpr(n.args[1])
+		if n.op == ">" {
+			p.str(")")
+		}
+	case exprIndex:
+		p.subexpr(n.args[0])
+		p.str("[")
+		p.print(n.args[1])
+		p.str("]")
+	case exprTernary:
+		p.subexpr(n.args[0])
+		p.str("?")
+		p.subexpr(n.args[1])
+		p.str(" : ")
+		p.subexpr(n.args[2])
+	case exprCall:
+		p.subexpr(n.args[0])
+		p.str("(")
+		p.list(n.args[1:])
+		p.str(")")
+	case exprCast:
+		p.str("(")
+		p.print(n.args[0])
+		p.str(")")
+		if len(n.args) == 2 {
+			p.subexpr(n.args[1])
+		} else {
+			p.str("(")
+			p.list(n.args[1:])
+			p.str(")")
+		}
+	case exprNamedCast:
+		p.str(n.op + "<")
+		p.print(n.args[0])
+		p.str(">(")
+		p.print(n.args[1])
+		p.str(")")
+	case exprBraced:
+		p.print(n.args[0])
+		p.str("{")
+		p.list(n.args[1:])
+		p.str("}")
+	}
+}
+func (n *exprNode) printRight(p *printer) {}
+
+type fnParamNode struct{ index int }
+
+func (n *fnParamNode) printLeft(p *printer) {
+	p.str("{parm#" + strconv.Itoa(n.index) + "}")
+}
+func (n *fnParamNode) printRight(p *printer) {}
+
+type initListNode struct{ elems []node }
+
+func (n *initListNode) printLeft(p *printer) {
+	p.str("{")
+	p.list(n.elems)
+	p.str("}")
+}
+func (n *initListNode) printRight(p *printer) {}
+
+type operatorInfo struct {
+	name  string
+	arity int
+}
+
+var operators = map[string]operatorInfo{
+	"aN": {"&=", 2}, "aS": {"=", 2}, "aa": {"&&", 2}, "ad": {"&", 1}, "an": {"&", 2},
+	"at": {"alignof ", 1}, "aw": {"co_await ", 1}, "az": {"alignof ", 1},
+	"cc": {"const_cast", 2}, "cl": {"()", 2}, "cm": {",", 2}, "co": {"~", 1},
+	"dV": {"/=", 2}, "da": {"delete[] ", 1}, "dc": {"dynamic_cast", 2}, "de": {"*", 1},
+	"dl": {"delete ", 1}, "ds": {".*", 2}, "dt": {".", 2}, "dv": {"/", 2},
+	"eO": {"^=", 2}, "eo": {"^", 2}, "eq": {"==", 2}, "ge": {">=", 2}, "gs": {"::", 1}, "gt": {">", 2},
+	"ix": {"[]", 2}, "lS": {"<<=", 2}, "le": {"<=", 2}, "li": {"operator\"\" ", 1}, "ls": {"<<", 2},
+	"lt": {"<", 2}, "mI": {"-=", 2}, "mL": {"*=", 2}, "mi": {"-", 2}, "ml": {"*", 2}, "mm": {"--", 1},
+	"na": {"new[]", 3}, "ne": {"!=", 2}, "ng": {"-", 1}, "nt": {"!", 1}, "nw": {"new", 3}, "nx": {"noexcept", 1},
+	"oR": {"|=", 2}, "oo": {"||", 2}, "or": {"|", 2}, "pL": {"+=", 2}, "pl": {"+", 2}, "pm": {"->*", 2},
+	"pp": {"++", 1}, "ps": {"+", 1}, "pt": {"->", 2}, "qu": {"?", 3}, "rM": {"%=", 2}, "rS": {">>=", 2},
+	"rc": {"reinterpret_cast", 2}, "rm": {"%", 2}, "rs": {">>", 2}, "sc": {"static_cast", 2},
+	"ss": {"<=>", 2}, "st": {"sizeof ", 1}, "sz": {"sizeof ", 1}, "tr": {"throw", 0}, "tw": {"throw ", 1},
+}
+
+var builtinTypes = map[byte]string{
+	'v': "void", 'w': "wchar_t", 'b': "bool", 'c': "char", 'a': "signed char", 'h': "unsigned char",
+	's': "short", 't': "unsigned short", 'i': "int", 'j': "unsigned int", 'l': "long", 'm': "unsigned long",
+	'x': "long long", 'y': "unsigned long long", 'n': "__int128", 'o': "unsigned __int128",
+	'f': "float", 'd': "double", 'e': "long double", 'g': "__float128", 'z': "...",
+}
+
+var builtinDTypes = map[byte]string{
+	'd': "decimal64", 'e': "decimal128", 'f': "decimal32", 'h': "half", 'i': "char32_t", 's': "char16_t",
+	'u': "char8_t", 'a': "auto", 'c': "decltype(auto)", 'n': "decltype(nullptr)",
+}
+
+/* literal suffixes c++filt uses for integer template arguments, "" prints the value bare */
+var literalSuffix = map[string]string{
+	"int": "", "unsigned int": "u", "long": "l", "unsigned long": "ul",
+	"long long": "ll", "unsigned long long": "ull",
+}
+
+type literalNode struct {
+	typ   node
+	value string
+}
+
+func (n *literalNode) printLeft(p *printer) {
+	if b, ok := n.typ.(*builtinNode); ok {
+		if suffix, ok := literalSuffix[b.name]; ok {
+			p.str(strings.Replace(n.value, "n", "-", 1) + suffix)
+			return
+		}
+		if b.name == "bool" && (n.value == "0" || n.value == "1") {
+			p.str(map[string]string{"0": "false", "1": "true"}[n.value])
+			return
+		}
+	}
+	p.str("(")
+	p.print(n.typ)
+	p.str(")")
+	p.str(strings.Replace(n.value, "n", "-", 1))
+}
+func (n *literalNode) printRight(p *printer) {}
+
+/* what parsing a name reports to the encoding that contains it */
+type nameInfo struct {
+	templated    bool // ends in template arguments, so the function type starts with the return type
+	ctorDtorConv bool // constructors, destructors and conversion operators never have one
+	quals        string
+}
+
+type itaniumParser struct {
+	s         string
+	pos       int
+	subs      []node
+	tmpl      []node
+	fwdRefs   []*templateParamNode
+	permitFwd bool
+	depth     int
+
+	oldUnresolved   bool // parse sr<type><name> the pre-2012 way
+	triedUnresolved bool
+	verbose         bool // Ss, Si, So and Sd in full, as c++filt prints them
+}
+
+type parseError struct{}
+
+func (d *itaniumParser) fail() { panic(parseError{}) }
+
+func (d *itaniumParser) peek() byte {
+	if d.pos < len(d.s) {
+		return d.s[d.pos]
+	}
+	return 0
+}
+
+func (d *itaniumParser) peekAt(i int) byte {
+	if d.pos+i < len(d.s) {
+		return d.s[d.pos+i]
+	}
+	return 0
+}
+
+func (d *itaniumParser) consume(prefix string) bool {
+	if strings.HasPrefix(d.s[d.pos:], prefix) {
+		d.pos += len(prefix)
+		return true
+	}
+	return false
+}
+
+func (d *itaniumParser) expect(prefix string) {
+	if !d.consume(prefix) {
+		d.fail()
+	}
+}
+
+func (d *itaniumParser) enter() {
+	d.depth++
+	if d.depth > 256 {
+		d.fail()
+	}
+}
+
+func (d *itaniumParser) leave() { d.depth-- }
+
+func demangleItanium(name string, verbose bool) (string, error) {
+	d := &itaniumParser{s: name, pos: 2, verbose: verbose}
+	n, clones, err := d.parse()
+	if err != nil && d.triedUnresolved {
+		d = &itaniumParser{s: name, pos: 2, oldUnresolved: true, verbose: verbose}
+		n, clones, err = d.parse()
+	}
+	if err != nil {
+		return "", err
+	}
+
+	p := &printer{packMax: noPack}
+	p.print(n)
+	for _, c := range clones {
+		p.str(" [clone " + c + "]")
+	}
+	return string(p.buf), nil
+}
+
+/* the encoding and the suffixes of GCC clones: .constprop.0, .isra.1, .cold */
+func (d *itaniumParser) parse() (n node, clones []string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(parseError); !ok {
+				panic(r)
+			}
+			n, clones, err = nil, nil, ErrInvalid
+		}
+	}()
+
+	n = d.encoding()
+	for d.pos < len(d.s) {
+		if d.s[d.pos] != '.' || d.pos+1 >= len(d.s) || !isLowerDigitUnderscore(d.s[d.pos+1]) {
+			d.fail()
+		}
+		start := d.pos
+		d.pos += 2
+		for d.pos < len(d.s) && isLowerDigitUnderscore(d.s[d.pos]) {
+			d.pos++
+		}
+		for d.pos+1 < len(d.s) && d.s[d.pos] == '.' && isDigit(d.s[d.pos+1]) {
+			d.pos += 2
+			for d.pos < len(d.s) && isDigit(d.s[d.pos]) {
+				d.pos++
+			}
+		}
+		clones = append(clones, d.s[start:d.pos])
+	}
+	return n, clones, nil
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isLowerDigitUnderscore(c byte) bool {
+	return c >= 'a' && c <= 'z' || isDigit(c) || c == '_'
+}
+
+func (d *itaniumParser) number() int {
+	neg := d.consume("n")
+	start := d.pos
+	for isDigit(d.peek()) {
+		d.pos++
+	}
+	if start == d.pos {
+		d.fail()
+	}
+	n, err := strconv.Atoi(d.s[start:d.pos])
+	if err != nil {
+		d.fail()
+	}
+	if neg {
+		return -n
+	}
+	return n
+}
+
+/* <seq-id> in base 36, followed by _ */
+func (d *itaniumParser) seqID() int {
+	if d.consume("_") {
+		return 0
+	}
+	n := 0
+	for {
+		c := d.peek()
+		switch {
+		case isDigit(c):
+			n = n*36 + int(c-'0')
+		case c >= 'A' && c <= 'Z':
+			n = n*36 + int(c-'A') + 10
+		case c == '_':
+			d.pos++
+			return n + 1
+		default:
+			d.fail()
+		}
+		d.pos++
+	}
+}
+
+func (d *itaniumParser) atEncodingEnd() bool {
+	c := d.peek()
+	return c == 0 || c == 'E' || c == '.'
+}
+
+func (d *itaniumParser) encoding() node {
+	d.enter()
+	defer d.leave()
+
+	if c := d.peek(); c == 'T' || c == 'G' {
+		return d.specialName()
+	}
+
+	saveFwd := d.permitFwd
+	d.permitFwd = true
+	name, info := d.name(true)
+	d.permitFwd = saveFwd
+	d.resolveFwdRefs()
+
+	if d.atEncodingEnd() {
+		return name
+	}
+
+	var ret node
+	if info.templated && !info.ctorDtorConv {
+		ret = d.typ()
+	}
+	var params []node
+	if c := d.peekAt(1); d.peek() == 'v' && (c == 0 || c == 'E' || c == '.') {
+		d.pos++
+	} else {
+		for !d.atEncodingEnd() {
+			params = append(params, d.typ())
+		}
+	}
+	enc := &encodingNode{ret: ret, name: name, params: params, quals: info.quals}
+	if info.templated {
+		enc.tmpl = append([]node{}, d.tmpl...)
+	}
+	return enc
+}
+
+func (d *itaniumParser) resolveFwdRefs() {
+	for _, r := range d.fwdRefs {
+		if r.index >= len(d.tmpl) {
+			d.fail()
+		}
+		r.ref = d.tmpl[r.index]
+	}
+	d.fwdRefs = nil
+}
+
+func (d *itaniumParser) callOffset() {
+	switch {
+	case d.consume("h"):
+		d.number()
+	case d.consume("v"):
+		d.number()
+		d.expect("_")
+		d.number()
+	default:
+		d.fail()
+	}
+	d.expect("_")
+}
+
+func (d *itaniumParser) specialName() node {
+	switch {
+	case d.consume("TV"):
+		return &specialNameNode{"vtable for ", d.typ()}
+	case d.consume("TT"):
+		return &specialNameNode{"VTT for ", d.typ()}
+	case d.consume("TI"):
+		return &specialNameNode{"typeinfo for ", d.typ()}
+	case d.consume("TS"):
+		return &specialNameNode{"typeinfo name for ", d.typ()}
+	case d.consume("TH"):
+		n, _ := d.name(false)
+		return &specialNameNode{"TLS init function for ", n}
+	case d.consume("TW"):
+		n, _ := d.name(false)
+		return &specialNameNode{"TLS wrapper function for ", n}
+	case d.consume("Th"):
+		d.number()
+		d.expect("_")
+		return &specialNameNode{"non-virtual thunk to ", d.encoding()}
+	case d.consume("Tv"):
+		d.number()
+		d.expect("_")
+		d.number()
+		d.expect("_")
+		return &specialNameNode{"virtual thunk to ", d.encoding()}
+	case d.consume("Tc"):
+		d.callOffset()
+		d.callOffset()
+		return &specialNameNode{"covariant return thunk to ", d.encoding()}
+	case d.consume("TC"):
+		first := d.typ()
+		d.number()
+		d.expect("_")
+		return &ctorVtableNode{first, d.typ()}
+	case d.consume("GV"):
+		n, _ := d.name(false)
+		return &specialNameNode{"guard variable for ", n}
+	case d.consume("GR"):
+		n, _ := d.name(false)
+		/* the _ may have been taken as the discriminator of a local name already */
+		idx := 0
+		if isDigit(d.peek()) {
+			idx = d.number()
+		}
+		d.consume("_")
+		return &specialNameNode{"reference temporary #" + strconv.Itoa(idx) + " for ", n}
+	case d.consume("GTt"):
+		return &specialNameNode{"transaction clone for ", d.encoding()}
+	case d.consume("GTn"):
+		return &specialNameNode{"non-transaction clone for ", d.encoding()}
+	case d.consume("GA"):
+		return &specialNameNode{"hidden alias for ", d.encoding()}
+	}
+	d.fail()
+	return nil
+}
+
+/* <name>; tag makes template arguments found here the ones T_ refers to */
+func (d *itaniumParser) name(tag bool) (node, nameInfo) {
+	d.enter()
+	defer d.leave()
+
+	switch d.peek() {
+	case 'N':
+		return d.nestedName(tag)
+	case 'Z':
+		return d.localName(tag)
+	}
+
+	var n node
+	var info nameInfo
+	isSub := false
+	switch {
+	case d.consume("St"):
+		d.consume("L")
+		var u node
+		u, info = d.unqualifiedName(nil)
+		n = &nestedNameNode{&nameNode{"std"}, u}
+	case d.peek() == 'S':
+		n = d.substitution(false)
+		isSub = true
+	default:
+		d.consume("L")
+		n, info = d.unqualifiedName(nil)
+	}
+
+	if d.peek() == 'I' {
+		if !isSub {
+			d.subs = append(d.subs, n)
+		}
+		args := d.templateArgs(tag)
+		return &nameWithArgsNode{n, args}, nameInfo{templated: true}
+	}
+	if isSub {
+		d.fail()
+	}
+	return n, info
+}
+
+func (d *itaniumParser) localName(tag bool) (node, nameInfo) {
+	d.expect("Z")
+	saveTmpl := d.tmpl
+	enc := d.encoding()
+	d.tmpl = saveTmpl
+	d.expect("E")
+	/* c++filt leaves out the return type of the enclosing function */
+	if f, ok := enc.(*encodingNode); ok {
+		f.ret = nil
+	}
+
+	if d.consume("s") {
+		d.discriminator()
+		return &localNameNode{enc, &nameNode{"string literal"}}, nameInfo{}
+	}
+	if d.consume("d") {
+		idx := 0
+		if d.peek() != '_' {
+			idx = d.number() + 1
+		}
+		d.expect("_")
+		n, info := d.name(tag)
+		arg := &nameNode{"{default arg#" + strconv.Itoa(idx+1) + "}"}
+		return &localNameNode{enc, &nestedNameNode{arg, n}}, info
+	}
+	n, info := d.name(tag)
+	/* lambdas and unnamed types carry their number already */
+	if u, ok := n.(*nameNode); !ok || !strings.HasPrefix(u.name, "{unnamed type#") {
+		if _, ok := n.(*lambdaNode); !ok {
+			d.discriminator()
+		}
+	}
+	return &localNameNode{enc, n}, info
+}
+
+func (d *itaniumParser) discriminator() {
+	if d.peek() != '_' {
+		return
+	}
+	d.pos++
+	long := d.consume("_")
+	n := 0
+	if isDigit(d.peek()) {
+		n = d.number()
+	}
+	if long && n >= 10 {
+		d.expect("_")
+	}
+}
+
+func (d *itaniumParser) nestedName(tag bool) (node, nameInfo) {
+	d.expect("N")
+	var info nameInfo
+	for {
+		switch {
+		case d.consume("r"):
+			info.quals += " restrict"
+			continue
+		case d.consume("V"):
+			info.quals += " volatile"
+			continue
+		case d.consume("K"):
+			info.quals += " const"
+			continue
+		}
+		break
+	}
+	cv := info.quals
+	info.quals = ""
+	/* c++filt orders member function qualifiers const, volatile, restrict */
+	for _, q := range []string{" const", " volatile", " restrict"} {
+		if strings.Contains(cv, q) {
+			info.quals += q
+		}
+	}
+	ref := ""
+	if d.consume("R") {
+		ref = " &"
+	} else if d.consume("O") {
+		ref = " &&"
+	}
+
+	soFar, pinfo := d.prefix(tag, true)
+	d.expect("E")
+	info.templated, info.ctorDtorConv = pinfo.templated, pinfo.ctorDtorConv
+	info.quals += ref
+	return soFar, info
+}
+
+// prefix parses the components of a nested name up to its E. With subst
+// each of them but the whole name is a substitution candidate.
+func (d *itaniumParser) prefix(tag, subst bool) (node, nameInfo) {
+	var info nameInfo
+	var soFar node
+	push := func(n node) {
+		if soFar == nil {
+			soFar = n
+		} else {
+			soFar = &nestedNameNode{soFar, n}
+		}
+	}
+
+	for d.peek() != 'E' {
+		if d.pos >= len(d.s) {
+			d.fail()
+		}
+		/* a constructor template keeps its flag through the template arguments */
+		info.templated = false
+		if d.peek() != 'I' {
+			info.ctorDtorConv = false
+		}
+		d.consume("L")
+		switch c := d.peek(); {
+		case c == 'M':
+			/* closure types defined in data member initialisers */
+			if soFar == nil {
+				d.fail()
+			}
+			d.pos++
+			continue
+		case c == 'S' && d.peekAt(1) == 't':
+			if soFar != nil {
+				d.fail()
+			}
+			d.pos += 2
+			soFar = &nameNode{"std"}
+			continue
+		case c == 'S':
+			if soFar != nil {
+				d.fail()
+			}
+			soFar = d.substitution(true)
+			continue
+		case c == 'T':
+			if soFar != nil {
+				d.fail()
+			}
+			soFar = d.templateParam()
+		case c == 'D' && (d.peekAt(1) == 't' || d.peekAt(1) == 'T'):
+			if soFar != nil {
+				d.fail()
+			}
+			soFar = d.decltype()
+		case c == 'I':
+			if soFar == nil {
+				d.fail()
+			}
+			soFar = &nameWithArgsNode{soFar, d.templateArgs(tag)}
+			info.templated = true
+		default:
+			var u node
+			var uinfo nameInfo
+			u, uinfo = d.unqualifiedName(soFar)
+			info.ctorDtorConv = uinfo.ctorDtorConv
+			push(u)
+		}
+		if subst {
+			d.subs = append(d.subs, soFar)
+		}
+	}
+	if soFar == nil {
+		d.fail()
+	}
+	if subst {
+		d.subs = d.subs[:len(d.subs)-1]
+	}
+	return soFar, info
+}
+
+/* the name a constructor or destructor of scope repeats */
+func baseName(n node) string {
+	switch t := n.(type) {
+	case *nameNode:
+		return t.name
+	case *nestedNameNode:
+		/* as in c++filt, an unnamed type's members repeat the last name that had one */
+		if u, ok := t.name.(*nameNode); ok && strings.HasPrefix(u.name, "{unnamed type#") {
+			return baseName(t.qual)
+		}
+		return baseName(t.name)
+	case *nameWithArgsNode:
+		return baseName(t.name)
+	case *specialSubNode:
+		return t.base
+	case *abiTagNode:
+		return baseName(t.base)
+	case *localNameNode:
+		return baseName(t.entity)
+	case *templateParamNode:
+		if t.ref != nil {
+			return baseName(t.ref)
+		}
+	}
+	p := &printer{packMax: noPack}
+	p.print(n)
+	return string(p.buf)
+}
+
+func (d *itaniumParser) unqualifiedName(scope node) (node, nameInfo) {
+	var n node
+	var info nameInfo
+	c := d.peek()
+	switch {
+	case isDigit(c):
+		n = d.sourceName()
+	case c == 'C' && scope != nil:
+		d.pos++
+		d.consume("I")
+		if !strings.ContainsRune("12345", rune(d.peek())) {
+			d.fail()
+		}
+		d.pos++
+		if d.s[d.pos-2] == 'I' {
+			d.typ()
+		}
+		n = &ctorDtorNode{base: baseName(scope)}
+		info.ctorDtorConv = true
+	case c == 'D' && scope != nil && strings.ContainsRune("012345", rune(d.peekAt(1))):
+		d.pos += 2
+		n = &ctorDtorNode{base: baseName(scope), dtor: true}
+		info.ctorDtorConv = true
+	case c == 'U':
+		n = d.unnamedTypeName()
+	case c == 'D' && d.peekAt(1) == 'C':
+		d.pos += 2
+		var names []string
+		for !d.consume("E") {
+			names = append(names, d.sourceName().(*nameNode).name)
+		}
+		n = &nameNode{"[" + strings.Join(names, ", ") + "]"}
+	case c >= 'a' && c <= 'z':
+		n, info.ctorDtorConv = d.operatorName()
+	default:
+		d.fail()
+	}
+	for d.consume("B") {
+		n = &abiTagNode{n, d.sourceName().(*nameNode).name}
+	}
+	return n, info
+}
+
+func (d *itaniumParser) sourceName() node {
+	l := d.number()
+	if l <= 0 || d.pos+l > len(d.s) {
+		d.fail()
+	}
+	id := d.s[d.pos : d.pos+l]
+	d.pos += l
+	if strings.HasPrefix(id, "_GLOBAL_") && len(id) > 9 && strings.ContainsRune("._$", rune(id[8])) && id[9] == 'N' {
+		return &nameNode{"(anonymous namespace)"}
+	}
+	return &nameNode{id}
+}
+
+func (d *itaniumParser) unnamedTypeName() node {
+	switch {
+	case d.consume("Ut"):
+		n := 1
+		if d.peek() != '_' {
+			n = d.number() + 2
+		}
+		d.expect("_")
+		return &nameNode{"{unnamed type#" + strconv.Itoa(n) + "}"}
+	case d.consume("Ul"):
+		var params []node
+		if d.peek() == 'v' && d.peekAt(1) == 'E' {
+			d.pos++
+		} else {
+			for d.peek() != 'E' {
+				params = append(params, d.typ())
+			}
+		}
+		d.expect("E")
+		n := 1
+		if d.peek() != '_' {
+			n = d.number() + 2
+		}
+		d.expect("_")
+		return &lambdaNode{params, n}
+	}
+	d.fail()
+	return nil
+}
+
+/* operator names print as "operator+", with a space before words: "operator new" */
+func (d *itaniumParser) operatorName() (node, bool) {
+	if d.consume("cv") {
+		save := d.permitFwd
+		t := d.typ()
+		d.permitFwd = save
+		return &convOpNode{t}, true
+	}
+	if d.consume("li") {
+		return &nameNode{"operator\"\" " + d.sourceName().(*nameNode).name}, false
+	}
+	if d.peek() == 'v' && isDigit(d.peekAt(1)) {
+		d.pos += 2
+		return &nameNode{"operator " + d.sourceName().(*nameNode).name}, false
+	}
+	if d.pos+2 > len(d.s) {
+		d.fail()
+	}
+	op, ok := operators[d.s[d.pos:d.pos+2]]
+	if !ok {
+		d.fail()
+	}
+	d.pos += 2
+	name := strings.TrimSuffix(op.name, " ")
+	if name[0] >= 'a' && name[0] <= 'z' {
+		return &nameNode{"operator " + name}, false
+	}
+	return &nameNode{"operator" + name}, false
+}
+
+type convOpNode struct{ typ node }
+
+func (n *convOpNode) printLeft(p *printer) {
+	p.str("operator ")
+	p.print(n.typ)
+}
+func (n *convOpNode) printRight(p *printer) {}
+
+// substitution reads S_, S<seq-id>_ or one of the std abbreviations.
+// Outside verbose mode Ss, Si, So and Sd print as std::string and the like,
+// except as the prefix of a constructor or destructor, which keeps the full
+// class name.
+func (d *itaniumParser) substitution(prefix bool) node {
+	d.expect("S")
+	if c := d.peek(); c >= 'a' && c <= 'z' {
+		d.pos++
+		if !d.verbose && !(prefix && (d.peek() == 'C' || d.peek() == 'D')) {
+			switch c {
+			case 's':
+				return &specialSubNode{"std::string", "basic_string"}
+			case 'i':
+				return &specialSubNode{"std::istream", "basic_istream"}
+			case 'o':
+				return &specialSubNode{"std::ostream", "basic_ostream"}
+			case 'd':
+				return &specialSubNode{"std::iostream", "basic_iostream"}
+			}
+		}
+		switch c {
+		case 'a':
+			return &specialSubNode{"std::allocator", "allocator"}
+		case 'b':
+			return &specialSubNode{"std::basic_string", "basic_string"}
+		case 's':
+			return &specialSubNode{"std::basic_string<char, std::char_traits<char>, std::allocator<char> >", "basic_string"}
+		case 'i':
+			return &specialSubNode{"std::basic_istream<char, std::char_traits<char> >", "basic_istream"}
+		case 'o':
+			return &specialSubNode{"std::basic_ostream<char, std::char_traits<char> >", "basic_ostream"}
+		case 'd':
+			return &specialSubNode{"std::basic_iostream<char, std::char_traits<char> >", "basic_iostream"}
+		}
+		d.fail()
+	}
+	id := d.seqID()
+	if id >= len(d.subs) {
+		d.fail()
+	}
+	return d.subs[id]
+}
+
+func (d *itaniumParser) templateParam() node {
+	d.expect("T")
+	idx := 0
+	if !d.consume("_") {
+		idx = d.number() + 1
+		d.expect("_")
+	}
+	if idx >= len(d.tmpl) {
+		if d.permitFwd {
+			r := &templateParamNode{index: idx}
+			d.fwdRefs = append(d.fwdRefs, r)
+			return r
+		}
+		d.fail()
+	}
+	return &templateParamNode{idx, d.tmpl[idx]}
+}
+
+func (d *itaniumParser) templateArgs(tag bool) *templateArgsNode {
+	d.expect("I")
+	var args []node
+	if tag {
+		d.tmpl = nil
+	}
+	for !d.consume("E") {
+		if d.pos >= len(d.s) {
+			d.fail()
+		}
+		a := d.templateArg()
+		args = append(args, a)
+		if tag {
+			if pack, ok := a.(*argPackNode); ok {
+				d.tmpl = append(d.tmpl, &paramPackNode{pack.elems})
+			} else {
+				d.tmpl = append(d.tmpl, a)
+			}
+		}
+	}
+	return &templateArgsNode{args}
+}
+
+func (d *itaniumParser) templateArg() node {
+	switch d.peek() {
+	case 'X':
+		d.pos++
+		e := d.expr()
+		d.expect("E")
+		return e
+	case 'J':
+		d.pos++
+		var elems []node
+		for !d.consume("E") {
+			if d.pos >= len(d.s) {
+				d.fail()
+			}
+			elems = append(elems, d.templateArg())
+		}
+		return &argPackNode{elems}
+	case 'L':
+		return d.literal()
+	}
+	return d.typ()
+}
+
+func (d *itaniumParser) literal() node {
+	d.expect("L")
+	if d.consume("_Z") || d.consume("Z") {
+		enc := d.encoding()
+		d.expect("E")
+		return enc
+	}
+	t := d.typ()
+	start := d.pos
+	for d.pos < len(d.s) && d.s[d.pos] != 'E' {
+		d.pos++
+	}
+	value := d.s[start:d.pos]
+	d.expect("E")
+	return &literalNode{t, value}
+}
+
+func (d *itaniumParser) decltype() node {
+	d.expect("D")
+	if !d.consume("t") && !d.consume("T") {
+		d.fail()
+	}
+	e := d.expr()
+	d.expect("E")
+	return &exprNode{op: "decltype ", args: []node{e}, kind: exprParens}
+}
+
+func (d *itaniumParser) typ() node {
+	d.enter()
+	defer d.leave()
+
+	var n node
+	c := d.peek()
+	if name, ok := builtinTypes[c]; ok {
+		d.pos++
+		return &builtinNode{name}
+	}
+
+	switch c {
+	case 'r', 'V', 'K':
+		quals := ""
+		for {
+			switch {
+			case d.consume("r"):
+				quals = " restrict" + quals
+				continue
+			case d.consume("V"):
+				quals = " volatile" + quals
+				continue
+			case d.consume("K"):
+				quals = " const" + quals
+				continue
+			}
+			break
+		}
+		if isFunctionTypeStart(d) {
+			/* cv-qualified function types only appear as member function types,
+			   the unqualified type is no substitution candidate */
+			f := d.functionType().(*funcTypeNode)
+			f.quals = quals + f.quals
+			n = f
+			break
+		}
+		child := d.typ()
+		if q, ok := child.(*qualNode); ok {
+			n = &qualNode{q.child, q.quals + quals}
+		} else {
+			n = &qualNode{child, quals}
+		}
+	case 'u':
+		d.pos++
+		n = d.sourceName()
+		if d.peek() == 'I' {
+			n = &nameWithArgsNode{n, d.templateArgs(false)}
+		}
+	case 'D':
+		if isFunctionTypeStart(d) {
+			n = d.functionType()
+			break
+		}
+		n = d.dType()
+		if n == nil {
+			return nil
+		}
+		if b, ok := n.(*builtinNode); ok {
+			return b
+		}
+	case 'F':
+		n = d.functionType()
+	case 'A':
+		n = d.arrayType()
+	case 'M':
+		d.pos++
+		class := d.typ()
+		member := d.typ()
+		n = &ptrToMemberNode{class, member}
+	case 'T':
+		n = d.templateParam()
+		if d.peek() == 'I' {
+			d.subs = append(d.subs, n)
+			n = &nameWithArgsNode{n, d.templateArgs(false)}
+		}
+	case 'P':
+		d.pos++
+		n = &pointerNode{d.typ(), "*"}
+	case 'R':
+		d.pos++
+		n = &refNode{d.typ(), false}
+	case 'O':
+		d.pos++
+		n = &refNode{d.typ(), true}
+	case 'C':
+		d.pos++
+		n = &pointerNode{d.typ(), " _Complex"}
+	case 'G':
+		d.pos++
+		n = &pointerNode{d.typ(), " _Imaginary"}
+	case 'S':
+		if d.peekAt(1) != 't' {
+			n = d.substitution(false)
+			if d.peek() != 'I' {
+				return n
+			}
+			n = &nameWithArgsNode{n, d.templateArgs(false)}
+			break
+		}
+		n, _ = d.name(false)
+	default:
+		n, _ = d.name(false)
+	}
+	d.subs = append(d.subs, n)
+	return n
+}
+
+func (d *itaniumParser) dType() node {
+	d.expect("D")
+	c := d.peek()
+	if name, ok := builtinDTypes[c]; ok {
+		d.pos++
+		return &builtinNode{name}
+	}
+	switch c {
+	case 'F':
+		d.pos++
+		bits := d.number()
+		suffix := ""
+		if d.consume("x") {
+			suffix = "x"
+		} else {
+			d.expect("_")
+		}
+		return &builtinNode{"_Float" + strconv.Itoa(bits) + suffix}
+	case 'p':
+		d.pos++
+		return &packExpansionNode{d.typ()}
+	case 't', 'T':
+		d.pos--
+		return d.decltype()
+	case 'v':
+		d.pos++
+		var dim node
+		if isDigit(d.peek()) {
+			dim = &nameNode{strconv.Itoa(d.number())}
+		} else {
+			d.expect("_")
+			dim = d.expr()
+		}
+		d.expect("_")
+		return &vectorNode{d.typ(), dim}
+	}
+	d.fail()
+	return nil
+}
+
+/* a function type may be introduced by Do, DO <expr> E, Dw <types> E or Dx */
+func isFunctionTypeStart(d *itaniumParser) bool {
+	return d.peek() == 'F' || d.peek() == 'D' && strings.ContainsRune("oOwx", rune(d.peekAt(1)))
+}
+
+func (d *itaniumParser) exceptionSpec() node {
+	switch {
+	case d.consume("Do"):
+		return &nameNode{"noexcept"}
+	case d.consume("DO"):
+		e := d.expr()
+		d.expect("E")
+		return &exprNode{op: "noexcept", kind: exprParens, args: []node{e}}
+	case d.consume("Dw"):
+		var types []node
+		for !d.consume("E") {
+			types = append(types, d.typ())
+		}
+		return &exprNode{op: "throw", kind: exprParens, args: []node{&argPackNode{types}}}
+	case d.consume("Dx"):
+		return &nameNode{"transaction_safe"}
+	}
+	return nil
+}
+
+func (d *itaniumParser) functionType() node {
+	spec := d.exceptionSpec()
+	d.expect("F")
+	d.consume("Y")
+	ret := d.typ()
+	var params []node
+	quals := ""
+	for {
+		switch {
+		case d.consume("E"):
+			return &funcTypeNode{ret, params, quals, spec}
+		case d.consume("RE"):
+			return &funcTypeNode{ret, params, " &", spec}
+		case d.consume("OE"):
+			return &funcTypeNode{ret, params, " &&", spec}
+		case d.peek() == 'v' && d.peekAt(1) == 'E' && params == nil:
+			d.pos++
+			continue
+		case d.pos >= len(d.s):
+			d.fail()
+		}
+		params = append(params, d.typ())
+	}
+}
+
+func (d *itaniumParser) arrayType() node {
+	d.expect("A")
+	var dim node
+	switch {
+	case isDigit(d.peek()):
+		dim = &nameNode{strconv.Itoa(d.number())}
+	case d.peek() != '_':
+		dim = d.expr()
+	}
+	d.expect("_")
+	return &arrayNode{d.typ(), dim}
+}
+
+func (d *itaniumParser) fnParam() node {
+	if d.consume("fp") {
+		for d.consume("r") || d.consume("V") || d.consume("K") {
+		}
+		idx := 1
+		if d.peek() != '_' {
+			idx = d.number() + 2
+		}
+		d.expect("_")
+		return &fnParamNode{idx}
+	}
+	d.expect("fL")
+	d.number()
+	d.expect("p")
+	for d.consume("r") || d.consume("V") || d.consume("K") {
+	}
+	idx := 1
+	if d.peek() != '_' {
+		idx = d.number() + 2
+	}
+	d.expect("_")
+	return &fnParamNode{idx}
+}
+
+func (d *itaniumParser) exprList(end string) []node {
+	var list []node
+	for !d.consume(end) {
+		if d.pos >= len(d.s) {
+			d.fail()
+		}
+		list = append(list, d.expr())
+	}
+	return list
+}
+
+func (d *itaniumParser) expr() node {
+	d.enter()
+	defer d.leave()
+
+	switch c := d.peek(); {
+	case c == 'L':
+		return d.literal()
+	case c == 'T':
+		return d.templateParam()
+	case c == 'f' && (d.peekAt(1) == 'p' || d.peekAt(1) == 'L'):
+		return d.fnParam()
+	case isDigit(c):
+		return d.unresolvedBase()
+	}
+
+	if d.pos+2 > len(d.s) {
+		d.fail()
+	}
+	code := d.s[d.pos : d.pos+2]
+	switch code {
+	case "sr":
+		d.pos += 2
+		return d.unresolvedName()
+	case "cl":
+		d.pos += 2
+		return &exprNode{kind: exprCall, args: d.exprList("E")}
+	case "cv":
+		d.pos += 2
+		t := d.typ()
+		if d.consume("_") {
+			return &exprNode{kind: exprCast, args: append([]node{t}, d.exprList("E")...)}
+		}
+		return &exprNode{kind: exprCast, args: []node{t, d.expr()}}
+	case "sc", "dc", "cc", "rc":
+		d.pos += 2
+		t := d.typ()
+		return &exprNode{op: operators[code].name, kind: exprNamedCast, args: []node{t, d.expr()}}
+	case "st", "at":
+		d.pos += 2
+		return &exprNode{op: operators[code].name, kind: exprParens, args: []node{d.typ()}}
+	case "sZ":
+		d.pos += 2
+		var arg node
+		if d.peek() == 'T' {
+			return &sizeofPackNode{d.templateParam().(*templateParamNode)}
+		}
+		arg = d.fnParam()
+		return &exprNode{op: "sizeof...", kind: exprParens, args: []node{arg}}
+	case "sP":
+		d.pos += 2
+		var args []node
+		for !d.consume("E") {
+			args = append(args, d.templateArg())
+		}
+		return &exprNode{op: "sizeof...", kind: exprParens, args: []node{&argPackNode{args}}}
+	case "te", "ti":
+		d.pos += 2
+		var arg node
+		if code == "ti" {
+			arg = d.typ()
+		} else {
+			arg = d.expr()
+		}
+		return &exprNode{op: "typeid ", kind: exprParens, args: []node{arg}}
+	case "tr":
+		d.pos += 2
+		return &nameNode{"throw"}
+	case "nx":
+		d.pos += 2
+		return &exprNode{op: "noexcept ", kind: exprParens, args: []node{d.expr()}}
+	case "il":
+		d.pos += 2
+		return &initListNode{d.exprList("E")}
+	case "tl":
+		d.pos += 2
+		t := d.typ()
+		return &exprNode{kind: exprBraced, args: append([]node{t}, d.exprList("E")...)}
+	case "dt", "pt":
+		d.pos += 2
+		l := d.expr()
+		return &exprNode{op: operators[code].name, kind: exprBinary, args: []node{l, d.unresolvedBase()}}
+	case "ix":
+		d.pos += 2
+		l := d.expr()
+		return &exprNode{kind: exprIndex, args: []node{l, d.expr()}}
+	case "gs":
+		d.pos += 2
+		return &exprNode{op: "::", kind: exprPrefix, args: []node{d.expr()}}
+	case "sp":
+		d.pos += 2
+		return &packExpansionNode{d.expr()}
+	case "on", "dn":
+		return d.unresolvedBase()
+	}
+
+	op, ok := operators[code]
+	if !ok {
+		d.fail()
+	}
+	d.pos += 2
+	switch op.arity {
+	case 1:
+		/* pp_ and mm_ are the prefix forms */
+		if (code == "pp" || code == "mm") && d.consume("_") {
+			return &exprNode{op: op.name, kind: exprPrefix, args: []node{d.expr()}}
+		}
+		e := d.expr()
+		if code == "pp" || code == "mm" {
+			return &exprNode{op: op.name, kind: exprBinary, args: []node{e, &nameNode{""}}}
+		}
+		if code == "sz" || code == "az" {
+			return &exprNode{op: op.name, kind: exprParens, args: []node{e}}
+		}
+		/* the address of a member function is written without its parameters */
+		if f, ok := e.(*encodingNode); ok && code == "ad" && f.quals == "" {
+			if _, ok := f.name.(*nestedNameNode); ok {
+				e = f.name
+			}
+		}
+		return &exprNode{op: op.name, kind: exprPrefix, args: []node{e}}
+	case 2:
+		l := d.expr()
+		return &exprNode{op: op.name, kind: exprBinary, args: []node{l, d.expr()}}
+	case 3:
+		if code != "qu" {
+			d.fail()
+		}
+		a := d.expr()
+		b := d.expr()
+		return &exprNode{kind: exprTernary, args: []node{a, b, d.expr()}}
+	}
+	d.fail()
+	return nil
+}
+
+/* <unresolved-name> after sr: a scope then the member it names */
+// unresolvedName follows sr. sr1AE1x (A::x) is ambiguous with the older
+// sr1A1x, so like c++filt the newer form is tried first and the whole name
+// parsed again with the older one when that fails.
+func (d *itaniumParser) unresolvedName() node {
+	var scope node
+	if c := d.peek(); !d.oldUnresolved && (isDigit(c) || c >= 'a' && c <= 'z' || c == 'C' || c == 'U' || c == 'L') {
+		d.triedUnresolved = true
+		scope, _ = d.prefix(false, false)
+		d.expect("E")
+	} else {
+		scope = d.typ()
+	}
+	/* c++filt parenthesises a qualified template-id in expressions, so the arguments go outside */
+	base := d.unresolvedBase()
+	if t, ok := base.(*nameWithArgsNode); ok {
+		return &nameWithArgsNode{&nestedNameNode{scope, t.name}, t.args}
+	}
+	return &nestedNameNode{scope, base}
+}
+
+func (d *itaniumParser) unresolvedType() node {
+	switch d.peek() {
+	case 'T':
+		n := d.templateParam()
+		d.subs = append(d.subs, n)
+		return n
+	case 'D':
+		n := d.decltype()
+		d.subs = append(d.subs, n)
+		return n
+	case 'S':
+		return d.substitution(false)
+	}
+	n, _ := d.name(false)
+	return n
+}
+
+/* <base-unresolved-name>: a simple name, operator or destructor, with optional template arguments */
+func (d *itaniumParser) unresolvedBase() node {
+	var n node
+	switch {
+	case d.consume("on"):
+		n, _ = d.operatorName()
+	case d.consume("dn"):
+		if isDigit(d.peek()) {
+			n = &nameNode{"~" + d.sourceName().(*nameNode).name}
+		} else {
+			t := d.unresolvedType()
+			p := &printer{packMax: noPack}
+			p.print(t)
+			n = &nameNode{"~" + string(p.buf)}
+		}
+	default:
+		n = d.sourceName()
+	}
+	if d.peek() == 'I' {
+		n = &nameWithArgsNode{n, d.templateArgs(false)}
+	}
+	return n
+}
@@ -0,0 +1,760 @@
+package demangle
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+/* legacy escapes inside identifiers, $LT$ for < and so on */
+var rustLegacyEscapes = map[string]byte{
+	"SP": '@', "BP": '*', "RF": '&', "LT": '<', "GT": '>', "LP": '(', "RP": ')', "C": ',',
+}
+
+// demangleRustLegacy recognises the old Rust mangling: an Itanium style
+// nested name whose last segment is a 17h hash of 16 hex digits. Anything
+// else is left to the C++ demangler. The hash is printed only when verbose.
+func demangleRustLegacy(name string, verbose bool) (string, bool) {
+	sym := name[3:]
+	for i := 0; i < len(sym); i++ {
+		c := sym[i]
+		if c != '_' && c != '$' && c != '.' && c != ':' && !isAlnum(c) {
+			return "", false
+		}
+	}
+	if !strings.HasSuffix(sym, "E") {
+		return "", false
+	}
+	sym = sym[:len(sym)-1]
+	if len(sym) <= 19 || !strings.HasPrefix(sym[len(sym)-19:], "17h") {
+		return "", false
+	}
+
+	var idents []string
+	for pos := 0; pos < len(sym); {
+		start := pos
+		for pos < len(sym) && isDigit(sym[pos]) {
+			pos++
+		}
+		if start == pos || sym[start] == '0' && pos-start > 1 {
+			return "", false
+		}
+		l, err := strconv.Atoi(sym[start:pos])
+		if err != nil || l > len(sym)-pos {
+			return "", false
+		}
+		idents = append(idents, sym[pos:pos+l])
+		pos += l
+	}
+	if !isLegacyHash(idents[len(idents)-1]) {
+		return "", false
+	}
+
+	if !verbose {
+		idents = idents[:len(idents)-1]
+	}
+	var out strings.Builder
+	for i, id := range idents {
+		if i > 0 {
+			out.WriteString("::")
+		}
+		writeLegacyIdent(&out, id)
+	}
+	return out.String(), true
+}
+
+/* h and 16 lower case hex digits, with enough distinct digits to not be a word */
+func isLegacyHash(id string) bool {
+	if len(id) != 17 || id[0] != 'h' {
+		return false
+	}
+	seen := 0
+	for i := 1; i < len(id); i++ {
+		v := hexNibble(id[i])
+		if v < 0 {
+			return false
+		}
+		seen |= 1 << v
+	}
+	n := 0
+	for ; seen != 0; seen &= seen - 1 {
+		n++
+	}
+	return n >= 5
+}
+
+func hexNibble(c byte) int {
+	switch {
+	case isDigit(c):
+		return int(c - '0')
+	case c >= 'a' && c <= 'f':
+		return int(c-'a') + 10
+	}
+	return -1
+}
+
+func isAlnum(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func writeLegacyIdent(out *strings.Builder, id string) {
+	/* the mangler puts _ in front of an escape so the identifier starts like one */
+	if strings.HasPrefix(id, "_$") {
+		id = id[1:]
+	}
+	for len(id) > 0 {
+		switch id[0] {
+		case '$':
+			c, n := legacyEscape(id)
+			if n == 0 {
+				out.WriteString(id)
+				return
+			}
+			out.WriteByte(c)
+			id = id[n:]
+		case '.':
+			if strings.HasPrefix(id, "..") {
+				out.WriteString("::")
+				id = id[2:]
+			} else {
+				out.WriteByte('.')
+				id = id[1:]
+			}
+		default:
+			n := strings.IndexAny(id, "$.")
+			if n < 0 {
+				n = len(id)
+			}
+			out.WriteString(id[:n])
+			id = id[n:]
+		}
+	}
+}
+
+/* decodes the escape at the start of e, returning its length or 0 */
+func legacyEscape(e string) (byte, int) {
+	end := strings.IndexByte(e[1:], '$')
+	if end < 1 {
+		return 0, 0
+	}
+	code := e[1 : end+1]
+	if c, ok := rustLegacyEscapes[code]; ok {
+		return c, end + 2
+	}
+	if len(code) == 3 && code[0] == 'u' {
+		hi, lo := hexNibble(code[1]), hexNibble(code[2])
+		if hi < 0 || lo < 0 || hi > 7 || hi<<4|lo < 0x20 {
+			return 0, 0
+		}
+		return byte(hi<<4 | lo), end + 2
+	}
+	return 0, 0
+}
+
+var rustBasicTypes = map[byte]string{
+	'b': "bool", 'c': "char", 'e': "str", 'u': "()", 'a': "i8", 's': "i16", 'l': "i32", 'x': "i64",
+	'n': "i128", 'i': "isize", 'h': "u8", 't': "u16", 'm': "u32", 'y': "u64", 'o': "u128", 'j': "usize",
+	'f': "f32", 'd': "f64", 'z': "!", 'p': "_", 'v': "...",
+}
+
+/* state of the v0 demangler, positions index sym which excludes the _R prefix */
+type rustParser struct {
+	sym        string
+	next       int
+	out        []byte
+	skipping   bool // parsing the instantiating crate or an impl path, which print nothing
+	verbose    bool // crate disambiguators are printed
+	boundDepth uint64
+	depth      int
+}
+
+func (r *rustParser) fail() { panic(parseError{}) }
+
+func (r *rustParser) str(s string) {
+	if !r.skipping {
+		r.out = append(r.out, s...)
+	}
+}
+
+func (r *rustParser) peek() byte {
+	if r.next < len(r.sym) {
+		return r.sym[r.next]
+	}
+	return 0
+}
+
+func (r *rustParser) eat(c byte) bool {
+	if r.peek() == c {
+		r.next++
+		return true
+	}
+	return false
+}
+
+func (r *rustParser) nextByte() byte {
+	c := r.peek()
+	if c == 0 {
+		r.fail()
+	}
+	r.next++
+	return c
+}
+
+func (r *rustParser) enter() {
+	r.depth++
+	if r.depth > 500 {
+		r.fail()
+	}
+}
+
+func (r *rustParser) leave() { r.depth-- }
+
+// demangleRustV0 handles the _R mangling, printing crate disambiguators in
+// brackets the way c++filt does when verbose.
+func demangleRustV0(name string, verbose bool) (out string, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			if _, ok := rec.(parseError); !ok {
+				panic(rec)
+			}
+			out, err = "", ErrInvalid
+		}
+	}()
+
+	sym := name[2:]
+	if len(sym) == 0 || sym[0] < 'A' || sym[0] > 'Z' {
+		return "", ErrNotMangled
+	}
+	/* suffixes such as .llvm.1234 are dropped */
+	if i := strings.IndexByte(sym, '.'); i >= 0 {
+		sym = sym[:i]
+	}
+	for i := 0; i < len(sym); i++ {
+		if sym[i] != '_' && !isAlnum(sym[i]) {
+			return "", ErrInvalid
+		}
+	}
+
+	r := &rustParser{sym: sym, verbose: verbose}
+	r.path(true)
+	if r.next < len(r.sym) {
+		r.skipping = true
+		r.path(false)
+	}
+	if r.next != len(r.sym) {
+		return "", ErrInvalid
+	}
+	return string(r.out), nil
+}
+
+/* <base-62-number>: digits, a-z, A-Z terminated by _, where _ alone is 0 */
+func (r *rustParser) integer62() uint64 {
+	if r.eat('_') {
+		return 0
+	}
+	var x uint64
+	for !r.eat('_') {
+		c := r.nextByte()
+		x *= 62
+		switch {
+		case isDigit(c):
+			x += uint64(c - '0')
+		case c >= 'a' && c <= 'z':
+			x += uint64(c-'a') + 10
+		case c >= 'A' && c <= 'Z':
+			x += uint64(c-'A') + 36
+		default:
+			r.fail()
+		}
+	}
+	return x + 1
+}
+
+func (r *rustParser) optInteger62(tag byte) uint64 {
+	if !r.eat(tag) {
+		return 0
+	}
+	return r.integer62() + 1
+}
+
+/* a backref points at an earlier position; the tail after it is parsed in place */
+func (r *rustParser) backref(parse func()) {
+	pos := r.integer62()
+	if r.skipping {
+		return
+	}
+	if pos >= uint64(r.next) {
+		r.fail()
+	}
+	old := r.next
+	r.next = int(pos)
+	parse()
+	r.next = old
+}
+
+type rustIdent struct {
+	ascii    string
+	punycode string
+}
+
+func (id rustIdent) empty() bool { return id.ascii == "" && id.punycode == "" }
+
+func (r *rustParser) ident() rustIdent {
+	puny := r.eat('u')
+	c := r.nextByte()
+	if !isDigit(c) {
+		r.fail()
+	}
+	l := int(c - '0')
+	if c != '0' {
+		for isDigit(r.peek()) {
+			l = l*10 + int(r.nextByte()-'0')
+			if l > len(r.sym) {
+				r.fail()
+			}
+		}
+	}
+	r.eat('_')
+	if l > len(r.sym)-r.next {
+		r.fail()
+	}
+	s := r.sym[r.next : r.next+l]
+	r.next += l
+	if !puny {
+		return rustIdent{ascii: s}
+	}
+	i := strings.LastIndexByte(s, '_')
+	if i == len(s)-1 {
+		r.fail()
+	}
+	if i < 0 {
+		return rustIdent{punycode: s}
+	}
+	return rustIdent{ascii: s[:i], punycode: s[i+1:]}
+}
+
+func (r *rustParser) printIdent(id rustIdent) {
+	if id.punycode == "" {
+		r.str(id.ascii)
+		return
+	}
+	decoded, ok := decodePunycode(id.ascii, id.punycode)
+	if !ok {
+		r.fail()
+	}
+	r.str(decoded)
+}
+
+/* RFC 3492 decoding with the digits v0 uses, a-z then 0-9 */
+func decodePunycode(basic, puny string) (string, bool) {
+	const (
+		base, tmin, tmax, skew, damp = 36, 1, 26, 38, 700
+	)
+	out := []rune(basic)
+	n, bias, i := 0x80, 72, 0
+	adapt := func(delta, points int, first bool) int {
+		if first {
+			delta /= damp
+		} else {
+			delta /= 2
+		}
+		delta += delta / points
+		k := 0
+		for delta > ((base-tmin)*tmax)/2 {
+			delta /= base - tmin
+			k += base
+		}
+		return k + (base-tmin+1)*delta/(delta+skew)
+	}
+	for pos := 0; pos < len(puny); {
+		old, w := i, 1
+		for k := base; ; k += base {
+			if pos >= len(puny) {
+				return "", false
+			}
+			c := puny[pos]
+			pos++
+			var digit int
+			switch {
+			case c >= 'a' && c <= 'z':
+				digit = int(c - 'a')
+			case isDigit(c):
+				digit = int(c-'0') + 26
+			default:
+				return "", false
+			}
+			i += digit * w
+			t := k - bias
+			if t < tmin {
+				t = tmin
+			} else if t > tmax {
+				t = tmax
+			}
+			if digit < t {
+				break
+			}
+			w *= base - t
+			if i > 1<<30 || w > 1<<30 {
+				return "", false
+			}
+		}
+		bias = adapt(i-old, len(out)+1, old == 0)
+		n += i / (len(out) + 1)
+		i %= len(out) + 1
+		if n > utf8.MaxRune {
+			return "", false
+		}
+		out = append(out[:i], append([]rune{rune(n)}, out[i:]...)...)
+		i++
+	}
+	return string(out), true
+}
+
+func (r *rustParser) path(inValue bool) {
+	r.enter()
+	defer r.leave()
+
+	switch tag := r.nextByte(); tag {
+	case 'C':
+		dis := r.optInteger62('s')
+		r.printIdent(r.ident())
+		if r.verbose {
+			r.str("[" + strconv.FormatUint(dis, 16) + "]")
+		}
+	case 'N':
+		ns := r.nextByte()
+		if !(ns >= 'a' && ns <= 'z' || ns >= 'A' && ns <= 'Z') {
+			r.fail()
+		}
+		r.path(inValue)
+		dis := r.optInteger62('s')
+		id := r.ident()
+		if ns >= 'A' && ns <= 'Z' {
+			r.str("::{")
+			switch ns {
+			case 'C':
+				r.str("closure")
+			case 'S':
+				r.str("shim")
+			default:
+				r.str(string(ns))
+			}
+			if !id.empty() {
+				r.str(":")
+				r.printIdent(id)
+			}
+			r.str("#" + strconv.FormatUint(dis, 10) + "}")
+		} else if !id.empty() {
+			r.str("::")
+			r.printIdent(id)
+		}
+	case 'M', 'X', 'Y':
+		if tag != 'Y' {
+			/* the impl's own path is not shown */
+			r.optInteger62('s')
+			skipping := r.skipping
+			r.skipping = true
+			r.path(inValue)
+			r.skipping = skipping
+		}
+		r.str("<")
+		r.typ()
+		if tag != 'M' {
+			r.str(" as ")
+			r.path(false)
+		}
+		r.str(">")
+	case 'I':
+		r.path(inValue)
+		if inValue {
+			r.str("::")
+		}
+		r.str("<")
+		for i := 0; !r.eat('E'); i++ {
+			if i > 0 {
+				r.str(", ")
+			}
+			r.genericArg()
+		}
+		r.str(">")
+	case 'B':
+		r.backref(func() { r.path(inValue) })
+	default:
+		r.fail()
+	}
+}
+
+func (r *rustParser) genericArg() {
+	switch {
+	case r.eat('L'):
+		r.lifetime(r.integer62())
+	case r.eat('K'):
+		r.constant()
+	default:
+		r.typ()
+	}
+}
+
+/* lifetimes are de Bruijn indices into the enclosing binders, named 'a, 'b, ... */
+func (r *rustParser) lifetime(lt uint64) {
+	r.str("'")
+	if lt == 0 {
+		r.str("_")
+		return
+	}
+	if lt > r.boundDepth {
+		r.fail()
+	}
+	depth := r.boundDepth - lt
+	if depth < 26 {
+		r.str(string(rune('a' + depth)))
+	} else {
+		r.str("_" + strconv.FormatUint(depth, 10))
+	}
+}
+
+func (r *rustParser) binder() {
+	n := r.optInteger62('G')
+	if n == 0 {
+		return
+	}
+	r.str("for<")
+	for i := uint64(0); i < n; i++ {
+		if i > 0 {
+			r.str(", ")
+		}
+		r.boundDepth++
+		r.lifetime(1)
+	}
+	r.str("> ")
+}
+
+func (r *rustParser) typ() {
+	tag := r.nextByte()
+	if basic, ok := rustBasicTypes[tag]; ok {
+		r.str(basic)
+		return
+	}
+	r.enter()
+	defer r.leave()
+
+	switch tag {
+	case 'R', 'Q':
+		r.str("&")
+		if r.eat('L') {
+			if lt := r.integer62(); lt != 0 {
+				r.lifetime(lt)
+				r.str(" ")
+			}
+		}
+		if tag == 'Q' {
+			r.str("mut ")
+		}
+		r.typ()
+	case 'P', 'O':
+		if tag == 'P' {
+			r.str("*const ")
+		} else {
+			r.str("*mut ")
+		}
+		r.typ()
+	case 'A', 'S':
+		r.str("[")
+		r.typ()
+		if tag == 'A' {
+			r.str("; ")
+			r.constant()
+		}
+		r.str("]")
+	case 'T':
+		r.str("(")
+		i := 0
+		for ; !r.eat('E'); i++ {
+			if i > 0 {
+				r.str(", ")
+			}
+			r.typ()
+		}
+		if i == 1 {
+			r.str(",")
+		}
+		r.str(")")
+	case 'F':
+		depth := r.boundDepth
+		r.binder()
+		if r.eat('U') {
+			r.str("unsafe ")
+		}
+		if r.eat('K') {
+			abi := "C"
+			if !r.eat('C') {
+				id := r.ident()
+				if id.ascii == "" || id.punycode != "" {
+					r.fail()
+				}
+				abi = strings.ReplaceAll(id.ascii, "_", "-")
+			}
+			r.str("extern \"" + abi + "\" ")
+		}
+		r.str("fn(")
+		for i := 0; !r.eat('E'); i++ {
+			if i > 0 {
+				r.str(", ")
+			}
+			r.typ()
+		}
+		r.str(")")
+		if !r.eat('u') {
+			r.str(" -> ")
+			r.typ()
+		}
+		r.boundDepth = depth
+	case 'D':
+		r.str("dyn ")
+		depth := r.boundDepth
+		r.binder()
+		for i := 0; !r.eat('E'); i++ {
+			if i > 0 {
+				r.str(" + ")
+			}
+			r.dynTrait()
+		}
+		r.boundDepth = depth
+		if !r.eat('L') {
+			r.fail()
+		}
+		if lt := r.integer62(); lt != 0 {
+			r.str(" + ")
+			r.lifetime(lt)
+		}
+	case 'B':
+		r.backref(r.typ)
+	default:
+		r.next--
+		r.path(false)
+	}
+}
+
+/* a trait path whose generic argument list stays open for associated type bindings */
+func (r *rustParser) pathMaybeOpenGenerics() bool {
+	r.enter()
+	defer r.leave()
+
+	open := false
+	switch {
+	case r.eat('B'):
+		r.backref(func() { open = r.pathMaybeOpenGenerics() })
+	case r.eat('I'):
+		r.path(false)
+		r.str("<")
+		open = true
+		for i := 0; !r.eat('E'); i++ {
+			if i > 0 {
+				r.str(", ")
+			}
+			r.genericArg()
+		}
+	default:
+		r.path(false)
+	}
+	return open
+}
+
+func (r *rustParser) dynTrait() {
+	open := r.pathMaybeOpenGenerics()
+	for r.eat('p') {
+		if open {
+			r.str(", ")
+		} else {
+			r.str("<")
+		}
+		open = true
+		r.printIdent(r.ident())
+		r.str(" = ")
+		r.typ()
+	}
+	if open {
+		r.str(">")
+	}
+}
+
+func (r *rustParser) hexNibbles() (uint64, string) {
+	start := r.next
+	var v uint64
+	for !r.eat('_') {
+		n := hexNibble(r.nextByte())
+		if n < 0 {
+			r.fail()
+		}
+		v = v<<4 | uint64(n)
+	}
+	return v, r.sym[start : r.next-1]
+}
+
+func (r *rustParser) constUint() {
+	v, hex := r.hexNibbles()
+	if len(hex) > 16 {
+		r.str("0x" + hex)
+		return
+	}
+	r.str(strconv.FormatUint(v, 10))
+}
+
+func (r *rustParser) constant() {
+	if r.eat('B') {
+		r.backref(r.constant)
+		return
+	}
+	tag := r.nextByte()
+	switch tag {
+	case 'p':
+		r.str("_")
+		return
+	case 'h', 't', 'm', 'y', 'o', 'j':
+		r.constUint()
+	case 'a', 's', 'l', 'x', 'n', 'i':
+		if r.eat('n') {
+			r.str("-")
+		}
+		r.constUint()
+	case 'b':
+		switch v, _ := r.hexNibbles(); v {
+		case 0:
+			r.str("false")
+		case 1:
+			r.str("true")
+		default:
+			r.fail()
+		}
+	case 'c':
+		v, hex := r.hexNibbles()
+		if len(hex) == 0 || len(hex) > 8 || v > utf8.MaxRune || v >= 0xd800 && v < 0xe000 {
+			r.fail()
+		}
+		r.str(quoteRustChar(rune(v)))
+	default:
+		r.fail()
+	}
+	r.str(": " + rustBasicTypes[tag])
+}
+
+func quoteRustChar(c rune) string {
+	switch c {
+	case 0:
+		return `'\0'`
+	case '\t':
+		return `'\t'`
+	case '\r':
+		return `'\r'`
+	case '\n':
+		return `'\n'`
+	case '\\':
+		return `'\\'`
+	case '\'':
+		return `'\''`
+	}
+	if c >= 0x20 && c <= 0x7e {
+		return "'" + string(c) + "'"
+	}
+	return "'\\u{" + strconv.FormatInt(int64(c), 16) + "}'"
+}
@@ -0,0 +1,995 @@
+package main
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Attribute form encodings, DWARF 5 section 7.5.6 plus the GNU extensions
+// gcc and dwz emit. Go's dwarf package keeps these unexported.
+const (
+	formAddr          = 0x01
+	formBlock2        = 0x03
+	formBlock4        = 0x04
+	formData2         = 0x05
+	formData4         = 0x06
+	formData8         = 0x07
+	formString        = 0x08
+	formBlock         = 0x09
+	formBlock1        = 0x0a
+	formData1         = 0x0b
+	formFlag          = 0x0c
+	formSdata         = 0x0d
+	formStrp          = 0x0e
+	formUdata         = 0x0f
+	formRefAddr       = 0x10
+	formRef1          = 0x11
+	formRef2          = 0x12
+	formRef4          = 0x13
+	formRef8          = 0x14
+	formRefUdata      = 0x15
+	formIndirect      = 0x16
+	formSecOffset     = 0x17
+	formExprloc       = 0x18
+	formFlagPresent   = 0x19
+	formStrx          = 0x1a
+	formAddrx         = 0x1b
+	formRefSup4       = 0x1c
+	formStrpSup       = 0x1d
+	formData16        = 0x1e
+	formLineStrp      = 0x1f
+	formRefSig8       = 0x20
+	formImplicitConst = 0x21
+	formLoclistx      = 0x22
+	formRnglistx      = 0x23
+	formRefSup8       = 0x24
+	formStrx1         = 0x25
+	formStrx2         = 0x26
+	formStrx3         = 0x27
+	formStrx4         = 0x28
+	formAddrx1        = 0x29
+	formAddrx2        = 0x2a
+	formAddrx3        = 0x2b
+	formAddrx4        = 0x2c
+	formGNUAddrIndex  = 0x1f01
+	formGNUStrIndex   = 0x1f02
+	formGNURefAlt     = 0x1f20
+	formGNUStrpAlt    = 0x1f21
+)
+
+// DWARF 5 unit types
+const (
+	utCompile      = 0x01
+	utType         = 0x02
+	utPartial      = 0x03
+	utSkeleton     = 0x04
+	utSplitCompile = 0x05
+	utSplitType    = 0x06
+)
+
+// DWARF 5 range list entry kinds (.debug_rnglists)
+const (
+	rleEndOfList    = 0x00
+	rleBaseAddressx = 0x01
+	rleStartxEndx   = 0x02
+	rleStartxLength = 0x03
+	rleOffsetPair   = 0x04
+	rleBaseAddress  = 0x05
+	rleStartEnd     = 0x06
+	rleStartLength  = 0x07
+)
+
+/* pre DWARF 4 spelling of DW_AT_linkage_name still emitted by older compilers */
+const attrMIPSLinkageName dwarf.Attr = 0x2007
+
+var errDwarfShort = errors.New("dwarf: unexpected end of section data")
+
+/* cursor over a DWARF section, the first out of bounds read latches err and yields zeros */
+type dwarfBuf struct {
+	data  []byte
+	off   uint64
+	order binary.ByteOrder
+	err   error
+}
+
+func (b *dwarfBuf) need(n uint64) bool {
+	if b.err != nil {
+		return false
+	}
+	if b.off+n > uint64(len(b.data)) || b.off+n < b.off {
+		b.err = errDwarfShort
+		return false
+	}
+	return true
+}
+
+func (b *dwarfBuf) u8() uint8 {
+	if !b.need(1) {
+		return 0
+	}
+	v := b.data[b.off]
+	b.off++
+	return v
+}
+
+func (b *dwarfBuf) u16() uint16 {
+	if !b.need(2) {
+		return 0
+	}
+	v := b.order.Uint16(b.data[b.off:])
+	b.off += 2
+	return v
+}
+
+func (b *dwarfBuf) u24() uint32 {
+	if !b.need(3) {
+		return 0
+	}
+	p := b.data[b.off : b.off+3]
+	b.off += 3
+	if b.order == binary.BigEndian {
+		return uint32(p[0])<<16 | uint32(p[1])<<8 | uint32(p[2])
+	}
+	return uint32(p[2])<<16 | uint32(p[1])<<8 | uint32(p[0])
+}
+
+func (b *dwarfBuf) u32() uint32 {
+	if !b.need(4) {
+		return 0
+	}
+	v := b.order.Uint32(b.data[b.off:])
+	b.off += 4
+	return v
+}
+
+func (b *dwarfBuf) u64() uint64 {
+	if !b.need(8) {
+		return 0
+	}
+	v := b.order.Uint64(b.data[b.off:])
+	b.off += 8
+	return v
+}
+
+func (b *dwarfBuf) uleb() uint64 {
+	var v uint64
+	var shift uint
+	for {
+		c := b.u8()
+		if b.err != nil {
+			return 0
+		}
+		if shift < 64 {
+			v |= uint64(c&0x7f) << shift
+		}
+		shift += 7
+		if c&0x80 == 0 {
+			return v
+		}
+	}
+}
+
+func (b *dwarfBuf) sleb() int64 {
+	var v int64
+	var shift uint
+	var c uint8
+	for {
+		c = b.u8()
+		if b.err != nil {
+			return 0
+		}
+		if shift < 64 {
+			v |= int64(c&0x7f) << shift
+		}
+		shift += 7
+		if c&0x80 == 0 {
+			break
+		}
+	}
+	if shift < 64 && c&0x40 != 0 {
+		v |= -1 << shift
+	}
+	return v
+}
+
+func (b *dwarfBuf) cstr() string {
+	if b.err != nil {
+		return ""
+	}
+	start := b.off
+	for b.off < uint64(len(b.data)) {
+		if b.data[b.off] == 0 {
+			s := string(b.data[start:b.off])
+			b.off++
+			return s
+		}
+		b.off++
+	}
+	b.err = errDwarfShort
+	return ""
+}
+
+func (b *dwarfBuf) bytes(n uint64) []byte {
+	if !b.need(n) {
+		return nil
+	}
+	v := b.data[b.off : b.off+n]
+	b.off += n
+	return v
+}
+
+func (b *dwarfBuf) skip(n uint64) {
+	if b.need(n) {
+		b.off += n
+	}
+}
+
+func (b *dwarfBuf) addr(size uint8) uint64 {
+	switch size {
+	case 1:
+		return uint64(b.u8())
+	case 2:
+		return uint64(b.u16())
+	case 4:
+		return uint64(b.u32())
+	case 8:
+		return b.u64()
+	}
+	b.err = fmt.Errorf("dwarf: unsupported address size %d", size)
+	return 0
+}
+
+func (b *dwarfBuf) offset(is64 bool) uint64 {
+	if is64 {
+		return b.u64()
+	}
+	return uint64(b.u32())
+}
+
+/* initial length field, 0xffffffff escapes to the 64-bit DWARF format */
+func (b *dwarfBuf) unitLength() (uint64, bool) {
+	l := b.u32()
+	if l == 0xffffffff {
+		return b.u64(), true
+	}
+	return uint64(l), false
+}
+
+/* reads a NUL terminated string at off within a string section */
+func cstrAt(data []byte, off uint64) string {
+	if off >= uint64(len(data)) {
+		return ""
+	}
+	return getSectionName(uint32(off), data)
+}
+
+type dwarfAttrSpec struct {
+	Attr          dwarf.Attr
+	Form          uint64
+	ImplicitConst int64
+}
+
+type dwarfAbbrev struct {
+	Tag      dwarf.Tag
+	Children bool
+	Specs    []dwarfAttrSpec
+}
+
+type dwarfAttr struct {
+	Attr  dwarf.Attr
+	Form  uint64
+	Val   uint64 // constants, addresses, offsets, indices and flags
+	Sval  int64  // DW_FORM_sdata and DW_FORM_implicit_const
+	Str   string // DW_FORM_string
+	Block []byte // blocks, exprloc and data16
+}
+
+type dwarfEntry struct {
+	Offset   uint64
+	Tag      dwarf.Tag
+	Attrs    []dwarfAttr
+	Children []*dwarfEntry
+	Parent   *dwarfEntry
+	Unit     *dwarfUnit
+}
+
+type dwarfUnit struct {
+	Offset     uint64
+	Version    uint16
+	UnitType   uint8
+	Is64       bool
+	AddrSize   uint8
+	AbbrevOff  uint64
+	End        uint64
+	Root       *dwarfEntry
+	StrOffBase uint64
+	AddrBase   uint64
+	RngBase    uint64
+	LowPC      uint64
+	Data       *dwarfData
+	lines      *lineTable
+}
+
+type dwarfData struct {
+	Order      binary.ByteOrder
+	Info       []byte
+	Abbrev     []byte
+	Str        []byte
+	LineStr    []byte
+	StrOffsets []byte
+	Addr       []byte
+	Ranges     []byte
+	RngLists   []byte
+	Line       []byte
+	Units      []*dwarfUnit
+	Alt        *dwarfData // dwz common file named by .gnu_debugaltlink
+	entries    map[uint64]*dwarfEntry
+	abbrevs    map[uint64]map[uint64]*dwarfAbbrev
+}
+
+/* collects the DWARF sections of the target, nil when there is no .debug_info */
+func loadDwarf(elfFs *elfFile) (*dwarfData, error) {
+	if len(elfFs.ElfSections.SectionName) == 0 {
+		elfFs.getSections()
+	}
+
+	/* stripped binaries keep their DWARF in a separate debug file */
+	if debugSectionNdx(".debug_info", elfFs) == 0 {
+		if dbg := elfFs.getDebugFile(); dbg != nil {
+			elfFs = dbg
+		}
+	}
+
+	d := new(dwarfData)
+	d.Order = elfFs.FileHdr.Endianness
+	if d.Info = getDebugSectionData(".debug_info", elfFs); d.Info == nil {
+		return nil, nil
+	}
+	d.Abbrev = getDebugSectionData(".debug_abbrev", elfFs)
+	d.Str = getDebugSectionData(".debug_str", elfFs)
+	d.LineStr = getDebugSectionData(".debug_line_str", elfFs)
+	d.StrOffsets = getDebugSectionData(".debug_str_offsets", elfFs)
+	d.Addr = getDebugSectionData(".debug_addr", elfFs)
+	d.Ranges = getDebugSectionData(".debug_ranges", elfFs)
+	d.RngLists = getDebugSectionData(".debug_rnglists", elfFs)
+	d.Line = getDebugSectionData(".debug_line", elfFs)
+
+	if err := d.parseUnits(); err != nil {
+		return nil, err
+	}
+
+	/* strings and entries shared between binaries by dwz live in the .gnu_debugaltlink file */
+	if alt := getAltDebugFile(elfFs); alt != nil {
+		var err error
+		if d.Alt, err = loadDwarf(alt); err != nil {
+			fmt.Printf("Warning: %s: %v\n", alt.Path, err)
+		}
+	}
+	return d, nil
+}
+
+/* debug section contents, with relocations applied when the target is an object file */
+/* older toolchains rename compressed .debug_* sections to .zdebug_* */
+func debugSectionNdx(name string, elfFs *elfFile) uint32 {
+	if ndx := getSectionNdx(name, elfFs); ndx != 0 {
+		return ndx
+	}
+	return getSectionNdx(".z"+strings.TrimPrefix(name, "."), elfFs)
+}
+
+func getDebugSectionData(name string, elfFs *elfFile) []byte {
+	ndx := debugSectionNdx(name, elfFs)
+	if ndx == 0 {
+		return nil
+	}
+	data := getSectionData(ndx, elfFs)
+
+	var etype elf.Type
+	switch h := elfFs.Hdr.(type) {
+	case *elf.Header32:
+		etype = elf.Type(h.Type)
+	case *elf.Header64:
+		etype = elf.Type(h.Type)
+	}
+	if etype == elf.ET_REL && data != nil {
+		relocateDebugSection(ndx, data, elfFs)
+	}
+	return data
+}
+
+/* width of the absolute data relocations compilers emit against debug sections */
+func absRelocSize(m elf.Machine, t uint32) int {
+	switch m {
+	case elf.EM_X86_64:
+		switch elf.R_X86_64(t) {
+		case elf.R_X86_64_64:
+			return 8
+		case elf.R_X86_64_32, elf.R_X86_64_32S:
+			return 4
+		}
+	case elf.EM_386:
+		if elf.R_386(t) == elf.R_386_32 {
+			return 4
+		}
+	case elf.EM_AARCH64:
+		switch elf.R_AARCH64(t) {
+		case elf.R_AARCH64_ABS64:
+			return 8
+		case elf.R_AARCH64_ABS32:
+			return 4
+		}
+	case elf.EM_ARM:
+		if elf.R_ARM(t) == elf.R_ARM_ABS32 {
+			return 4
+		}
+	case elf.EM_PPC64:
+		switch elf.R_PPC64(t) {
+		case elf.R_PPC64_ADDR64:
+			return 8
+		case elf.R_PPC64_ADDR32:
+			return 4
+		}
+	case elf.EM_PPC:
+		if elf.R_PPC(t) == elf.R_PPC_ADDR32 {
+			return 4
+		}
+	case elf.EM_RISCV:
+		switch elf.R_RISCV(t) {
+		case elf.R_RISCV_64:
+			return 8
+		case elf.R_RISCV_32:
+			return 4
+		}
+	case elf.EM_S390:
+		switch elf.R_390(t) {
+		case elf.R_390_64:
+			return 8
+		case elf.R_390_32:
+			return 4
+		}
+	case elf.EM_SPARCV9:
+		switch elf.R_SPARC(t) {
+		case elf.R_SPARC_64, elf.R_SPARC_UA64:
+			return 8
+		case elf.R_SPARC_32, elf.R_SPARC_UA32:
+			return 4
+		}
+	case elf.EM_MIPS:
+		switch elf.R_MIPS(t) {
+		case elf.R_MIPS_64:
+			return 8
+		case elf.R_MIPS_32:
+			return 4
+		}
+	}
+	return 0
+}
+
+/* resolves S + A for every relocation section that targets section ndx */
+func relocateDebugSection(ndx uint32, data []byte, elfFs *elfFile) {
+	if elfFs.Rels == nil {
+		elfFs.getRelocations()
+	}
+	if elfFs.Symbols == nil {
+		if symNdx := getSectionNdx(".symtab", elfFs); symNdx != 0 {
+			elfFs.loadSymbols(symNdx, getSectionNdx(".strtab", elfFs), sym)
+		}
+	}
+
+	order := elfFs.FileHdr.Endianness
+	apply := func(off uint64, symNdx uint32, t uint32, addend int64, hasAddend bool) {
+		size := absRelocSize(elfFs.FileHdr.Machine, t)
+		if size == 0 || off+uint64(size) > uint64(len(data)) {
+			return
+		}
+
+		var s uint64
+		switch sym := elfFs.Symbols[symNdx].(type) {
+		case *elf.Sym32:
+			s = uint64(sym.Value)
+		case *elf.Sym64:
+			s = sym.Value
+		}
+
+		if size == 8 {
+			if !hasAddend {
+				addend = int64(order.Uint64(data[off:]))
+			}
+			order.PutUint64(data[off:], s+uint64(addend))
+		} else {
+			if !hasAddend {
+				addend = int64(int32(order.Uint32(data[off:])))
+			}
+			order.PutUint32(data[off:], uint32(s+uint64(addend)))
+		}
+	}
+
+	for relNdx, v := range elfFs.Rels {
+		if getSectionHeader(relNdx, elfFs).Info != ndx {
+			continue
+		}
+		switch r := v.(type) {
+		case []elf.Rel32:
+			for _, rel := range r {
+				apply(uint64(rel.Off), elf.R_SYM32(rel.Info), elf.R_TYPE32(rel.Info), 0, false)
+			}
+		case []elf.Rela32:
+			for _, rel := range r {
+				apply(uint64(rel.Off), elf.R_SYM32(rel.Info), elf.R_TYPE32(rel.Info), int64(rel.Addend), true)
+			}
+		case []elf.Rel64:
+			for _, rel := range r {
+				apply(rel.Off, elf.R_SYM64(rel.Info), elf.R_TYPE64(rel.Info), 0, false)
+			}
+		case []elf.Rela64:
+			for _, rel := range r {
+				apply(rel.Off, elf.R_SYM64(rel.Info), elf.R_TYPE64(rel.Info), rel.Addend, true)
+			}
+		}
+	}
+}
+
+func (d *dwarfData) parseAbbrevs(off uint64) (map[uint64]*dwarfAbbrev, error) {
+	if d.abbrevs == nil {
+		d.abbrevs = make(map[uint64]map[uint64]*dwarfAbbrev)
+	}
+	if m, ok := d.abbrevs[off]; ok {
+		return m, nil
+	}
+
+	m := make(map[uint64]*dwarfAbbrev)
+	b := &dwarfBuf{data: d.Abbrev, off: off, order: d.Order}
+	for {
+		code := b.uleb()
+		if code == 0 || b.err != nil {
+			break
+		}
+		a := new(dwarfAbbrev)
+		a.Tag = dwarf.Tag(b.uleb())
+		a.Children = b.u8() != 0
+		for {
+			attr := b.uleb()
+			form := b.uleb()
+			if attr == 0 && form == 0 || b.err != nil {
+				break
+			}
+			spec := dwarfAttrSpec{Attr: dwarf.Attr(attr), Form: form}
+			if form == formImplicitConst {
+				spec.ImplicitConst = b.sleb()
+			}
+			a.Specs = append(a.Specs, spec)
+		}
+		m[code] = a
+	}
+	if b.err != nil {
+		return nil, fmt.Errorf("reading .debug_abbrev at 0x%x: %v", off, b.err)
+	}
+	d.abbrevs[off] = m
+	return m, nil
+}
+
+func (d *dwarfData) parseUnits() error {
+	d.entries = make(map[uint64]*dwarfEntry)
+	b := &dwarfBuf{data: d.Info, order: d.Order}
+
+	for b.off < uint64(len(d.Info)) {
+		u := &dwarfUnit{Offset: b.off, Data: d}
+		length, is64 := b.unitLength()
+		u.Is64 = is64
+		u.End = b.off + length
+		u.Version = b.u16()
+		if b.err != nil || u.End > uint64(len(d.Info)) {
+			return fmt.Errorf("corrupt unit header at 0x%x in .debug_info", u.Offset)
+		}
+		if u.Version < 2 || u.Version > 5 {
+			return fmt.Errorf("unsupported DWARF version %d at 0x%x", u.Version, u.Offset)
+		}
+
+		if u.Version >= 5 {
+			u.UnitType = b.u8()
+			u.AddrSize = b.u8()
+			u.AbbrevOff = b.offset(is64)
+			switch u.UnitType {
+			case utSkeleton, utSplitCompile:
+				b.skip(8) // dwo_id
+			case utType, utSplitType:
+				b.skip(8)        // type_signature
+				b.offset(u.Is64) // type_offset
+			}
+		} else {
+			u.UnitType = utCompile
+			u.AbbrevOff = b.offset(is64)
+			u.AddrSize = b.u8()
+		}
+
+		abbrevs, err := d.parseAbbrevs(u.AbbrevOff)
+		if err != nil {
+			return err
+		}
+
+		ub := &dwarfBuf{data: d.Info[:u.End], off: b.off, order: d.Order}
+		if err := d.parseEntries(u, ub, abbrevs); err != nil {
+			return err
+		}
+		d.Units = append(d.Units, u)
+
+		if u.Root != nil {
+			u.StrOffBase, _ = u.Root.uintVal(dwarf.AttrStrOffsetsBase)
+			u.AddrBase, _ = u.Root.uintVal(dwarf.AttrAddrBase)
+			u.RngBase, _ = u.Root.uintVal(dwarf.AttrRnglistsBase)
+			u.LowPC, _ = d.addrVal(u.Root, dwarf.AttrLowpc)
+		}
+		b.off = u.End
+	}
+	return nil
+}
+
+func (d *dwarfData) parseEntries(u *dwarfUnit, b *dwarfBuf, abbrevs map[uint64]*dwarfAbbrev) error {
+	var parent *dwarfEntry
+	for b.off < uint64(len(b.data)) {
+		off := b.off
+		code := b.uleb()
+		if b.err != nil {
+			break
+		}
+		if code == 0 {
+			/* end of a sibling chain */
+			if parent == nil {
+				continue
+			}
+			parent = parent.Parent
+			continue
+		}
+
+		a, ok := abbrevs[code]
+		if !ok {
+			return fmt.Errorf("unknown abbreviation %d at 0x%x in .debug_info", code, off)
+		}
+
+		e := &dwarfEntry{Offset: off, Tag: a.Tag, Parent: parent, Unit: u}
+		e.Attrs = make([]dwarfAttr, 0, len(a.Specs))
+		for _, spec := range a.Specs {
+			e.Attrs = append(e.Attrs, d.readAttr(u, b, spec.Attr, spec.Form, spec.ImplicitConst))
+		}
+		if b.err != nil {
+			return fmt.Errorf("reading entry at 0x%x in .debug_info: %v", off, b.err)
+		}
+		d.entries[off] = e
+
+		if parent == nil {
+			if u.Root == nil {
+				u.Root = e
+			}
+		} else {
+			parent.Children = append(parent.Children, e)
+		}
+		if a.Children {
+			parent = e
+		}
+	}
+	return b.err
+}
+
+func (d *dwarfData) readAttr(u *dwarfUnit, b *dwarfBuf, at dwarf.Attr, form uint64, implicit int64) dwarfAttr {
+	v := dwarfAttr{Attr: at, Form: form}
+	switch form {
+	case formAddr:
+		v.Val = b.addr(u.AddrSize)
+	case formBlock1:
+		v.Block = b.bytes(uint64(b.u8()))
+	case formBlock2:
+		v.Block = b.bytes(uint64(b.u16()))
+	case formBlock4:
+		v.Block = b.bytes(uint64(b.u32()))
+	case formBlock, formExprloc:
+		v.Block = b.bytes(b.uleb())
+	case formData1, formRef1, formFlag, formStrx1, formAddrx1:
+		v.Val = uint64(b.u8())
+	case formData2, formRef2, formStrx2, formAddrx2:
+		v.Val = uint64(b.u16())
+	case formStrx3, formAddrx3:
+		v.Val = uint64(b.u24())
+	case formData4, formRef4, formRefSup4, formStrx4, formAddrx4:
+		v.Val = uint64(b.u32())
+	case formData8, formRef8, formRefSig8, formRefSup8:
+		v.Val = b.u64()
+	case formData16:
+		v.Block = b.bytes(16)
+	case formString:
+		v.Str = b.cstr()
+	case formSdata:
+		v.Sval = b.sleb()
+		v.Val = uint64(v.Sval)
+	case formUdata, formRefUdata, formStrx, formAddrx, formLoclistx, formRnglistx, formGNUAddrIndex, formGNUStrIndex:
+		v.Val = b.uleb()
+	case formStrp, formLineStrp, formSecOffset, formStrpSup, formGNURefAlt, formGNUStrpAlt:
+		v.Val = b.offset(u.Is64)
+	case formRefAddr:
+		if u.Version == 2 {
+			v.Val = b.addr(u.AddrSize)
+		} else {
+			v.Val = b.offset(u.Is64)
+		}
+	case formFlagPresent:
+		v.Val = 1
+	case formImplicitConst:
+		v.Sval = implicit
+		v.Val = uint64(implicit)
+	case formIndirect:
+		return d.readAttr(u, b, at, b.uleb(), implicit)
+	default:
+		b.err = fmt.Errorf("dwarf: unknown attribute form 0x%x", form)
+	}
+	return v
+}
+
+func (e *dwarfEntry) attr(at dwarf.Attr) *dwarfAttr {
+	for i := range e.Attrs {
+		if e.Attrs[i].Attr == at {
+			return &e.Attrs[i]
+		}
+	}
+	return nil
+}
+
+func isConstForm(form uint64) bool {
+	switch form {
+	case formData1, formData2, formData4, formData8, formSdata, formUdata, formImplicitConst:
+		return true
+	}
+	return false
+}
+
+/* constant class attribute value, DW_FORM_sec_offset is accepted for the *_base attributes */
+func (e *dwarfEntry) uintVal(at dwarf.Attr) (uint64, bool) {
+	a := e.attr(at)
+	if a == nil {
+		return 0, false
+	}
+	if isConstForm(a.Form) || a.Form == formSecOffset || a.Form == formFlag || a.Form == formFlagPresent {
+		return a.Val, true
+	}
+	return 0, false
+}
+
+func (e *dwarfEntry) flag(at dwarf.Attr) bool {
+	v, _ := e.uintVal(at)
+	return v != 0
+}
+
+func (d *dwarfData) strx(u *dwarfUnit, index uint64) string {
+	var size uint64 = 4
+	base := u.StrOffBase
+	if u.Is64 {
+		size = 8
+	}
+	if base == 0 && u.Version >= 5 {
+		/* skip the .debug_str_offsets header of the first contribution */
+		base = 2 * size
+	}
+	b := &dwarfBuf{data: d.StrOffsets, off: base + index*size, order: d.Order}
+	off := b.offset(u.Is64)
+	if b.err != nil {
+		return ""
+	}
+	return cstrAt(d.Str, off)
+}
+
+func (d *dwarfData) stringVal(e *dwarfEntry, at dwarf.Attr) string {
+	a := e.attr(at)
+	if a == nil {
+		return ""
+	}
+	/* entries reached through the alt file resolve against its sections */
+	d = e.Unit.Data
+	switch a.Form {
+	case formString:
+		return a.Str
+	case formStrp:
+		return cstrAt(d.Str, a.Val)
+	case formLineStrp:
+		return cstrAt(d.LineStr, a.Val)
+	case formStrx, formStrx1, formStrx2, formStrx3, formStrx4, formGNUStrIndex:
+		return d.strx(e.Unit, a.Val)
+	case formGNUStrpAlt, formStrpSup:
+		if d.Alt != nil {
+			return cstrAt(d.Alt.Str, a.Val)
+		}
+	}
+	return ""
+}
+
+func (d *dwarfData) addrx(u *dwarfUnit, index uint64) (uint64, bool) {
+	base := u.AddrBase
+	if base == 0 && u.Version >= 5 {
+		base = 8
+		if u.Is64 {
+			base = 16
+		}
+	}
+	b := &dwarfBuf{data: d.Addr, off: base + index*uint64(u.AddrSize), order: d.Order}
+	v := b.addr(u.AddrSize)
+	return v, b.err == nil
+}
+
+func (d *dwarfData) addrVal(e *dwarfEntry, at dwarf.Attr) (uint64, bool) {
+	a := e.attr(at)
+	if a == nil {
+		return 0, false
+	}
+	d = e.Unit.Data
+	switch a.Form {
+	case formAddr:
+		return a.Val, true
+	case formAddrx, formAddrx1, formAddrx2, formAddrx3, formAddrx4, formGNUAddrIndex:
+		return d.addrx(e.Unit, a.Val)
+	}
+	return 0, false
+}
+
+/* resolves a reference class attribute to the entry it points at */
+func (d *dwarfData) refVal(e *dwarfEntry, at dwarf.Attr) *dwarfEntry {
+	a := e.attr(at)
+	if a == nil {
+		return nil
+	}
+	d = e.Unit.Data
+	switch a.Form {
+	case formRef1, formRef2, formRef4, formRef8, formRefUdata:
+		return d.entries[e.Unit.Offset+a.Val]
+	case formRefAddr:
+		return d.entries[a.Val]
+	case formGNURefAlt, formRefSup4, formRefSup8:
+		if d.Alt != nil {
+			return d.Alt.entries[a.Val]
+		}
+	}
+	return nil
+}
+
+/* name of an entry, following the abstract origin and specification chains */
+func (d *dwarfData) entryName(e *dwarfEntry) string {
+	return d.chainName(e, dwarf.AttrName, dwarf.AttrLinkageName, attrMIPSLinkageName)
+}
+
+/* like entryName but prefers the linkage name, the way symbolizers report functions */
+func (d *dwarfData) funcName(e *dwarfEntry) string {
+	return d.chainName(e, dwarf.AttrLinkageName, attrMIPSLinkageName, dwarf.AttrName)
+}
+
+func (d *dwarfData) chainName(e *dwarfEntry, attrs ...dwarf.Attr) string {
+	for depth := 0; e != nil && depth < 16; depth++ {
+		for _, at := range attrs {
+			if n := d.stringVal(e, at); n != "" {
+				return n
+			}
+		}
+		next := d.refVal(e, dwarf.AttrAbstractOrigin)
+		if next == nil {
+			next = d.refVal(e, dwarf.AttrSpecification)
+		}
+		e = next
+	}
+	return ""
+}
+
+type pcRange struct {
+	Low, High uint64
+}
+
+// entryRanges is the address ranges an entry covers. DW_AT_ranges comes
+// first: GCC gives optimized compile units DW_AT_low_pc 0 next to it, and
+// that low_pc is only the base address the list is relative to.
+func (d *dwarfData) entryRanges(e *dwarfEntry) []pcRange {
+	if a := e.attr(dwarf.AttrRanges); a != nil {
+		u := e.Unit
+		if u.Version < 5 {
+			return d.readRanges(u, a.Val)
+		}
+		off := a.Val
+		if a.Form == formRnglistx {
+			size := uint64(4)
+			if u.Is64 {
+				size = 8
+			}
+			b := &dwarfBuf{data: d.RngLists, off: u.RngBase + a.Val*size, order: d.Order}
+			off = u.RngBase + b.offset(u.Is64)
+			if b.err != nil {
+				return nil
+			}
+		}
+		return d.readRngList(u, off)
+	}
+
+	low, ok := d.addrVal(e, dwarf.AttrLowpc)
+	if !ok {
+		return nil
+	}
+	h := e.attr(dwarf.AttrHighpc)
+	if h == nil {
+		return []pcRange{{low, low + 1}}
+	}
+	if isConstForm(h.Form) {
+		return []pcRange{{low, low + h.Val}}
+	}
+	if high, ok := d.addrVal(e, dwarf.AttrHighpc); ok {
+		return []pcRange{{low, high}}
+	}
+	return nil
+}
+
+/* pre DWARF 5 .debug_ranges list, entries are relative to the unit base address */
+func (d *dwarfData) readRanges(u *dwarfUnit, off uint64) []pcRange {
+	var ranges []pcRange
+	var maxAddr uint64 = ^uint64(0)
+	if u.AddrSize == 4 {
+		maxAddr = 0xffffffff
+	}
+
+	base := u.LowPC
+	b := &dwarfBuf{data: d.Ranges, off: off, order: d.Order}
+	for {
+		low := b.addr(u.AddrSize)
+		high := b.addr(u.AddrSize)
+		if b.err != nil || low == 0 && high == 0 {
+			break
+		}
+		if low == maxAddr {
+			base = high
+			continue
+		}
+		if low != high {
+			ranges = append(ranges, pcRange{base + low, base + high})
+		}
+	}
+	return ranges
+}
+
+func (d *dwarfData) readRngList(u *dwarfUnit, off uint64) []pcRange {
+	var ranges []pcRange
+	base := u.LowPC
+	b := &dwarfBuf{data: d.RngLists, off: off, order: d.Order}
+	for b.err == nil {
+		switch b.u8() {
+		case rleEndOfList:
+			return ranges
+		case rleBaseAddressx:
+			base, _ = d.addrx(u, b.uleb())
+		case rleStartxEndx:
+			low, _ := d.addrx(u, b.uleb())
+			high, _ := d.addrx(u, b.uleb())
+			ranges = append(ranges, pcRange{low, high})
+		case rleStartxLength:
+			low, _ := d.addrx(u, b.uleb())
+			ranges = append(ranges, pcRange{low, low + b.uleb()})
+		case rleOffsetPair:
+			low := b.uleb()
+			high := b.uleb()
+			ranges = append(ranges, pcRange{base + low, base + high})
+		case rleBaseAddress:
+			base = b.addr(u.AddrSize)
+		case rleStartEnd:
+			low := b.addr(u.AddrSize)
+			ranges = append(ranges, pcRange{low, b.addr(u.AddrSize)})
+		case rleStartLength:
+			low := b.addr(u.AddrSize)
+			ranges = append(ranges, pcRange{low, low + b.uleb()})
+		default:
+			return ranges
+		}
+	}
+	return ranges
+}
+
+func rangesContain(ranges []pcRange, addr uint64) bool {
+	for _, r := range ranges {
+		if addr >= r.Low && addr < r.High {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,566 @@
+package main
+
+import (
+	"debug/dwarf"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Standard line number opcodes
+const (
+	lnsCopy             = 0x01
+	lnsAdvancePC        = 0x02
+	lnsAdvanceLine      = 0x03
+	lnsSetFile          = 0x04
+	lnsSetColumn        = 0x05
+	lnsNegateStmt       = 0x06
+	lnsSetBasicBlock    = 0x07
+	lnsConstAddPC       = 0x08
+	lnsFixedAdvancePC   = 0x09
+	lnsSetPrologueEnd   = 0x0a
+	lnsSetEpilogueBegin = 0x0b
+	lnsSetISA           = 0x0c
+)
+
+// Extended line number opcodes
+const (
+	lneEndSequence      = 0x01
+	lneSetAddress       = 0x02
+	lneDefineFile       = 0x03
+	lneSetDiscriminator = 0x04
+)
+
+// DWARF 5 line number header entry content types
+const (
+	lnctPath           = 0x1
+	lnctDirectoryIndex = 0x2
+	lnctTimestamp      = 0x3
+	lnctSize           = 0x4
+	lnctMD5            = 0x5
+)
+
+type lineFileEntry struct {
+	Name   string
+	Dir    uint64
+	Mtime  uint64
+	Length uint64
+	MD5    []byte
+}
+
+type lineEntryFormat struct {
+	Content uint64
+	Form    uint64
+}
+
+type lineRow struct {
+	Address       uint64
+	OpIndex       uint64
+	File          uint64
+	Line          uint64
+	Column        uint64
+	IsStmt        bool
+	BasicBlock    bool
+	EndSequence   bool
+	PrologueEnd   bool
+	EpilogueBegin bool
+	ISA           uint64
+	Discriminator uint64
+}
+
+type lineTable struct {
+	Offset           uint64
+	Length           uint64
+	Is64             bool
+	Version          uint16
+	AddrSize         uint8
+	SegSelSize       uint8
+	HeaderLength     uint64
+	MinInstLength    uint8
+	MaxOpsPerInst    uint8
+	DefaultIsStmt    bool
+	LineBase         int8
+	LineRange        uint8
+	OpcodeBase       uint8
+	StdOpcodeLengths []uint8
+	DirFormat        []lineEntryFormat
+	Dirs             []string
+	FileFormat       []lineEntryFormat
+	Files            []lineFileEntry
+	ProgramOff       uint64
+	End              uint64
+	Rows             []lineRow
+}
+
+/* readelf style trace of each opcode, used by the raw dump */
+type lineTracer func(off uint64, format string, args ...interface{})
+
+func (d *dwarfData) parseLineTable(off uint64, trace lineTracer) (*lineTable, error) {
+	b := &dwarfBuf{data: d.Line, off: off, order: d.Order}
+	lt := &lineTable{Offset: off}
+
+	lt.Length, lt.Is64 = b.unitLength()
+	lt.End = b.off + lt.Length
+	lt.Version = b.u16()
+	if b.err != nil || lt.End > uint64(len(d.Line)) {
+		return nil, fmt.Errorf("corrupt line table header at 0x%x in .debug_line", off)
+	}
+	if lt.Version < 2 || lt.Version > 5 {
+		return nil, fmt.Errorf("unsupported line table version %d at 0x%x", lt.Version, off)
+	}
+	b.data = d.Line[:lt.End]
+
+	if lt.Version >= 5 {
+		lt.AddrSize = b.u8()
+		lt.SegSelSize = b.u8()
+	}
+	lt.HeaderLength = b.offset(lt.Is64)
+	lt.ProgramOff = b.off + lt.HeaderLength
+	lt.MinInstLength = b.u8()
+	lt.MaxOpsPerInst = 1
+	if lt.Version >= 4 {
+		lt.MaxOpsPerInst = b.u8()
+	}
+	lt.DefaultIsStmt = b.u8() != 0
+	lt.LineBase = int8(b.u8())
+	lt.LineRange = b.u8()
+	lt.OpcodeBase = b.u8()
+	for i := uint8(1); i < lt.OpcodeBase; i++ {
+		lt.StdOpcodeLengths = append(lt.StdOpcodeLengths, b.u8())
+	}
+
+	if lt.Version >= 5 {
+		lt.DirFormat = readLineEntryFormat(b)
+		count := b.uleb()
+		for i := uint64(0); i < count && b.err == nil; i++ {
+			fe := d.readLineFileEntry(b, lt, lt.DirFormat)
+			lt.Dirs = append(lt.Dirs, fe.Name)
+		}
+		lt.FileFormat = readLineEntryFormat(b)
+		count = b.uleb()
+		for i := uint64(0); i < count && b.err == nil; i++ {
+			lt.Files = append(lt.Files, d.readLineFileEntry(b, lt, lt.FileFormat))
+		}
+	} else {
+		for {
+			dir := b.cstr()
+			if dir == "" || b.err != nil {
+				break
+			}
+			lt.Dirs = append(lt.Dirs, dir)
+		}
+		for {
+			name := b.cstr()
+			if name == "" || b.err != nil {
+				break
+			}
+			fe := lineFileEntry{Name: name}
+			fe.Dir = b.uleb()
+			fe.Mtime = b.uleb()
+			fe.Length = b.uleb()
+			lt.Files = append(lt.Files, fe)
+		}
+	}
+
+	if b.err != nil {
+		return nil, fmt.Errorf("corrupt line table header at 0x%x in .debug_line: %v", off, b.err)
+	}
+	if lt.LineRange == 0 {
+		return nil, fmt.Errorf("line table at 0x%x has a zero line_range", off)
+	}
+
+	b.off = lt.ProgramOff
+	lt.run(b, trace)
+	return lt, b.err
+}
+
+func readLineEntryFormat(b *dwarfBuf) []lineEntryFormat {
+	var formats []lineEntryFormat
+	count := b.u8()
+	for i := uint8(0); i < count && b.err == nil; i++ {
+		formats = append(formats, lineEntryFormat{Content: b.uleb(), Form: b.uleb()})
+	}
+	return formats
+}
+
+func (d *dwarfData) readLineFileEntry(b *dwarfBuf, lt *lineTable, formats []lineEntryFormat) lineFileEntry {
+	var fe lineFileEntry
+	for _, f := range formats {
+		var val uint64
+		var str string
+		var block []byte
+
+		switch f.Form {
+		case formString:
+			str = b.cstr()
+		case formLineStrp:
+			str = cstrAt(d.LineStr, b.offset(lt.Is64))
+		case formStrp:
+			str = cstrAt(d.Str, b.offset(lt.Is64))
+		case formUdata:
+			val = b.uleb()
+		case formData1:
+			val = uint64(b.u8())
+		case formData2:
+			val = uint64(b.u16())
+		case formData4:
+			val = uint64(b.u32())
+		case formData8:
+			val = b.u64()
+		case formData16:
+			block = b.bytes(16)
+		case formBlock:
+			block = b.bytes(b.uleb())
+		default:
+			b.err = fmt.Errorf("unsupported form 0x%x in line table entry format", f.Form)
+			return fe
+		}
+
+		switch f.Content {
+		case lnctPath:
+			fe.Name = str
+		case lnctDirectoryIndex:
+			fe.Dir = val
+		case lnctTimestamp:
+			fe.Mtime = val
+		case lnctSize:
+			fe.Length = val
+		case lnctMD5:
+			fe.MD5 = block
+		}
+	}
+	return fe
+}
+
+/* executes the line number program, appending a row for every emitted line */
+func (lt *lineTable) run(b *dwarfBuf, trace lineTracer) {
+	maxOps := uint64(lt.MaxOpsPerInst)
+	if maxOps == 0 {
+		maxOps = 1
+	}
+	minInst := uint64(lt.MinInstLength)
+
+	var st lineRow
+	reset := func() {
+		st = lineRow{File: 1, Line: 1, IsStmt: lt.DefaultIsStmt}
+	}
+	advance := func(opAdvance uint64) uint64 {
+		addrAdv := minInst * ((st.OpIndex + opAdvance) / maxOps)
+		st.Address += addrAdv
+		st.OpIndex = (st.OpIndex + opAdvance) % maxOps
+		return addrAdv
+	}
+	emit := func() {
+		lt.Rows = append(lt.Rows, st)
+		st.Discriminator = 0
+		st.BasicBlock = false
+		st.PrologueEnd = false
+		st.EpilogueBegin = false
+	}
+	if trace == nil {
+		trace = func(uint64, string, ...interface{}) {}
+	}
+
+	reset()
+	for b.off < lt.End && b.err == nil {
+		opOff := b.off
+		op := b.u8()
+
+		if op >= lt.OpcodeBase {
+			adj := uint64(op - lt.OpcodeBase)
+			addrAdv := advance(adj / uint64(lt.LineRange))
+			lineAdv := int64(lt.LineBase) + int64(adj%uint64(lt.LineRange))
+			st.Line = uint64(int64(st.Line) + lineAdv)
+			trace(opOff, "Special opcode %d: advance Address by %d to 0x%x and Line by %d to %d",
+				adj, addrAdv, st.Address, lineAdv, st.Line)
+			emit()
+			continue
+		}
+
+		switch op {
+		case 0:
+			length := b.uleb()
+			end := b.off + length
+			if length == 0 {
+				trace(opOff, "Badly formed extended line op")
+				continue
+			}
+			sub := b.u8()
+			switch sub {
+			case lneEndSequence:
+				st.EndSequence = true
+				trace(opOff, "Extended opcode %d: End of Sequence", sub)
+				emit()
+				reset()
+			case lneSetAddress:
+				st.Address = b.addr(uint8(length - 1))
+				st.OpIndex = 0
+				trace(opOff, "Extended opcode %d: set Address to 0x%x", sub, st.Address)
+			case lneDefineFile:
+				fe := lineFileEntry{Name: b.cstr()}
+				fe.Dir = b.uleb()
+				fe.Mtime = b.uleb()
+				fe.Length = b.uleb()
+				lt.Files = append(lt.Files, fe)
+				trace(opOff, "Extended opcode %d: define new File Table entry %s", sub, fe.Name)
+			case lneSetDiscriminator:
+				st.Discriminator = b.uleb()
+				trace(opOff, "Extended opcode %d: set Discriminator to %d", sub, st.Discriminator)
+			default:
+				trace(opOff, "Extended opcode %d: unknown, skipping %d bytes", sub, length-1)
+			}
+			b.off = end
+		case lnsCopy:
+			trace(opOff, "Copy")
+			emit()
+		case lnsAdvancePC:
+			addrAdv := advance(b.uleb())
+			trace(opOff, "Advance PC by %d to 0x%x", addrAdv, st.Address)
+		case lnsAdvanceLine:
+			lineAdv := b.sleb()
+			st.Line = uint64(int64(st.Line) + lineAdv)
+			trace(opOff, "Advance Line by %d to %d", lineAdv, st.Line)
+		case lnsSetFile:
+			st.File = b.uleb()
+			trace(opOff, "Set File Name to entry %d in the File Name Table", st.File)
+		case lnsSetColumn:
+			st.Column = b.uleb()
+			trace(opOff, "Set column to %d", st.Column)
+		case lnsNegateStmt:
+			st.IsStmt = !st.IsStmt
+			trace(opOff, "Set is_stmt to %d", boolToInt(st.IsStmt))
+		case lnsSetBasicBlock:
+			st.BasicBlock = true
+			trace(opOff, "Set basic block")
+		case lnsConstAddPC:
+			addrAdv := advance(uint64(255-lt.OpcodeBase) / uint64(lt.LineRange))
+			trace(opOff, "Advance PC by constant %d to 0x%x", addrAdv, st.Address)
+		case lnsFixedAdvancePC:
+			adv := uint64(b.u16())
+			st.Address += adv
+			st.OpIndex = 0
+			trace(opOff, "Advance PC by fixed size amount %d to 0x%x", adv, st.Address)
+		case lnsSetPrologueEnd:
+			st.PrologueEnd = true
+			trace(opOff, "Set prologue_end to true")
+		case lnsSetEpilogueBegin:
+			st.EpilogueBegin = true
+			trace(opOff, "Set epilogue_begin to true")
+		case lnsSetISA:
+			st.ISA = b.uleb()
+			trace(opOff, "Set ISA to %d", st.ISA)
+		default:
+			/* opcode_base grew past what we know, the header tells us how many operands to skip */
+			var args []string
+			for i := uint8(0); i < lt.StdOpcodeLengths[op-1]; i++ {
+				args = append(args, fmt.Sprintf("0x%x", b.uleb()))
+			}
+			trace(opOff, "Unknown opcode %d with operands: %s", op, strings.Join(args, ", "))
+		}
+	}
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+/* file names are 1-based before DWARF 5, directory 0 is the compilation directory */
+func (lt *lineTable) fileEntry(idx uint64) (lineFileEntry, bool) {
+	if lt.Version < 5 {
+		if idx == 0 || idx > uint64(len(lt.Files)) {
+			return lineFileEntry{}, false
+		}
+		return lt.Files[idx-1], true
+	}
+	if idx >= uint64(len(lt.Files)) {
+		return lineFileEntry{}, false
+	}
+	return lt.Files[idx], true
+}
+
+func (lt *lineTable) dirName(idx uint64, compDir string) string {
+	if lt.Version < 5 {
+		if idx == 0 {
+			return compDir
+		}
+		idx--
+	}
+	if idx < uint64(len(lt.Dirs)) {
+		dir := lt.Dirs[idx]
+		if !path.IsAbs(dir) && compDir != "" {
+			dir = path.Join(compDir, dir)
+		}
+		return dir
+	}
+	return ""
+}
+
+func (lt *lineTable) filePath(idx uint64, compDir string) string {
+	fe, ok := lt.fileEntry(idx)
+	if !ok {
+		return "??"
+	}
+	if path.IsAbs(fe.Name) {
+		return fe.Name
+	}
+	if dir := lt.dirName(fe.Dir, compDir); dir != "" {
+		return path.Join(dir, fe.Name)
+	}
+	return fe.Name
+}
+
+/* row describing addr: the last row at or below addr whose successor in the sequence lies above it */
+func (lt *lineTable) lookup(addr uint64) *lineRow {
+	for i := 0; i+1 < len(lt.Rows); i++ {
+		r := &lt.Rows[i]
+		if r.EndSequence {
+			continue
+		}
+		if r.Address <= addr && addr < lt.Rows[i+1].Address {
+			return r
+		}
+	}
+	return nil
+}
+
+func (d *dwarfData) unitLineTable(u *dwarfUnit) *lineTable {
+	if u.lines != nil {
+		return u.lines
+	}
+	if u.Root == nil {
+		return nil
+	}
+	a := u.Root.attr(dwarf.AttrStmtList)
+	if a == nil {
+		return nil
+	}
+	lt, err := d.parseLineTable(a.Val, nil)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		return nil
+	}
+	u.lines = lt
+	return lt
+}
+
+func printRawLines(d *dwarfData) {
+	fmt.Println("Raw dump of debug contents of section .debug_line:")
+	for off := uint64(0); off < uint64(len(d.Line)); {
+		lt, err := d.parseLineTable(off, nil)
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+			return
+		}
+
+		fmt.Println()
+		fmt.Printf("  Offset:                      0x%x\n", lt.Offset)
+		fmt.Printf("  Length:                      %d\n", lt.Length)
+		fmt.Printf("  DWARF Version:               %d\n", lt.Version)
+		if lt.Version >= 5 {
+			fmt.Printf("  Address size (bytes):        %d\n", lt.AddrSize)
+			fmt.Printf("  Segment selector (bytes):    %d\n", lt.SegSelSize)
+		}
+		fmt.Printf("  Prologue Length:             %d\n", lt.HeaderLength)
+		fmt.Printf("  Minimum Instruction Length:  %d\n", lt.MinInstLength)
+		if lt.Version >= 4 {
+			fmt.Printf("  Maximum Ops per Instruction: %d\n", lt.MaxOpsPerInst)
+		}
+		fmt.Printf("  Initial value of 'is_stmt':  %d\n", boolToInt(lt.DefaultIsStmt))
+		fmt.Printf("  Line Base:                   %d\n", lt.LineBase)
+		fmt.Printf("  Line Range:                  %d\n", lt.LineRange)
+		fmt.Printf("  Opcode Base:                 %d\n", lt.OpcodeBase)
+
+		fmt.Println("\n Opcodes:")
+		for i, n := range lt.StdOpcodeLengths {
+			fmt.Printf("  Opcode %d has %d args\n", i+1, n)
+		}
+
+		if len(lt.Dirs) == 0 {
+			fmt.Println("\n The Directory Table is empty.")
+		} else {
+			fmt.Printf("\n The Directory Table (offset 0x%x):\n", lt.Offset)
+			base := 1
+			if lt.Version >= 5 {
+				base = 0
+			}
+			for i, dir := range lt.Dirs {
+				fmt.Printf("  %d\t%s\n", i+base, dir)
+			}
+		}
+
+		if len(lt.Files) == 0 {
+			fmt.Println("\n The File Name Table is empty.")
+		} else {
+			fmt.Println("\n The File Name Table:")
+			fmt.Println("  Entry\tDir\tTime\tSize\tName")
+			base := 1
+			if lt.Version >= 5 {
+				base = 0
+			}
+			for i, fe := range lt.Files {
+				fmt.Printf("  %d\t%d\t%d\t%d\t%s\n", i+base, fe.Dir, fe.Mtime, fe.Length, fe.Name)
+			}
+		}
+
+		fmt.Println("\n Line Number Statements:")
+		_, err = d.parseLineTable(off, func(opOff uint64, format string, args ...interface{}) {
+			fmt.Printf("  [0x%08x]  %s\n", opOff, fmt.Sprintf(format, args...))
+		})
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+			return
+		}
+		off = lt.End
+	}
+	fmt.Println()
+}
+
+func printDecodedLines(d *dwarfData) {
+	fmt.Println("Decoded dump of debug contents of section .debug_line:")
+
+	/* map line tables back to their unit so relative directories resolve against DW_AT_comp_dir */
+	compDirs := make(map[uint64]string)
+	for _, u := range d.Units {
+		if u.Root == nil {
+			continue
+		}
+		if a := u.Root.attr(dwarf.AttrStmtList); a != nil {
+			compDirs[a.Val] = d.stringVal(u.Root, dwarf.AttrCompDir)
+		}
+	}
+
+	for off := uint64(0); off < uint64(len(d.Line)); {
+		lt, err := d.parseLineTable(off, nil)
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+			return
+		}
+		compDir := compDirs[off]
+
+		primary := uint64(1)
+		if lt.Version >= 5 {
+			primary = 0
+		}
+		fmt.Printf("\nCU: %s:\n", lt.filePath(primary, compDir))
+		fmt.Printf("%-35s %11s  %18s  %4s\n", "File name", "Line number", "Starting address", "Stmt")
+
+		for _, r := range lt.Rows {
+			fe, _ := lt.fileEntry(r.File)
+			name := path.Base(fe.Name)
+			if r.EndSequence {
+				fmt.Printf("%-35s %11s  %#18x  %4s\n", name, "-", r.Address, "")
+				fmt.Println()
+				continue
+			}
+			stmt := ""
+			if r.IsStmt {
+				stmt = "x"
+			}
+			fmt.Printf("%-35s %11d  %#18x  %4s\n", name, r.Line, r.Address, stmt)
+		}
+		off = lt.End
+	}
+}
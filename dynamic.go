@@ -0,0 +1,129 @@
+package main
+
+import (
+	"debug/elf"
+	"io"
+)
+
+/* flattened view of an Elf32_Dyn or Elf64_Dyn */
+type dynEntry struct {
+	Tag elf.DynTag
+	Val uint64
+}
+
+/* the dynamic section, with its string table */
+type dynamicInfo struct {
+	Entries []dynEntry
+	Strtab  []byte
+}
+
+// loadDynamic reads .dynamic, or PT_DYNAMIC when the section headers are
+// gone, up to DT_NULL. The strings come from the section .dynamic links to,
+// or from DT_STRTAB mapped back to the file through PT_LOAD. A file without
+// a dynamic section gets nil.
+func loadDynamic(elfFs *elfFile) *dynamicInfo {
+	var data []byte
+	var strtab []byte
+	if ndx := getSectionByType(elf.SHT_DYNAMIC, elfFs); len(ndx) > 0 {
+		data = getSectionData(ndx[0], elfFs)
+		strtab = getSectionData(getSectionHeader(ndx[0], elfFs).Link, elfFs)
+	} else {
+		if elfFs.ElfProgs == nil {
+			elfFs.getProgHeaders()
+		}
+		for i := 0; i < numProgHeaders(elfFs); i++ {
+			if ph := getProgHeader(i, elfFs); ph.Type == elf.PT_DYNAMIC {
+				data = make([]byte, ph.Filesz)
+				_, err := io.ReadFull(io.NewSectionReader(elfFs.Fh, int64(ph.Off), int64(ph.Filesz)), data)
+				checkError(err)
+			}
+		}
+	}
+	if data == nil {
+		return nil
+	}
+
+	d := &dynamicInfo{Strtab: strtab}
+	order := elfFs.FileHdr.Endianness
+	size := 16
+	if elfFs.FileHdr.Arch == elf.ELFCLASS32 {
+		size = 8
+	}
+	for off := 0; off+size <= len(data); off += size {
+		var e dynEntry
+		if size == 8 {
+			e = dynEntry{elf.DynTag(int32(order.Uint32(data[off:]))), uint64(order.Uint32(data[off+4:]))}
+		} else {
+			e = dynEntry{elf.DynTag(int64(order.Uint64(data[off:]))), order.Uint64(data[off+8:])}
+		}
+		if e.Tag == elf.DT_NULL {
+			break
+		}
+		d.Entries = append(d.Entries, e)
+	}
+
+	if d.Strtab == nil {
+		addr, _ := d.value(elf.DT_STRTAB)
+		size, _ := d.value(elf.DT_STRSZ)
+		d.Strtab = readImage(elfFs, addr, size)
+	}
+	return d
+}
+
+/* the value of the first entry with tag t */
+func (d *dynamicInfo) value(t elf.DynTag) (uint64, bool) {
+	if d == nil {
+		return 0, false
+	}
+	for _, e := range d.Entries {
+		if e.Tag == t {
+			return e.Val, true
+		}
+	}
+	return 0, false
+}
+
+/* the strings of every entry with tag t, DT_NEEDED, DT_RPATH, DT_RUNPATH and the like */
+func (d *dynamicInfo) strings(t elf.DynTag) []string {
+	if d == nil {
+		return nil
+	}
+	var list []string
+	for _, e := range d.Entries {
+		if e.Tag == t && e.Val < uint64(len(d.Strtab)) {
+			list = append(list, getSectionName(uint32(e.Val), d.Strtab))
+		}
+	}
+	return list
+}
+
+/* whether DT_FLAGS has flag f set */
+func (d *dynamicInfo) hasFlag(f elf.DynFlag) bool {
+	v, _ := d.value(elf.DT_FLAGS)
+	return elf.DynFlag(v)&f != 0
+}
+
+/* whether DT_FLAGS_1 has flag f set */
+func (d *dynamicInfo) hasFlag1(f elf.DynFlag1) bool {
+	v, _ := d.value(elf.DT_FLAGS_1)
+	return elf.DynFlag1(v)&f != 0
+}
+
+/* size bytes of the loaded image at addr, nil when they are not backed by the file */
+func readImage(elfFs *elfFile, addr, size uint64) []byte {
+	if elfFs.ElfProgs == nil {
+		elfFs.getProgHeaders()
+	}
+	for i := 0; i < numProgHeaders(elfFs); i++ {
+		ph := getProgHeader(i, elfFs)
+		if ph.Type != elf.PT_LOAD || addr < ph.Vaddr || addr+size > ph.Vaddr+ph.Filesz || size == 0 {
+			continue
+		}
+		buf := make([]byte, size)
+		if _, err := elfFs.Fh.ReadAt(buf, int64(ph.Off+addr-ph.Vaddr)); err != nil {
+			return nil
+		}
+		return buf
+	}
+	return nil
+}
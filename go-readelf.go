@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"unsafe"
 )
@@ -27,14 +29,24 @@ type symtab struct {
 	SymbolName []string
 }
 
+/* the file being parsed, an *os.File or an ELF image held in memory */
+type elfReader interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+	io.Closer
+}
+
 type elfFile struct {
-	Fh          *os.File
+	Fh          elfReader
+	Path        string
 	Ident       [16]byte
 	FileHdr     enumIdent
 	Hdr         interface{}
 	err         error
 	ElfSections shdrTble
 	ElfSymbols  symtab
+	ElfProgs    interface{} // []elf.Prog32 or []elf.Prog64, loaded on demand
 	Size        int64
 
 	//      XSections map[uint32]interface{}
@@ -45,6 +57,10 @@ type elfFile struct {
 	DynSymbolsName map[uint32]string
 	Rels           map[uint32]interface{} // relocation entries are mapped to section index
 
+	Debug         *elfFile // separate debug file found through .gnu_debuglink or the build-id
+	debugSearched bool
+	MiniDebug     *elfFile // symbols-only ELF embedded xz compressed in .gnu_debugdata
+	miniSearched  bool
 }
 
 const (
@@ -96,82 +112,125 @@ func (elfFs *elfFile) mapHeader() {
 //Size per entry in Section Header Table = Shentsize
 //Calculate the size of Section Header Table = Shnum * Shentsize
 
+/* past SHN_LORESERVE sections the real count and string table index live in section header 0 */
+func sectionCounts(elfFs *elfFile, shoff int64, shnum, shstrndx uint32) (uint32, uint32) {
+	if shoff == 0 || shnum != 0 && shstrndx != uint32(elf.SHN_XINDEX) {
+		return shnum, shstrndx
+	}
+
+	var size uint64
+	var link uint32
+	switch elfFs.FileHdr.Arch {
+	case elf.ELFCLASS64:
+		var sh elf.Section64
+		checkError(binary.Read(io.NewSectionReader(elfFs.Fh, shoff, int64(unsafe.Sizeof(sh))), elfFs.FileHdr.Endianness, &sh))
+		size, link = sh.Size, sh.Link
+	case elf.ELFCLASS32:
+		var sh elf.Section32
+		checkError(binary.Read(io.NewSectionReader(elfFs.Fh, shoff, int64(unsafe.Sizeof(sh))), elfFs.FileHdr.Endianness, &sh))
+		size, link = uint64(sh.Size), sh.Link
+	}
+	if shnum == 0 {
+		shnum = uint32(size)
+	}
+	if shstrndx == uint32(elf.SHN_XINDEX) {
+		shstrndx = link
+	}
+	return shnum, shstrndx
+}
+
 func (elfFs *elfFile) getSections() {
 
 	if h, ok := elfFs.Hdr.(*elf.Header64); ok {
-		shdrTableSize := h.Shentsize * h.Shnum
+		shnum, shstrndx := sectionCounts(elfFs, int64(h.Shoff), uint32(h.Shnum), uint32(h.Shstrndx))
+		shdrTableSize := int64(h.Shentsize) * int64(shnum)
 
-		elfFs.ElfSections.Section = make([]elf.Section64, h.Shnum)
-		elfFs.ElfSections.SectionName = make([]string, h.Shnum)
+		elfFs.ElfSections.Section = make([]elf.Section64, shnum)
+		elfFs.ElfSections.SectionName = make([]string, shnum)
 
-		sr := io.NewSectionReader(elfFs.Fh, int64(h.Shoff), int64(shdrTableSize))
+		sr := io.NewSectionReader(elfFs.Fh, int64(h.Shoff), shdrTableSize)
 		err := binary.Read(sr, elfFs.FileHdr.Endianness, elfFs.ElfSections.Section.([]elf.Section64))
 		checkError(err)
+		if shnum == 0 {
+			return
+		}
 
-		shstrtab := make([]byte, elfFs.ElfSections.Section.([]elf.Section64)[h.Shstrndx].Size)
-		shstrtabOff := elfFs.ElfSections.Section.([]elf.Section64)[h.Shstrndx].Off
-		shstrtabSize := elfFs.ElfSections.Section.([]elf.Section64)[h.Shstrndx].Size
+		shstrtab := make([]byte, elfFs.ElfSections.Section.([]elf.Section64)[shstrndx].Size)
+		shstrtabOff := elfFs.ElfSections.Section.([]elf.Section64)[shstrndx].Off
+		shstrtabSize := elfFs.ElfSections.Section.([]elf.Section64)[shstrndx].Size
 
 		shstrtabSec := io.NewSectionReader(elfFs.Fh, int64(shstrtabOff), int64(shstrtabSize)+int64(shstrtabOff))
 		err = binary.Read(shstrtabSec, elfFs.FileHdr.Endianness, shstrtab)
 		checkError(err)
 
-		for i := 0; i < int(h.Shnum); i++ {
+		for i := 0; i < int(shnum); i++ {
 			sIndex := elfFs.ElfSections.Section.([]elf.Section64)[i].Name
 			elfFs.ElfSections.SectionName[i] = getSectionName(sIndex, shstrtab)
 		}
 	}
 
 	if h, ok := elfFs.Hdr.(*elf.Header32); ok {
-		shdrTableSize := h.Shentsize * h.Shnum
+		shnum, shstrndx := sectionCounts(elfFs, int64(h.Shoff), uint32(h.Shnum), uint32(h.Shstrndx))
+		shdrTableSize := int64(h.Shentsize) * int64(shnum)
 
-		elfFs.ElfSections.Section = make([]elf.Section32, h.Shnum)
-		elfFs.ElfSections.SectionName = make([]string, h.Shnum)
+		elfFs.ElfSections.Section = make([]elf.Section32, shnum)
+		elfFs.ElfSections.SectionName = make([]string, shnum)
 
-		sr := io.NewSectionReader(elfFs.Fh, int64(h.Shoff), int64(shdrTableSize))
+		sr := io.NewSectionReader(elfFs.Fh, int64(h.Shoff), shdrTableSize)
 		err := binary.Read(sr, elfFs.FileHdr.Endianness, elfFs.ElfSections.Section.([]elf.Section32))
 		checkError(err)
+		if shnum == 0 {
+			return
+		}
 
-		shstrtab := make([]byte, elfFs.ElfSections.Section.([]elf.Section32)[h.Shstrndx].Size)
-		shstrtabOff := elfFs.ElfSections.Section.([]elf.Section32)[h.Shstrndx].Off
-		shstrtabSize := elfFs.ElfSections.Section.([]elf.Section32)[h.Shstrndx].Size
+		shstrtab := make([]byte, elfFs.ElfSections.Section.([]elf.Section32)[shstrndx].Size)
+		shstrtabOff := elfFs.ElfSections.Section.([]elf.Section32)[shstrndx].Off
+		shstrtabSize := elfFs.ElfSections.Section.([]elf.Section32)[shstrndx].Size
 		shstrTableEnd := shstrtabOff + shstrtabSize
 
 		shstrtabSec := io.NewSectionReader(elfFs.Fh, int64(shstrtabOff), int64(shstrTableEnd))
 		err = binary.Read(shstrtabSec, elfFs.FileHdr.Endianness, shstrtab)
 		checkError(err)
 
-		for i := 0; i < int(h.Shnum); i++ {
+		for i := 0; i < int(shnum); i++ {
 			sIndex := elfFs.ElfSections.Section.([]elf.Section32)[i].Name
 			elfFs.ElfSections.SectionName[i] = getSectionName(sIndex, shstrtab)
 		}
 	}
 }
 
-func (elfFs *elfFile) getSymbols() {
+func (elfFs *elfFile) getSymbols(flt *symbolFilter) {
+	elfFs.getDynSymbols(flt)
 
-	var dsymtabNdx uint32
-	if dsymtabNdx = getSectionNdx(".dynsym", elfFs); dsymtabNdx != 0 {
-		var dynstrNdx uint32
-		dynstrNdx = getSectionNdx(".dynstr", elfFs)
-		elfFs.loadSymbols(dsymtabNdx, dynstrNdx, dynSym)
+	if src := elfFs.loadSymtab(); src != nil {
+		var from string
+		switch src {
+		case elfFs.MiniDebug:
+			from = " of MiniDebugInfo (.gnu_debugdata)"
+		case elfFs.Debug:
+			from = " of separate debug file " + src.Path
+		}
+		printSymbolTable(src, getSectionNdx(".symtab", src), sym, from, flt)
+	} else {
+		fmt.Println("Section .symtab mising -- Binary is stripped no exported symbols available !")
+	}
+}
 
-		fmt.Printf("%d entries found in .dynsym\n", len(elfFs.DynSymbols))
-		printSymbols(elfFs)
+func (elfFs *elfFile) getDynSymbols(flt *symbolFilter) {
+	if ndx := elfFs.loadDynSymbols(); ndx != 0 {
+		printSymbolTable(elfFs, ndx, dynSym, "", flt)
 	} else {
 		fmt.Println("No Dynamic symbols found - .dynsym missing from target")
 	}
+}
 
-	var symtabNdx uint32
-	var symstrNdx uint32
-	if symtabNdx = getSectionNdx(".symtab", elfFs); symtabNdx != 0 {
-		symstrNdx = getSectionNdx(".strtab", elfFs)
-		elfFs.loadSymbols(symtabNdx, symstrNdx, sym)
-		fmt.Printf("%d entries found in .symtab\n", len(elfFs.Symbols))
-		printSymbols(elfFs)
-	} else {
-		fmt.Println("Section .symtab mising -- Binary is stripped no exported symbols available !")
+/* loads .dynsym without printing it, returning its section index or 0 */
+func (elfFs *elfFile) loadDynSymbols() uint32 {
+	ndx := getSectionNdx(".dynsym", elfFs)
+	if ndx != 0 {
+		elfFs.loadSymbols(ndx, getSectionHeader(ndx, elfFs).Link, dynSym)
 	}
+	return ndx
 }
 
 func (elfFs *elfFile) loadSymbols(sectionNdx uint32, symstrNdx uint32, symType int) {
@@ -274,6 +333,79 @@ func (elfFs *elfFile) loadSymbols(sectionNdx uint32, symstrNdx uint32, symType i
 	}
 }
 
+/* GNU symbol binding and type, missing from debug/elf before go1.23 */
+const (
+	stbGNUUnique elf.SymBind = 10
+	sttGNUIFunc  elf.SymType = 10
+)
+
+/* packed relocation sections, missing from debug/elf */
+const (
+	shtRelr        elf.SectionType = 19
+	shtAndroidRel  elf.SectionType = 0x60000001
+	shtAndroidRela elf.SectionType = 0x60000002
+)
+
+/* the dynamic tags locating the same packed tables, for when the section headers are gone */
+const (
+	dtAndroidRel    elf.DynTag = 0x6000000f
+	dtAndroidRelsz  elf.DynTag = 0x60000010
+	dtAndroidRela   elf.DynTag = 0x60000011
+	dtAndroidRelasz elf.DynTag = 0x60000012
+)
+
+func sectionTypeString(t elf.SectionType) string {
+	switch t {
+	case shtRelr:
+		return "SHT_RELR"
+	case shtAndroidRel:
+		return "SHT_ANDROID_REL"
+	case shtAndroidRela:
+		return "SHT_ANDROID_RELA"
+	}
+	return t.String()
+}
+
+func symTypeString(t elf.SymType) string {
+	if t == sttGNUIFunc {
+		return "STT_GNU_IFUNC"
+	}
+	return t.String()
+}
+
+func symBindString(b elf.SymBind) string {
+	if b == stbGNUUnique {
+		return "STB_GNU_UNIQUE"
+	}
+	return b.String()
+}
+
+/* flattened view of an Elf32_Sym or Elf64_Sym */
+type elfSymbol struct {
+	Name  string
+	Value uint64
+	Size  uint64
+	Info  uint8
+	Other uint8
+	Shndx uint16
+}
+
+/* entry ndx of the loaded .symtab (sym) or .dynsym (dynSym) */
+func getSymbol(ndx uint32, symType int, elfFs *elfFile) (elfSymbol, bool) {
+	syms, names := elfFs.Symbols, elfFs.SymbolsName
+	if symType == dynSym {
+		syms, names = elfFs.DynSymbols, elfFs.DynSymbolsName
+	}
+
+	switch s := syms[ndx].(type) {
+	case *elf.Sym32:
+		return elfSymbol{names[s.Name], uint64(s.Value), uint64(s.Size), s.Info, s.Other, s.Shndx}, true
+	case *elf.Sym64:
+		return elfSymbol{names[s.Name], s.Value, s.Size, s.Info, s.Other, s.Shndx}, true
+	}
+	return elfSymbol{}, false
+}
+
 func getSymbolName(symIndex uint32, sectionStrtab []byte) string {
 	return getSectionName(symIndex, sectionStrtab)
 }
@@ -325,6 +457,83 @@ func getSectionByType(t elf.SectionType, elfFs *elfFile) []uint32 {
 	return indexList
 }
 
+/* class independent view of a section header, saves callers from switching on Section32/Section64 */
+type sectionHeader struct {
+	Name      string
+	Type      elf.SectionType
+	Flags     elf.SectionFlag
+	Addr      uint64
+	Off       uint64
+	Size      uint64
+	Link      uint32
+	Info      uint32
+	Addralign uint64
+	Entsize   uint64
+}
+
+func getSectionHeader(ndx uint32, elfFs *elfFile) sectionHeader {
+	var sh sectionHeader
+	if ndx < uint32(len(elfFs.ElfSections.SectionName)) {
+		sh.Name = elfFs.ElfSections.SectionName[ndx]
+	}
+
+	switch s := elfFs.ElfSections.Section.(type) {
+	case []elf.Section32:
+		if ndx < uint32(len(s)) {
+			sh.Type = elf.SectionType(s[ndx].Type)
+			sh.Flags = elf.SectionFlag(s[ndx].Flags)
+			sh.Addr = uint64(s[ndx].Addr)
+			sh.Off = uint64(s[ndx].Off)
+			sh.Size = uint64(s[ndx].Size)
+			sh.Link = s[ndx].Link
+			sh.Info = s[ndx].Info
+			sh.Addralign = uint64(s[ndx].Addralign)
+			sh.Entsize = uint64(s[ndx].Entsize)
+		}
+	case []elf.Section64:
+		if ndx < uint32(len(s)) {
+			sh.Type = elf.SectionType(s[ndx].Type)
+			sh.Flags = elf.SectionFlag(s[ndx].Flags)
+			sh.Addr = s[ndx].Addr
+			sh.Off = s[ndx].Off
+			sh.Size = s[ndx].Size
+			sh.Link = s[ndx].Link
+			sh.Info = s[ndx].Info
+			sh.Addralign = s[ndx].Addralign
+			sh.Entsize = s[ndx].Entsize
+		}
+	}
+	return sh
+}
+
+/* returns the contents of a section, decompressed when needed, SHT_NOBITS sections have none */
+func getSectionData(ndx uint32, elfFs *elfFile) []byte {
+	sh := getSectionHeader(ndx, elfFs)
+	if sh.Type == elf.SHT_NOBITS || sh.Type == elf.SHT_NULL || sh.Size == 0 {
+		return nil
+	}
+
+	data := make([]byte, sh.Size)
+	sr := io.NewSectionReader(elfFs.Fh, int64(sh.Off), int64(sh.Size))
+	_, err := io.ReadFull(sr, data)
+	checkError(err)
+
+	if c, ok := parseChdr(sh, data, elfFs); ok {
+		data, err = decompressSection(c, data)
+		if err != nil {
+			checkError(fmt.Errorf("section %s: %v", sh.Name, err))
+		}
+	}
+	return data
+}
+
+func getSectionDataByName(name string, elfFs *elfFile) []byte {
+	if ndx := getSectionNdx(name, elfFs); ndx != 0 {
+		return getSectionData(ndx, elfFs)
+	}
+	return nil
+}
+
 func (elfFs *elfFile) getRelocations() {
 
 	elfFs.Rels = make(map[uint32]interface{})
@@ -375,6 +584,217 @@ func (elfFs *elfFile) getRelocations() {
 			}
 		}
 	}
+
+	/* the same encoding for both classes, only the word size differs */
+	for _, ndx := range getSectionByType(shtRelr, elfFs) {
+		elfFs.Rels[ndx] = decodeRelr(getSectionData(ndx, elfFs), elfFs)
+	}
+	for _, ndx := range append(getSectionByType(shtAndroidRel, elfFs), getSectionByType(shtAndroidRela, elfFs)...) {
+		elfFs.Rels[ndx] = decodeAndroidRelocs(ndx, elfFs)
+	}
+}
+
+/* the addresses a SHT_RELR section relocates, each one gets an R_*_RELATIVE */
+type relrOffsets []uint64
+
+// decodeRelr expands the words of a SHT_RELR section. An even word is an
+// address to relocate and an odd one a bitmap over the words following the
+// last address: bit n set relocates the (n-1)th of them, and each bitmap
+// moves the base on by the 31 or 63 words it covers.
+func decodeRelr(data []byte, elfFs *elfFile) relrOffsets {
+	wordSize := 8
+	if elfFs.FileHdr.Arch == elf.ELFCLASS32 {
+		wordSize = 4
+	}
+	order := elfFs.FileHdr.Endianness
+
+	var offs relrOffsets
+	var base uint64
+	for i := 0; i+wordSize <= len(data); i += wordSize {
+		var w uint64
+		if wordSize == 8 {
+			w = order.Uint64(data[i:])
+		} else {
+			w = uint64(order.Uint32(data[i:]))
+		}
+
+		if w&1 == 0 {
+			offs = append(offs, w)
+			base = w + uint64(wordSize)
+			continue
+		}
+		for bit := 1; bit < wordSize*8; bit++ {
+			if w>>uint(bit)&1 != 0 {
+				offs = append(offs, base+uint64((bit-1)*wordSize))
+			}
+		}
+		base += uint64((wordSize*8 - 1) * wordSize)
+	}
+	return offs
+}
+
+/* group flags of the Android packed relocation format */
+const (
+	apsGroupedByInfo        = 1
+	apsGroupedByOffsetDelta = 2
+	apsGroupedByAddend      = 4
+	apsGroupHasAddend       = 8
+)
+
+// decodeAPS2 expands an APS2 packed table into the entries of a plain REL
+// or RELA one. After the magic come the relocation count and the starting
+// offset, then groups: a size and flags, followed by whatever the group's
+// entries share (offset delta, info, addend), then per entry the fields
+// they do not share. Offsets and addends are running sums, every number is
+// a SLEB128.
+func decodeAPS2(data []byte, rela bool, elfFs *elfFile) (interface{}, error) {
+	if len(data) < 4 || string(data[:4]) != "APS2" {
+		return nil, fmt.Errorf("packed relocations without the APS2 magic")
+	}
+
+	b := &dwarfBuf{data: data, off: 4, order: elfFs.FileHdr.Endianness}
+	count := b.sleb()
+	offset := uint64(b.sleb())
+	var rels []elf.Rela64
+	var addend int64
+	for int64(len(rels)) < count && b.err == nil {
+		size, flags := b.sleb(), b.sleb()
+		if size <= 0 || size > count-int64(len(rels)) {
+			return nil, fmt.Errorf("bad packed relocation group size %d", size)
+		}
+
+		var delta, info uint64
+		if flags&apsGroupedByOffsetDelta != 0 {
+			delta = uint64(b.sleb())
+		}
+		if flags&apsGroupedByInfo != 0 {
+			info = uint64(b.sleb())
+		}
+		if flags&apsGroupHasAddend == 0 {
+			addend = 0
+		} else if flags&apsGroupedByAddend != 0 {
+			addend += b.sleb()
+		}
+
+		for i := int64(0); i < size && b.err == nil; i++ {
+			if flags&apsGroupedByOffsetDelta != 0 {
+				offset += delta
+			} else {
+				offset += uint64(b.sleb())
+			}
+			if flags&apsGroupedByInfo == 0 {
+				info = uint64(b.sleb())
+			}
+			if flags&apsGroupHasAddend != 0 && flags&apsGroupedByAddend == 0 {
+				addend += b.sleb()
+			}
+			rels = append(rels, elf.Rela64{Off: offset, Info: info, Addend: addend})
+		}
+	}
+	if b.err != nil {
+		return nil, fmt.Errorf("truncated packed relocations")
+	}
+
+	if elfFs.FileHdr.Arch == elf.ELFCLASS32 {
+		if rela {
+			r := make([]elf.Rela32, len(rels))
+			for i, rel := range rels {
+				r[i] = elf.Rela32{Off: uint32(rel.Off), Info: uint32(rel.Info), Addend: int32(rel.Addend)}
+			}
+			return r, nil
+		}
+		r := make([]elf.Rel32, len(rels))
+		for i, rel := range rels {
+			r[i] = elf.Rel32{Off: uint32(rel.Off), Info: uint32(rel.Info)}
+		}
+		return r, nil
+	}
+	if rela {
+		return rels, nil
+	}
+	r := make([]elf.Rel64, len(rels))
+	for i, rel := range rels {
+		r[i] = elf.Rel64{Off: rel.Off, Info: rel.Info}
+	}
+	return r, nil
+}
+
+/* the entries of a SHT_ANDROID_REL or RELA section */
+func decodeAndroidRelocs(ndx uint32, elfFs *elfFile) interface{} {
+	sh := getSectionHeader(ndx, elfFs)
+	rels, err := decodeAPS2(getSectionData(ndx, elfFs), sh.Type == shtAndroidRela, elfFs)
+	if err != nil {
+		checkError(fmt.Errorf("section %s: %v", sh.Name, err))
+	}
+	return rels
+}
+
+/* a packed table found through the dynamic section, Addr is where DT_ANDROID_REL or RELA points */
+type dynamicRelocs struct {
+	Tag  string
+	Addr uint64
+	Rela bool
+	Rels interface{}
+}
+
+// dynamicAndroidRelocs decodes the tables DT_ANDROID_REL and DT_ANDROID_RELA
+// point at, read from the loaded image with the sizes DT_ANDROID_RELSZ and
+// DT_ANDROID_RELASZ give, so the packed relocations of a file stripped of
+// its section headers can still be listed.
+func dynamicAndroidRelocs(elfFs *elfFile) []dynamicRelocs {
+	dyn := loadDynamic(elfFs)
+	var list []dynamicRelocs
+	for _, t := range []struct {
+		name       string
+		addr, size elf.DynTag
+		rela       bool
+	}{
+		{"DT_ANDROID_REL", dtAndroidRel, dtAndroidRelsz, false},
+		{"DT_ANDROID_RELA", dtAndroidRela, dtAndroidRelasz, true},
+	} {
+		addr, ok := dyn.value(t.addr)
+		if !ok {
+			continue
+		}
+		size, _ := dyn.value(t.size)
+		data := readImage(elfFs, addr, size)
+		if data == nil {
+			checkError(fmt.Errorf("%s: 0x%x bytes at 0x%x are not in the file", t.name, size, addr))
+		}
+		rels, err := decodeAPS2(data, t.rela, elfFs)
+		if err != nil {
+			checkError(fmt.Errorf("%s: %v", t.name, err))
+		}
+		list = append(list, dynamicRelocs{t.name, addr, t.rela, rels})
+	}
+	return list
+}
+
+/* the R_*_RELATIVE type of a machine, what every RELR entry stands for */
+func relativeRelocType(mType elf.Machine) (uint32, bool) {
+	switch mType {
+	case elf.EM_X86_64:
+		return uint32(elf.R_X86_64_RELATIVE), true
+	case elf.EM_386:
+		return uint32(elf.R_386_RELATIVE), true
+	case elf.EM_ARM:
+		return uint32(elf.R_ARM_RELATIVE), true
+	case elf.EM_AARCH64:
+		return uint32(elf.R_AARCH64_RELATIVE), true
+	case elf.EM_PPC:
+		return uint32(elf.R_PPC_RELATIVE), true
+	case elf.EM_PPC64:
+		return uint32(elf.R_PPC64_RELATIVE), true
+	case elf.EM_RISCV:
+		return uint32(elf.R_RISCV_RELATIVE), true
+	case elf.EM_S390:
+		return uint32(elf.R_390_RELATIVE), true
+	case elf.EM_SPARCV9:
+		return uint32(elf.R_SPARC_RELATIVE), true
+	case elf.EM_LOONGARCH:
+		return uint32(elf.R_LARCH_RELATIVE), true
+	}
+	return 0, false
 }
 
 func resolveRelocType(rType uint32, mType elf.Machine) string {
@@ -404,233 +824,96 @@ func resolveRelocType(rType uint32, mType elf.Machine) string {
 	}
 }
 
+// printRelocations lists every relocation section in section order. Each
+// entry is followed by where it applies and what it resolves to, see
+// relocTarget, and section symbols are shown under their section's name.
+// Without section headers the packed tables the dynamic section points at
+// are listed instead, their symbols by index.
 func printRelocations(elfFs *elfFile) {
-	if _, ok := elfFs.ElfSections.Section.([]elf.Section32); ok {
-		for k, v := range elfFs.Rels {
-			sName := elfFs.ElfSections.SectionName[k]
-			switch r := v.(type) {
-			case []elf.Rel32:
-				l := len(r)
-				fmt.Printf("\nSection %s has %d relocation entries\n\n", sName, l)
-				fmt.Println("Offset\t\t\tInfo\t\t\t\tType\t\t\tSym.Value\t\t\tSym.Name")
-
-				for rNdx := 0; rNdx < l; rNdx++ {
-					o := r[rNdx].Off
-					t := elf.R_TYPE32(r[rNdx].Info)
-					s := elf.R_SYM32(r[rNdx].Info)
-					i := elf.R_INFO(s, t)
-
-					relName := resolveRelocType(t, elfFs.FileHdr.Machine)
-
-					var symName string
-					var symValue uint32
-					var symbol interface{}
-
-					secNdx := elfFs.ElfSections.Section.([]elf.Section32)[k].Link
-					switch elfFs.ElfSections.SectionName[secNdx] {
-					case ".dynsym":
-						symbol = elfFs.DynSymbols[s]
-						symName = elfFs.DynSymbolsName[symbol.(*elf.Sym32).Name]
-					case ".symtab":
-						symbol = elfFs.Symbols[s]
-						symName = elfFs.SymbolsName[symbol.(*elf.Sym32).Name]
-					default:
-						fmt.Printf("f when locating symbol tables in printRelocations()")
-						os.Exit(f)
-					}
-					symValue = symbol.(*elf.Sym32).Value
-					fmt.Printf("%016x\t%016x\t%s\t%016x\t\t%s\n", o, i, relName, symValue, symName)
-				}
-			case []elf.Rela32:
-				l := len(r)
-				fmt.Printf("\nSection %s has %d relocation entries\n\n", sName, l)
-				for rNdx := 0; rNdx < l; rNdx++ {
-					o := r[rNdx].Off
-					a := r[rNdx].Addend
-					t := elf.R_TYPE32(r[rNdx].Info)
-					s := elf.R_SYM32(r[rNdx].Info)
-					i := elf.R_INFO(s, t)
-
-					relName := resolveRelocType(t, elfFs.FileHdr.Machine)
-
-					var symName string
-					var symValue uint32
-					var symbol interface{}
-
-					secNdx := elfFs.ElfSections.Section.([]elf.Section32)[k].Link
-					switch elfFs.ElfSections.SectionName[secNdx] {
-					case ".dynsym":
-						symbol = elfFs.DynSymbols[s]
-						symName = elfFs.DynSymbolsName[symbol.(*elf.Sym32).Name]
-					case ".symtab":
-						symbol = elfFs.Symbols[s]
-						symName = elfFs.SymbolsName[symbol.(*elf.Sym32).Name]
-					default:
-						fmt.Printf("f when locating symbol tables in printRelocations()")
-						os.Exit(f)
-					}
-					symValue = symbol.(*elf.Sym32).Value
-					if s != uint32(elf.SHN_UNDEF) {
-						symName += " + "
-					}
-					fmt.Printf("%016x\t%016x\t%s\t%016x\t\t%s%d\n", o, i, relName, symValue, symName, a)
-				}
+	var secs []uint32
+	for k := range elfFs.Rels {
+		secs = append(secs, k)
+	}
+	sort.Slice(secs, func(a, b int) bool { return secs[a] < secs[b] })
+	idx := newSymbolIndex(elfFs)
+
+	for _, k := range secs {
+		sh := getSectionHeader(k, elfFs)
+		entries := relocEntries(elfFs.Rels[k], elfFs.FileHdr.Machine)
+		fmt.Printf("\nSection %s has %d relocation entries\n\n", sh.Name, len(entries))
+
+		symType := sym
+		switch getSectionHeader(sh.Link, elfFs).Type {
+		case elf.SHT_DYNSYM:
+			symType = dynSym
+		case elf.SHT_SYMTAB:
+		default:
+			if sh.Type != shtRelr {
+				fmt.Printf("Error when locating symbol tables in printRelocations()")
+				os.Exit(f)
 			}
 		}
+
+		_, rela := elfFs.Rels[k].([]elf.Rela32)
+		if _, ok := elfFs.Rels[k].([]elf.Rela64); ok {
+			rela = true
+		}
+		printRelocEntries(elfFs, sh, entries, symType, rela, idx)
 	}
 
-	if _, ok := elfFs.ElfSections.Section.([]elf.Section64); ok {
-		for k, v := range elfFs.Rels {
-			sName := elfFs.ElfSections.SectionName[k]
-			switch r := v.(type) {
-			case []elf.Rel64:
-				l := len(r)
-				fmt.Printf("\nSection %s has %d relocation entries\n\n", sName, l)
-				fmt.Println("Offset\t\t\tInfo\t\t\t\tType\t\t\tSym.Value\t\t\tSym.Name")
-
-				for rNdx := 0; rNdx < l; rNdx++ {
-					o := r[rNdx].Off
-					t := elf.R_TYPE64(r[rNdx].Info)
-					s := elf.R_SYM64(r[rNdx].Info)
-					i := elf.R_INFO(s, t)
-
-					relName := resolveRelocType(t, elfFs.FileHdr.Machine)
-
-					var symName string
-					var symValue uint64
-					var symbol interface{}
-
-					secNdx := elfFs.ElfSections.Section.([]elf.Section64)[k].Link
-					switch elfFs.ElfSections.SectionName[secNdx] {
-					case ".dynsym":
-						symbol = elfFs.DynSymbols[s]
-						symName = elfFs.DynSymbolsName[symbol.(*elf.Sym64).Name]
-					case ".symtab":
-						symbol = elfFs.Symbols[s]
-						symName = elfFs.SymbolsName[symbol.(*elf.Sym64).Name]
-					default:
-						fmt.Printf("f when locating symbol tables in printRelocations()")
-						os.Exit(f)
-					}
-					symValue = symbol.(*elf.Sym64).Value
-					fmt.Printf("%016x\t%016x\t%s\t%016x\t\t%s\n", o, i, relName, symValue, symName)
-				}
-			case []elf.Rela64:
-				l := len(r)
-				fmt.Printf("\nSection %s has %d relocation entries\n\n", sName, l)
-				fmt.Println("Offset\t\t\tInfo\t\t\t\tType\t\t\tSym.Value\t\t\tSym.Name + Addend")
-
-				for rNdx := 0; rNdx < l; rNdx++ {
-					o := r[rNdx].Off
-					a := r[rNdx].Addend
-					t := elf.R_TYPE64(r[rNdx].Info)
-					s := elf.R_SYM64(r[rNdx].Info)
-					i := elf.R_INFO(s, t)
-
-					relName := resolveRelocType(t, elfFs.FileHdr.Machine)
-
-					var symName string
-					var symValue uint64
-					var symbol interface{}
-
-					secNdx := elfFs.ElfSections.Section.([]elf.Section64)[k].Link
-					switch elfFs.ElfSections.SectionName[secNdx] {
-					case ".dynsym":
-						symbol = elfFs.DynSymbols[s]
-						symName = elfFs.DynSymbolsName[symbol.(*elf.Sym64).Name]
-					case ".symtab":
-						symbol = elfFs.Symbols[s]
-						symName = elfFs.SymbolsName[symbol.(*elf.Sym64).Name]
-					default:
-						fmt.Printf("Error when locating symbol tables in printRelocations()")
-						os.Exit(f)
-					}
-					symValue = symbol.(*elf.Sym64).Value
-					if s != uint32(elf.SHN_UNDEF) {
-						symName += " + "
-					}
-					fmt.Printf("%016x\t%016x\t%s\t%016x\t\t%s%d\n", o, i, relName, symValue, symName, a)
-				}
-			}
+	if len(elfFs.ElfSections.SectionName) != 0 {
+		return
+	}
+	for _, d := range dynamicAndroidRelocs(elfFs) {
+		entries := relocEntries(d.Rels, elfFs.FileHdr.Machine)
+		fmt.Printf("\n%s at 0x%x has %d relocation entries\n\n", d.Tag, d.Addr, len(entries))
+		sh := sectionHeader{Name: d.Tag, Type: shtAndroidRel, Addr: d.Addr}
+		if d.Rela {
+			sh.Type = shtAndroidRela
 		}
+		printRelocEntries(elfFs, sh, entries, dynSym, d.Rela, idx)
 	}
 }
 
-func printSymbols(elfFs *elfFile) {
-	var symPresent, dsymPresent bool
-	var ndsym, nsym int
-
-	if ndsym = len(elfFs.DynSymbols); ndsym > 0 {
-		dsymPresent = true
-	}
-	if nsym = len(elfFs.Symbols); nsym > 0 {
-		symPresent = true
+/* the rows of one relocation table, sh is its section or one standing in for a dynamic table */
+func printRelocEntries(elfFs *elfFile, sh sectionHeader, entries []relocEntry, symType int, rela bool, idx symbolIndex) {
+	switch {
+	case sh.Type == shtRelr:
+		fmt.Println("Offset\t\t\tInfo\t\t\t\tType\t\t\tTarget")
+	case rela:
+		fmt.Println("Offset\t\t\tInfo\t\t\t\tType\t\t\tSym.Value\t\t\tSym.Name + Addend\t\tTarget")
+	default:
+		fmt.Println("Offset\t\t\tInfo\t\t\t\tType\t\t\tSym.Value\t\t\tSym.Name\t\tTarget")
 	}
 
-	switch elfFs.FileHdr.Arch {
-	case elf.ELFCLASS32:
-		fmt.Printf("  Num:\tValue\t\tSize \tType\t\tBind\t\tVis\t\tNdx\t\tName\n")
-		if dsymPresent {
-			for sNdx := uint32(0); sNdx < uint32(ndsym); sNdx++ {
-				sym := elfFs.DynSymbols[sNdx]
-				v := sym.(*elf.Sym32).Value
-				s := sym.(*elf.Sym32).Size
-				t := elf.ST_TYPE(sym.(*elf.Sym32).Info)
-				b := elf.ST_BIND(sym.(*elf.Sym32).Info)
-				vis := elf.ST_VISIBILITY(sym.(*elf.Sym32).Info)
-				sec := sym.(*elf.Sym32).Shndx
-				nm := elfFs.DynSymbolsName[sym.(*elf.Sym32).Name]
-				fmt.Printf("  %-5d %08x\t%d\t%s\t%s\t%s\t%d\t%s\n", sNdx, v, s, t, b, vis, sec, nm)
-			}
-		}
-
-		if symPresent {
-			for sNdx := uint32(0); sNdx < uint32(nsym); sNdx++ {
-				sym := elfFs.Symbols[sNdx]
-				v := sym.(*elf.Sym32).Value
-				s := sym.(*elf.Sym32).Size
-				t := elf.ST_TYPE(sym.(*elf.Sym32).Info)
-				b := elf.ST_BIND(sym.(*elf.Sym32).Info)
-				vis := elf.ST_VISIBILITY(sym.(*elf.Sym32).Info)
-				sec := sym.(*elf.Sym32).Shndx
-				nm := elfFs.SymbolsName[sym.(*elf.Sym32).Name]
-				fmt.Printf("  %-5d %08x\t%d\t%s\t%s\t%s\t%d\t%s\n", sNdx, v, s, t, b, vis, sec, nm)
-			}
-		}
-	case elf.ELFCLASS64:
-		fmt.Printf("  Num:\tValue\t\tSize \tType\t\tBind\t\tVis\t\tNdx\t\tName\n")
-		if dsymPresent {
-			for sNdx := uint32(0); sNdx < uint32(ndsym); sNdx++ {
-				sym := elfFs.DynSymbols[sNdx]
-				v := sym.(*elf.Sym64).Value
-				s := sym.(*elf.Sym64).Size
-				t := elf.ST_TYPE(sym.(*elf.Sym64).Info)
-				b := elf.ST_BIND(sym.(*elf.Sym64).Info)
-				vis := elf.ST_VISIBILITY(sym.(*elf.Sym64).Info)
-				sec := sym.(*elf.Sym64).Shndx
-				nm := elfFs.DynSymbolsName[sym.(*elf.Sym64).Name]
-				fmt.Printf("  %-5d %08x\t%d\t%s\t%s\t%s\t%d\t%s\n", sNdx, v, s, t, b, vis, sec, nm)
-			}
+	for _, e := range entries {
+		relName := resolveRelocType(e.Type, elfFs.FileHdr.Machine)
+		i := elf.R_INFO(e.Sym, e.Type)
+		if sh.Type == shtRelr {
+			fmt.Printf("%016x\t%016x\t%s\t%s\n", e.Off, i, relName, relocTarget(elfFs, sh, e, elfSymbol{}, "", idx))
+			continue
 		}
 
-		if symPresent {
-			for sNdx := uint32(0); sNdx < uint32(nsym); sNdx++ {
-				sym := elfFs.Symbols[sNdx]
-				v := sym.(*elf.Sym64).Value
-				s := sym.(*elf.Sym64).Size
-				t := elf.ST_TYPE(sym.(*elf.Sym64).Info)
-				b := elf.ST_BIND(sym.(*elf.Sym64).Info)
-				vis := elf.ST_VISIBILITY(sym.(*elf.Sym64).Info)
-				sec := sym.(*elf.Sym64).Shndx
-				nm := elfFs.SymbolsName[sym.(*elf.Sym64).Name]
-				fmt.Printf("  %-5d %08x\t%d\t%s\t%s\t%s\t%d\t%s\n", sNdx, v, s, t, b, vis, sec, nm)
-			}
+		s, ok := getSymbol(e.Sym, symType, elfFs)
+		symName := displayName(symDisplayName(s, e.Sym, nil, elfFs))
+		if !ok && e.Sym != 0 {
+			/* no symbol table to name it from */
+			symName = fmt.Sprintf(".dynsym[%d]", e.Sym)
+		}
+		target := relocTarget(elfFs, sh, e, s, symName, idx)
+		if !rela {
+			fmt.Printf("%016x\t%016x\t%s\t%016x\t\t%s\t\t%s\n", e.Off, i, relName, s.Value, symName, target)
+			continue
+		}
+		if e.Sym != uint32(elf.SHN_UNDEF) {
+			symName += " + "
 		}
+		fmt.Printf("%016x\t%016x\t%s\t%016x\t\t%s%d\t\t%s\n", e.Off, i, relName, s.Value, symName, e.Addend, target)
 	}
-
 }
 
-func printSections(ElfSections shdrTble, numSec uint16, secOff interface{}) {
+func printSections(elfFs *elfFile, numSec int, secOff interface{}, wide bool) {
+	ElfSections := elfFs.ElfSections
 	switch v := secOff.(type) {
 	case uint32:
 		fmt.Printf("%d Sections @ Offset 0x%x\n", numSec, v)
@@ -640,9 +923,8 @@ func printSections(ElfSections shdrTble, numSec uint16, secOff interface{}) {
 	}
 
 	if section, ok := ElfSections.Section.([]elf.Section32); ok {
-		fmt.Println("[NR]  Name\t\t\tType\t\tAddress\t\t\tOffsets")
-		fmt.Println("      Size\t\t\tEntSize\t\tFlags Link Info\t\tAlign")
-		for i := uint16(0); i < numSec; i++ {
+		printSectionsHeading(wide, 8)
+		for i := 0; i < numSec; i++ {
 			a := section[i].Addr
 			o := section[i].Off
 			s := section[i].Size
@@ -654,21 +936,25 @@ func printSections(ElfSections shdrTble, numSec uint16, secOff interface{}) {
 			nm := ElfSections.SectionName[i]
 
 			/* SHT_REL string throws off alignment, this is a hack to maintain alignment for display purposes */
-			t := fmt.Sprintf("%s", elf.SectionType(section[i].Type))
+			t := sectionTypeString(elf.SectionType(section[i].Type))
 			if t == "SHT_REL" {
 				t += " "
 			}
 
-			fmt.Printf("[%-2d]  %-20s\t%s\t%08x\t\t%08x\n", i, nm, t, a, o)
-			fmt.Printf("      %08x\t\t\t%08x\t  %-5s%-5d%d\t\t%5d\n", s, e, f, l, info, align)
+			if wide {
+				fmt.Printf("[%2d] %-18s %-16s %08x %06x %06x %02x %3s %2d %3d %2d\n", i, nm, strings.TrimPrefix(t, "SHT_"), a, o, s, e, f, l, info, align)
+			} else {
+				fmt.Printf("[%-2d]  %-20s\t%s\t%08x\t\t%08x\n", i, nm, t, a, o)
+				fmt.Printf("      %08x\t\t\t%08x\t  %-5s%-5d%d\t\t%5d\n", s, e, f, l, info, align)
+			}
+			printCompression(elfFs, uint32(i))
 		}
 	}
 
 	if section, ok := ElfSections.Section.([]elf.Section64); ok {
-		fmt.Println("[NR]  Name\t\t\tType\t\tAddress\t\t\tOffsets")
-		fmt.Println("      Size\t\t\tEntSize\t\tFlags Link Info\t\tAlign")
-		for i := uint16(0); i < numSec; i++ {
-			t := elf.SectionType(section[i].Type)
+		printSectionsHeading(wide, 16)
+		for i := 0; i < numSec; i++ {
+			t := sectionTypeString(elf.SectionType(section[i].Type))
 			a := section[i].Addr
 			o := section[i].Off
 			s := section[i].Size
@@ -678,8 +964,13 @@ func printSections(ElfSections shdrTble, numSec uint16, secOff interface{}) {
 			info := section[i].Info
 			align := section[i].Addralign
 			nm := ElfSections.SectionName[i]
-			fmt.Printf("[%-2d]  %-20s\t%s\t%016x\t%08x\n", i, nm, t, a, o)
-			fmt.Printf("      %016x\t\t%016x  %-5s%-5d%d\t\t%5d\n", s, e, f, l, info, align)
+			if wide {
+				fmt.Printf("[%2d] %-18s %-16s %016x %06x %06x %02x %3s %2d %3d %2d\n", i, nm, strings.TrimPrefix(t, "SHT_"), a, o, s, e, f, l, info, align)
+			} else {
+				fmt.Printf("[%-2d]  %-20s\t%s\t%016x\t%08x\n", i, nm, t, a, o)
+				fmt.Printf("      %016x\t\t%016x  %-5s%-5d%d\t\t%5d\n", s, e, f, l, info, align)
+			}
+			printCompression(elfFs, uint32(i))
 		}
 	}
 
@@ -689,6 +980,28 @@ func printSections(ElfSections shdrTble, numSec uint16, secOff interface{}) {
 	fmt.Println("C (compressed), p (processor specific)")
 }
 
+/* -W keeps each section on one line, the way readelf -SW lays them out */
+func printSectionsHeading(wide bool, addrWidth int) {
+	if wide {
+		fmt.Printf("[Nr] %-18s %-16s %-*s Off    Size   ES Flg Lk Inf Al\n", "Name", "Type", addrWidth, "Address")
+		return
+	}
+	fmt.Println("[NR]  Name\t\t\tType\t\tAddress\t\t\tOffsets")
+	fmt.Println("      Size\t\t\tEntSize\t\tFlags Link Info\t\tAlign")
+}
+
+/* compressed sections get a third line with the sizes before and after decompression */
+func printCompression(elfFs *elfFile, ndx uint32) {
+	if c, ok := getSectionChdr(ndx, elfFs); ok {
+		kind := compressionName(c.Type)
+		if c.Legacy {
+			kind += " (.zdebug)"
+		}
+		fmt.Printf("      %s compressed: 0x%x bytes, uncompressed: 0x%x bytes, align %d\n",
+			kind, getSectionHeader(ndx, elfFs).Size, c.Size, c.Addralign)
+	}
+}
+
 func flagToKey(flag string) (key string) {
 	if strings.Contains(flag, "SHF_WRITE") {
 		key += "W"
@@ -791,94 +1104,613 @@ func printHeader(hdr interface{}) {
 	return
 }
 
-func main() {
-	if len(os.Args) < 3 {
-		usage()
-		os.Exit(f)
+/* the views selected on the command line */
+type displayOptions struct {
+	Header       bool
+	Sections     bool
+	Segments     bool
+	Symbols      bool
+	DynSyms      bool
+	Relocations  bool
+	Hash         bool
+	ArchiveIndex bool
+	Wide         bool
+	Filter       symbolFilter // narrows and orders what -s and --dyn-syms list
 
-	}
+	Size string // --size report format, "berkeley" or "sysv"
 
-	var target elfFile
+	Checksec string // --checksec report format, "text" or "json"
+
+	Policy string // rule file of --policy
+
+	Sysroot string // root file system --ldd resolves libraries in
+
+	NmSizes bool // --nm -S
+
+	BloatBy   string // data source of --bloat and --bloat-diff
+	BloatRows int    // rows listed before the rest is folded into one, 0 for all
 
-	bin := os.Args[2]
-	target.Fh, target.err = os.Open(bin)
-	checkError(target.err)
-	defer target.Fh.Close()
+	Long string   // one of the --options handled by longOptions
+	Args []string // operands following the target of a long option
+}
+
+/* views that are run per ELF file, as opposed to per archive */
+func (opts displayOptions) memberViews() bool {
+	return opts.Header || opts.Sections || opts.Segments || opts.Symbols || opts.DynSyms || opts.Relocations ||
+		opts.Hash || opts.Long != ""
+}
+
+var shortOptions = map[byte]func(*displayOptions){
+	'h': func(o *displayOptions) { o.Header = true },
+	'S': func(o *displayOptions) { o.Sections = true },
+	'l': func(o *displayOptions) { o.Segments = true },
+	's': func(o *displayOptions) { o.Symbols = true },
+	'r': func(o *displayOptions) { o.Relocations = true },
+	'I': func(o *displayOptions) { o.Hash = true },
+	'c': func(o *displayOptions) { o.ArchiveIndex = true },
+	'W': func(o *displayOptions) { o.Wide = true },
+	'C': func(o *displayOptions) { demangleNames = true },
+	'a': func(o *displayOptions) {
+		o.Header, o.Segments, o.Sections, o.Symbols, o.Relocations, o.Hash = true, true, true, true, true, true
+	},
+}
 
-	target.Fh.Read(target.Ident[:16])
+/* binutils spellings of the short options */
+var longFlagOptions = map[string]byte{
+	"--file-header":     'h',
+	"--sections":        'S',
+	"--section-headers": 'S',
+	"--program-headers": 'l',
+	"--segments":        'l',
+	"--symbols":         's',
+	"--syms":            's',
+	"--relocs":          'r',
+	"--histogram":       'I',
+	"--archive-index":   'c',
+	"--wide":            'W',
+	"--demangle":        'C',
+	"--all":             'a',
+}
+
+/* long options that select a mode of their own, the ones taking operands read them after the target */
+var modeOptions = map[string]bool{
+	"--debug-dump=line":        false,
+	"--debug-dump=rawline":     false,
+	"--debug-dump=decodedline": false,
+	"--debug-link":             false,
+	"--struct-holes":           true,
+	"--struct":                 true,
+	"--addr2line":              true,
+	"--hex-dump":               true,
+	"--lookup":                 true,
+	"--symbol-at":              true,
+	"--plt":                    false,
+	"--reloc-stats":            false,
+	"--min-versions":           false,
+	"--ldd":                    false,
+	"--ldd=dot":                false,
+	"--bindings":               false,
+	"--nm":                     false,
+	"--bloat":                  false,
+	"--bloat-diff":             true,
+}
+
+func badOption(opt string) {
+	fmt.Printf("Unrecognizable parameters: %s\n", opt)
+	usage()
+	os.Exit(f)
+}
+
+/* splits the command line into the selected views and the input files */
+func parseArgs(args []string) (displayOptions, []string) {
+	opts := displayOptions{BloatBy: bloatSources[0], BloatRows: 30}
+	var positional []string
+	operands := false
+
+	/* --nm takes nm's own short options, and sorts by name unless told otherwise */
+	short, long := shortOptions, longFlagOptions
+	for _, a := range args {
+		if a == "--" {
+			break
+		}
+		if a == "--nm" {
+			short, long = nmShortOptions, nmLongOptions
+			opts.Filter.Sort = "name"
+		}
+	}
+
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--":
+			positional = append(positional, args[i+1:]...)
+			i = len(args)
+		case a == "--help" || a == "-H":
+			usage()
+			os.Exit(0)
+		case a == "--dyn-syms":
+			opts.DynSyms = true
+		case a == "--defined" || a == "--defined-only":
+			opts.Filter.Defined = true
+		case a == "--undefined":
+			opts.Filter.Undefined = true
+		case a == "--reverse":
+			opts.Filter.Reverse = true
+		case strings.HasPrefix(a, "--bloat-by="):
+			opts.BloatBy = strings.TrimPrefix(a, "--bloat-by=")
+			if !contains(bloatSources, opts.BloatBy) {
+				fmt.Printf("--bloat-by: source must be one of %s\n", strings.Join(bloatSources, ", "))
+				os.Exit(f)
+			}
+		case strings.HasPrefix(a, "--sysroot="):
+			opts.Sysroot = strings.TrimPrefix(a, "--sysroot=")
+		case strings.HasPrefix(a, "--bloat-rows="):
+			n, err := strconv.Atoi(strings.TrimPrefix(a, "--bloat-rows="))
+			if err != nil || n < 0 {
+				fmt.Println("--bloat-rows: expected a row count, 0 for all")
+				os.Exit(f)
+			}
+			opts.BloatRows = n
+		case a == "--size" || strings.HasPrefix(a, "--size="):
+			switch format := strings.TrimPrefix(strings.TrimPrefix(a, "--size"), "="); format {
+			case "", "berkeley":
+				opts.Size = "berkeley"
+			case "sysv":
+				opts.Size = "sysv"
+			default:
+				fmt.Printf("--size: format must be berkeley or sysv\n")
+				os.Exit(f)
+			}
+		case a == "--checksec" || strings.HasPrefix(a, "--checksec="):
+			switch format := strings.TrimPrefix(strings.TrimPrefix(a, "--checksec"), "="); format {
+			case "", "text":
+				opts.Checksec = "text"
+			case "json":
+				opts.Checksec = "json"
+			default:
+				fmt.Printf("--checksec: format must be text or json\n")
+				os.Exit(f)
+			}
+		case a == "--policy" || strings.HasPrefix(a, "--policy="):
+			if file, ok := strings.CutPrefix(a, "--policy="); ok {
+				opts.Policy = file
+			} else if i+1 < len(args) {
+				i++
+				opts.Policy = args[i]
+			}
+			if opts.Policy == "" {
+				fmt.Println("--policy: expected a rule file")
+				os.Exit(f)
+			}
+		case strings.HasPrefix(a, "--"):
+			if c, ok := long[a]; ok {
+				short[c](&opts)
+				continue
+			}
+			if takesOperands, ok := modeOptions[a]; ok {
+				if opts.Long != "" && opts.Long != a {
+					fmt.Printf("%s cannot be combined with %s\n", a, opts.Long)
+					os.Exit(f)
+				}
+				opts.Long = a
+				operands = takesOperands
+				continue
+			}
+			name, val, hasVal := strings.Cut(a, "=")
+			ok, err := opts.Filter.set(name, val)
+			if !ok {
+				badOption(a)
+			}
+			if !hasVal {
+				err = fmt.Errorf("missing =value")
+			}
+			if err != nil {
+				fmt.Printf("%s: %v\n", name, err)
+				os.Exit(f)
+			}
+		case strings.HasPrefix(a, "-") && len(a) > 1:
+			for j := 1; j < len(a); j++ {
+				set, ok := short[a[j]]
+				if !ok {
+					badOption("-" + string(a[j]))
+				}
+				set(&opts)
+			}
+		default:
+			positional = append(positional, a)
+		}
+	}
 
-	if isElf(target.Ident[:4]) == false {
-		fmt.Println("This is not an Elf binary")
-		os.Exit(1)
+	if operands {
+		/* legacy layout: the target comes first and everything after it belongs to the mode */
+		if len(positional) == 0 || len(positional) == 1 && opts.Long != "--struct-holes" {
+			usage()
+			os.Exit(f)
+		}
+		opts.Args = positional[1:]
+		return opts, positional[:1]
+	}
+	/* --size, --checksec and --policy report on all files at once, on their own */
+	reports := map[string]bool{"--size": opts.Size != "", "--checksec": opts.Checksec != "", "--policy": opts.Policy != ""}
+	selected := 0
+	for _, set := range reports {
+		if set {
+			selected++
+		}
+	}
+	for mode, set := range reports {
+		if set && (opts.memberViews() || opts.ArchiveIndex || selected > 1) {
+			fmt.Printf("%s cannot be combined with other views\n", mode)
+			os.Exit(f)
+		}
+	}
+	if selected > 0 {
+		return opts, positional
+	}
+	/* filtering or sorting on its own asks for the symbol view */
+	if (opts.Filter.active() || opts.Filter.Sort != "" || opts.Filter.Reverse) && !opts.Symbols && !opts.DynSyms {
+		opts.Symbols = true
 	}
-	target.setArch()
-	target.mapHeader()
+	if !opts.memberViews() && !opts.ArchiveIndex {
+		usage()
+		os.Exit(f)
+	}
+	return opts, positional
+}
 
-	options := os.Args[1]
-	if options[0] != '-' {
+func main() {
+	os.Args = extractDebugDirs(os.Args)
+	opts, files := parseArgs(os.Args[1:])
+	if len(files) == 0 {
 		usage()
 		os.Exit(f)
 	}
 
-	var optHeader, optSections, optSymbols, optRelocations bool
-	for i := 1; i < len(options); i++ {
-		switch {
-		case options[i] == 'h':
-			optHeader = true
-		case options[i] == 'S':
-			optSections = true
-		case options[i] == 's':
-			optSymbols = true
-		case options[i] == 'r':
-			optRelocations = true
-		default:
-			fmt.Println("Unrecognizable parameters")
+	if opts.Size != "" {
+		if !printSizes(files, opts.Size) {
+			os.Exit(f)
+		}
+		return
+	}
+	if opts.Checksec != "" {
+		if !printChecksec(files, opts.Checksec) {
 			os.Exit(f)
 		}
+		return
+	}
+	if opts.Policy != "" {
+		if !printPolicy(files, opts.Policy) {
+			os.Exit(f)
+		}
+		return
 	}
 
-	if optHeader {
+	failed := false
+	for _, file := range files {
+		switch {
+		case len(files) > 1 && opts.Long == "--nm":
+			fmt.Printf("\n%s:\n", file)
+		case len(files) > 1:
+			fmt.Printf("\nFile: %s\n", file)
+		}
+		if !processFile(file, opts) {
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(f)
+	}
+}
+
+/* runs fn, turning a checkError panic into a message so the remaining inputs are still processed */
+func runGuarded(name string, fn func()) bool {
+	if err := catchError(fn); err != nil {
+		fmt.Printf("Error: %s: %v\n", name, err)
+		return false
+	}
+	return true
+}
+
+/* runs fn, returning the checkError panic it raised, if any */
+func catchError(fn func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	fn()
+	return nil
+}
+
+func processFile(path string, opts displayOptions) bool {
+	fh, err := os.Open(path)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return false
+	}
+	defer fh.Close()
+
+	var magic [8]byte
+	fh.ReadAt(magic[:], 0)
+	if isArchive(magic[:]) {
+		ok := true
+		if !runGuarded(path, func() { ok = processArchive(fh, path, opts) }) {
+			return false
+		}
+		return ok
+	}
+
+	var target elfFile
+	target.Path = path
+	target.Fh = fh
+	if _, err := io.ReadFull(target.Fh, target.Ident[:16]); err != nil || isElf(target.Ident[:4]) == false {
+		fmt.Printf("Error: %s: This is not an Elf binary\n", path)
+		return false
+	}
+	switch elf.Class(target.Ident[elf.EI_CLASS]) {
+	case elf.ELFCLASS32, elf.ELFCLASS64:
+	default:
+		fmt.Printf("Error: %s: Elf Arch Class Invalid !\n", path)
+		return false
+	}
+
+	return runGuarded(path, func() {
+		target.setArch()
+		target.mapHeader()
+		processElf(&target, opts)
+	})
+}
+
+func processElf(target *elfFile, opts displayOptions) {
+	if opts.Long != "" {
+		longOptions(target, opts)
+		return
+	}
+
+	target.getSections()
+
+	if opts.Header {
 		printHeader(target.Hdr)
 	}
 
-	if optSections {
-		target.getSections()
+	if opts.Sections {
 		switch target.FileHdr.Arch {
 		case elf.ELFCLASS32:
-			printSections(target.ElfSections, target.Hdr.(*elf.Header32).Shnum, target.Hdr.(*elf.Header32).Shoff)
+			printSections(target, len(target.ElfSections.SectionName), target.Hdr.(*elf.Header32).Shoff, opts.Wide)
 		case elf.ELFCLASS64:
-			printSections(target.ElfSections, target.Hdr.(*elf.Header64).Shnum, target.Hdr.(*elf.Header64).Shoff)
+			printSections(target, len(target.ElfSections.SectionName), target.Hdr.(*elf.Header64).Shoff, opts.Wide)
 		}
 	}
 
-	if optSymbols {
-		if optSections == false {
-			target.getSections()
-		}
-		target.getSymbols()
+	if opts.Segments {
+		printProgramHeaders(target, opts.Wide)
+	}
+
+	if opts.Symbols {
+		target.getSymbols(&opts.Filter)
+	} else if opts.DynSyms {
+		target.getDynSymbols(&opts.Filter)
 	}
 
-	if optRelocations {
-		if optSections == false {
-			target.getSections()
+	if opts.Relocations {
+		target.loadAllSymbols()
+		target.getRelocations()
+		printRelocations(target)
+
+	}
+
+	if opts.Hash {
+		printHashTables(target)
+	}
+}
+
+func longOptions(target *elfFile, opts displayOptions) {
+	target.getSections()
+	option, args := opts.Long, opts.Args
+
+	switch option {
+	case "--debug-dump=line", "--debug-dump=rawline", "--debug-dump=decodedline":
+		d, err := loadDwarf(target)
+		checkError(err)
+		if d == nil || d.Line == nil {
+			fmt.Println("No .debug_line section found in target")
+			return
+		}
+		if option == "--debug-dump=decodedline" {
+			printDecodedLines(d)
+		} else {
+			printRawLines(d)
 		}
 
-		if optSymbols == false {
-			target.getSymbols()
+	case "--struct":
+		if len(args) == 0 {
+			usage()
+			os.Exit(f)
 		}
-		target.getRelocations()
-		printRelocations(&target)
+		printStructs(target, args)
+
+	case "--struct-holes":
+		printStructHoles(target, args)
+
+	case "--debug-link":
+		printDebugLink(target)
 
+	case "--addr2line":
+		if len(args) == 0 {
+			usage()
+			os.Exit(f)
+		}
+		printAddr2line(target, args)
+
+	case "--lookup":
+		if len(args) == 0 {
+			usage()
+			os.Exit(f)
+		}
+		printLookup(target, args)
+
+	case "--symbol-at":
+		printSymbolAt(target, args)
+
+	case "--plt":
+		printPlt(target)
+
+	case "--min-versions":
+		printMinVersions(target)
+
+	case "--ldd", "--ldd=dot":
+		printLdd(target, opts.Sysroot, option == "--ldd=dot")
+
+	case "--bindings":
+		printBindings(target, opts.Sysroot)
+
+	case "--reloc-stats":
+		printRelocStats(target)
+
+	case "--nm":
+		printNm(target, opts)
+
+	case "--bloat":
+		printBloat(target, opts.BloatBy, opts.BloatRows)
+
+	case "--bloat-diff":
+		printBloatDiff(target, args[0], opts.BloatBy, opts.BloatRows)
+
+	case "--hex-dump":
+		if len(args) == 0 {
+			usage()
+			os.Exit(f)
+		}
+		missing := 0
+		for _, a := range args {
+			if !printHexDump(target, a) {
+				missing++
+			}
+		}
+		/* the sections found are still dumped, but the target fails like --struct does */
+		if missing > 0 {
+			checkError(fmt.Errorf("%d of %d sections not found", missing, len(args)))
+		}
+
+	default:
+		fmt.Println("Unrecognizable parameters")
+		os.Exit(f)
 	}
 }
 
 func usage() {
-	fmt.Printf("Usage: %s [-hrsS] <target-binary>\n", os.Args[0])
-	fmt.Println("\t-h: View Elf header")
-	fmt.Println("\t-r: View relocation entries")
-	fmt.Println("\t-s: View symbols")
-	fmt.Println("\t-S: View Sections")
-	fmt.Println("\t-l: View program headers")
+	fmt.Printf("Usage: %s [-hlSsrIcaWC] [--long-options] <target-binary>...\n", os.Args[0])
+	fmt.Printf("       %s --debug-dump=[rawline|decodedline] <target-binary>...\n", os.Args[0])
+	fmt.Printf("       %s --addr2line <target-binary> <addr>...\n", os.Args[0])
+	fmt.Printf("       %s --struct <target-binary> <name>...\n", os.Args[0])
+	fmt.Printf("       %s --debug-link <target-binary>...\n", os.Args[0])
+	fmt.Printf("       %s --struct-holes <target-binary> [min-bytes]\n", os.Args[0])
+	fmt.Printf("       %s --hex-dump <target-binary> <section>...\n", os.Args[0])
+	fmt.Printf("       %s --lookup <target-binary> <name[@version]>...\n", os.Args[0])
+	fmt.Printf("       %s --symbol-at <target-binary> <addr>...\n", os.Args[0])
+	fmt.Printf("       %s --plt <target-binary>...\n", os.Args[0])
+	fmt.Printf("       %s --reloc-stats <target-binary>...\n", os.Args[0])
+	fmt.Printf("       %s --min-versions <target-binary>...\n", os.Args[0])
+	fmt.Printf("       %s --ldd[=dot] [--sysroot=DIR] <target-binary>...\n", os.Args[0])
+	fmt.Printf("       %s --bindings [--sysroot=DIR] <target-binary>...\n", os.Args[0])
+	fmt.Printf("       %s --size[=berkeley|sysv] <target-binary>...\n", os.Args[0])
+	fmt.Printf("       %s --checksec[=text|json] <target-binary>...\n", os.Args[0])
+	fmt.Printf("       %s --policy <rules.json> <target-binary>...\n", os.Args[0])
+	fmt.Printf("       %s --nm [-DuSnprgC] [--defined-only] <target-binary>...\n", os.Args[0])
+	fmt.Printf("       %s --bloat [--bloat-by=SOURCE] <target-binary>...\n", os.Args[0])
+	fmt.Printf("       %s --bloat-diff [--bloat-by=SOURCE] <old-binary> <new-binary>\n", os.Args[0])
+	fmt.Println("\t-h, --file-header: View Elf header")
+	fmt.Println("\t-l, --program-headers, --segments: View program headers and the section to segment mapping")
+	fmt.Println("\t-S, --sections, --section-headers: View Sections")
+	fmt.Println("\t-s, --symbols, --syms: View symbols")
+	fmt.Println("\t--dyn-syms: View the dynamic symbols only")
+	fmt.Println("\t--sym-name=GLOB, --sym-regex=RE: Only list symbols whose name matches")
+	fmt.Println("\t--sym-type=FUNC,OBJECT,TLS,IFUNC,.. --sym-bind=GLOBAL,WEAK,.. --sym-vis=HIDDEN,.. --sym-section=.text,..: Filter symbols")
+	fmt.Println("\t--defined, --undefined: Only list defined or undefined symbols")
+	fmt.Println("\t--size-min=N, --size-max=N: Only list symbols within a size range, K and M suffixes allowed")
+	fmt.Println("\t--sort=addr|size|name, --reverse: Order the symbol listing")
+	fmt.Println("\t-r, --relocs: View relocation entries, where each applies and what it resolves to")
+	fmt.Println("\t-I, --histogram: View hash table histograms and check every dynamic symbol is reachable")
+	fmt.Println("\t-c, --archive-index: View the symbol index of an archive")
+	fmt.Println("\t-a, --all: Same as -h -l -S -s -r -I")
+	fmt.Println("\t-W, --wide: Keep section and program header rows on one line")
+	fmt.Println("\t-C, --demangle: Show C++ and Rust symbol names in source form")
+	fmt.Println("\t-H, --help: Show this help")
+	fmt.Println("\t--debug-dump=rawline: Dump the .debug_line programs opcode by opcode")
+	fmt.Println("\t--debug-dump=decodedline: Dump the decoded address to file:line table")
+	fmt.Println("\t--addr2line: Map addresses to function, file, line and inlined callers")
+	fmt.Println("\t--struct: Show member offsets, sizes, holes and padding of a struct")
+	fmt.Println("\t--struct-holes: List structs whose holes add up to at least min-bytes")
+	fmt.Println("\t--debug-link: Show the build-id, debug links and the separate debug file found")
+	fmt.Println("\t--hex-dump: Dump the contents of a section, given by name or number, decompressed if needed")
+	fmt.Println("\t--lookup: Resolve a dynamic symbol through .gnu.hash, .hash or .dynsym as ld.so would")
+	fmt.Println("\t--symbol-at: Name the symbol containing each address and the offset into it")
+	fmt.Println("\t--plt: Map each PLT stub to its GOT slot, relocation and symbol, and list the GOT as it is on disk")
+	fmt.Println("\t--reloc-stats: Count dynamic relocations by section and type, with their symbol lookup and startup costs")
+	fmt.Println("\t--min-versions: Newest symbol version needed from each library, e.g. GLIBC_2.34, and the symbols needing it")
+	fmt.Println("\t--ldd[=dot]: Resolve the needed libraries as ld.so would, without running the target, as a tree or a DOT graph")
+	fmt.Println("\t--bindings: Library each undefined symbol binds to, unresolved symbols and the ones interposed")
+	fmt.Println("\t--sysroot=DIR: Resolve --ldd and --bindings paths, ld.so.cache and the default directories inside DIR")
+	fmt.Println("\t--size[=berkeley|sysv]: Text, data and bss totals per file and archive member as size(1) prints them, or every section with sysv")
+	fmt.Println("\t--checksec[=text|json]: RELRO, canary, NX, PIE, RPATH/RUNPATH, TEXTREL, FORTIFY, CET and executable stack of each file")
+	fmt.Println("\t--policy rules.json: Check each file against a JSON rule file and fail when any breaks a rule")
+	fmt.Println("\t--nm: List symbols as nm does, with -D dynamic symbols, -u undefined only, --defined-only, -S sizes,")
+	fmt.Println("\t      -n by address, -p unsorted, -r reversed, -g external only and -C demangled")
+	fmt.Println("\t--bloat: Attribute every byte of the file and of the mapped image, unclaimed bytes are shown as [Unattributed]")
+	fmt.Println("\t--bloat-diff: Show how much each section, symbol, .. grew or shrank from the old to the new build")
+	fmt.Println("\t--bloat-by=sections|segments|symbols|compileunits|files: What --bloat attributes bytes to (default sections)")
+	fmt.Println("\t--bloat-rows=N: List the N largest rows and fold the rest into one (default 30, 0 for all)")
+	fmt.Println("\t--debug-dir=DIR: Also search DIR for separate debug files (default /usr/lib/debug)")
+}
+
+/* dumps one section given by name or number, false when there is no such section */
+func printHexDump(elfFs *elfFile, section string) bool {
+	ndx := getSectionNdx(section, elfFs)
+	if n, err := strconv.ParseUint(section, 10, 32); err == nil {
+		ndx = uint32(n)
+	}
+	if ndx == 0 || ndx >= uint32(len(elfFs.ElfSections.SectionName)) {
+		fmt.Printf("Section '%s' was not dumped because it does not exist\n", section)
+		return false
+	}
+
+	sh := getSectionHeader(ndx, elfFs)
+	data := getSectionData(ndx, elfFs)
+	if data == nil {
+		fmt.Printf("Section '%s' has no data to dump\n", sh.Name)
+		return true
+	}
+
+	fmt.Printf("\nHex dump of section '%s':\n", sh.Name)
+	if _, ok := getSectionChdr(ndx, elfFs); ok {
+		fmt.Printf(" NOTE: This section has been decompressed (0x%x -> 0x%x bytes)\n", sh.Size, len(data))
+	}
+	for off := 0; off < len(data); off += 16 {
+		line := data[off:]
+		if len(line) > 16 {
+			line = line[:16]
+		}
+		fmt.Printf("  0x%08x ", sh.Addr+uint64(off))
+		for i := 0; i < 16; i++ {
+			if i < len(line) {
+				fmt.Printf("%02x", line[i])
+			} else {
+				fmt.Print("  ")
+			}
+			if i%4 == 3 {
+				fmt.Print(" ")
+			}
+		}
+		for _, c := range line {
+			if c < 0x20 || c > 0x7e {
+				c = '.'
+			}
+			fmt.Printf("%c", c)
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+	return true
 }
 
 func checkError(e error) {
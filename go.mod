@@ -0,0 +1,3 @@
+module github.com/sad0p/go-readelf
+
+go 1.21
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"debug/elf"
+	"fmt"
+	"math/bits"
+
+	"github.com/sad0p/go-readelf/symlookup"
+)
+
+type sysvHash struct {
+	Section uint32
+	Nbucket uint32
+	Nchain  uint32
+	Buckets []uint32
+	Chains  []uint32
+}
+
+type gnuHash struct {
+	Section    uint32
+	Nbucket    uint32
+	Symoffset  uint32
+	BloomSize  uint32
+	BloomShift uint32
+	Bloom      []uint64
+	Buckets    []uint32
+	Chain      []uint32 // hash values of symbols from Symoffset on, low bit ends a bucket's chain
+}
+
+/* .hash entries are 32 bit words except on 64 bit s390 and alpha */
+func hashEntrySize(elfFs *elfFile) int {
+	if elfFs.FileHdr.Arch == elf.ELFCLASS64 && (elfFs.FileHdr.Machine == elf.EM_S390 || elfFs.FileHdr.Machine == elf.EM_ALPHA) {
+		return 8
+	}
+	return 4
+}
+
+func loadSysvHash(elfFs *elfFile) *sysvHash {
+	ndxs := getSectionByType(elf.SHT_HASH, elfFs)
+	if len(ndxs) == 0 {
+		return nil
+	}
+	data := getSectionData(ndxs[0], elfFs)
+	es := hashEntrySize(elfFs)
+	word := func(i int) uint32 {
+		if es == 8 {
+			return uint32(elfFs.FileHdr.Endianness.Uint64(data[i*8:]))
+		}
+		return elfFs.FileHdr.Endianness.Uint32(data[i*4:])
+	}
+	if len(data) < 2*es {
+		return nil
+	}
+
+	h := &sysvHash{Section: ndxs[0], Nbucket: word(0), Nchain: word(1)}
+	if uint64(2+h.Nbucket+h.Nchain)*uint64(es) > uint64(len(data)) {
+		fmt.Printf("Warning: .hash is truncated (nbucket %d, nchain %d, %d bytes)\n", h.Nbucket, h.Nchain, len(data))
+		return nil
+	}
+	for i := 0; i < int(h.Nbucket); i++ {
+		h.Buckets = append(h.Buckets, word(2+i))
+	}
+	for i := 0; i < int(h.Nchain); i++ {
+		h.Chains = append(h.Chains, word(2+int(h.Nbucket)+i))
+	}
+	return h
+}
+
+func loadGnuHash(elfFs *elfFile) *gnuHash {
+	ndxs := getSectionByType(elf.SHT_GNU_HASH, elfFs)
+	if len(ndxs) == 0 {
+		return nil
+	}
+	data := getSectionData(ndxs[0], elfFs)
+	if len(data) < 16 {
+		return nil
+	}
+	order := elfFs.FileHdr.Endianness
+
+	h := &gnuHash{
+		Section:    ndxs[0],
+		Nbucket:    order.Uint32(data),
+		Symoffset:  order.Uint32(data[4:]),
+		BloomSize:  order.Uint32(data[8:]),
+		BloomShift: order.Uint32(data[12:]),
+	}
+	bloomWord := 8
+	if elfFs.FileHdr.Arch == elf.ELFCLASS32 {
+		bloomWord = 4
+	}
+	off := 16
+	if uint64(off)+uint64(h.BloomSize)*uint64(bloomWord)+uint64(h.Nbucket)*4 > uint64(len(data)) {
+		fmt.Printf("Warning: .gnu.hash is truncated (nbucket %d, bloom size %d, %d bytes)\n", h.Nbucket, h.BloomSize, len(data))
+		return nil
+	}
+	for i := uint32(0); i < h.BloomSize; i++ {
+		if bloomWord == 4 {
+			h.Bloom = append(h.Bloom, uint64(order.Uint32(data[off:])))
+		} else {
+			h.Bloom = append(h.Bloom, order.Uint64(data[off:]))
+		}
+		off += bloomWord
+	}
+	for i := uint32(0); i < h.Nbucket; i++ {
+		h.Buckets = append(h.Buckets, order.Uint32(data[off:]))
+		off += 4
+	}
+	for ; off+4 <= len(data); off += 4 {
+		h.Chain = append(h.Chain, order.Uint32(data[off:]))
+	}
+	return h
+}
+
+/* symbol indexes in the chain of bucket b, stops at the end marker or the end of the section */
+func (h *gnuHash) bucketChain(b uint32) []uint32 {
+	var syms []uint32
+	i := h.Buckets[b]
+	if i == 0 || i < h.Symoffset {
+		return nil
+	}
+	for ; int(i-h.Symoffset) < len(h.Chain); i++ {
+		syms = append(syms, i)
+		if h.Chain[i-h.Symoffset]&1 != 0 {
+			break
+		}
+	}
+	return syms
+}
+
+/* the two bits a name sets in the bloom filter, both must be present for a lookup to proceed */
+func (h *gnuHash) bloomHit(hash uint32, wordBits uint32) bool {
+	if h.BloomSize == 0 {
+		return false
+	}
+	w := h.Bloom[(hash/wordBits)%h.BloomSize]
+	mask := uint64(1)<<(hash%wordBits) | uint64(1)<<((hash>>h.BloomShift)%wordBits)
+	return w&mask == mask
+}
+
+func bloomWordBits(elfFs *elfFile) uint32 {
+	if elfFs.FileHdr.Arch == elf.ELFCLASS32 {
+		return 32
+	}
+	return 64
+}
+
+/* walks a .hash chain, the step limit guards against cycles in corrupt tables */
+func (h *sysvHash) bucketChain(b uint32) []uint32 {
+	var syms []uint32
+	for i := h.Buckets[b]; i != 0 && i < h.Nchain && len(syms) <= int(h.Nchain); i = h.Chains[i] {
+		syms = append(syms, i)
+	}
+	return syms
+}
+
+func printHistogram(title string, lengths []int) {
+	maxLen := 0
+	total := 0
+	for _, l := range lengths {
+		if l > maxLen {
+			maxLen = l
+		}
+		total += l
+	}
+	counts := make([]int, maxLen+1)
+	for _, l := range lengths {
+		counts[l]++
+	}
+
+	fmt.Printf("Histogram for %sbucket list length (total of %d buckets):\n", title, len(lengths))
+	fmt.Println(" Length  Number     % of total  Coverage")
+	covered := 0
+	for l, n := range counts {
+		pct := 0.0
+		if len(lengths) > 0 {
+			pct = float64(n) * 100 / float64(len(lengths))
+		}
+		fmt.Printf("%7d  %-10d (%5.1f%%)", l, n, pct)
+		if l > 0 && total > 0 {
+			covered += l * n
+			fmt.Printf("    %5.1f%%", float64(covered)*100/float64(total))
+		}
+		fmt.Println()
+	}
+}
+
+/* loads the symbol table a hash section indexes through its sh_link */
+func loadHashedSymbols(hashNdx uint32, elfFs *elfFile) int {
+	symNdx := getSectionHeader(hashNdx, elfFs).Link
+	strNdx := getSectionHeader(symNdx, elfFs).Link
+	elfFs.loadSymbols(symNdx, strNdx, dynSym)
+	return len(elfFs.DynSymbols)
+}
+
+func isExportedSym(s elfSymbol) bool {
+	b := elf.ST_BIND(s.Info)
+	return elf.SectionIndex(s.Shndx) != elf.SHN_UNDEF && (b == elf.STB_GLOBAL || b == elf.STB_WEAK || b == stbGNUUnique)
+}
+
+func checkSysvHash(h *sysvHash, nsyms int, elfFs *elfFile) []string {
+	var problems []string
+	if int(h.Nchain) != nsyms {
+		problems = append(problems, fmt.Sprintf("nchain is %d but .dynsym has %d entries", h.Nchain, nsyms))
+	}
+	if h.Nbucket == 0 {
+		return append(problems, "nbucket is 0, no symbol can be found")
+	}
+
+	for i := uint32(1); int(i) < nsyms; i++ {
+		s, _ := getSymbol(i, dynSym, elfFs)
+		if s.Name == "" {
+			continue
+		}
+		found := false
+		for _, j := range h.bucketChain(symlookup.ElfHash(s.Name) % h.Nbucket) {
+			if j == i {
+				found = true
+				break
+			}
+		}
+		if !found {
+			problems = append(problems, fmt.Sprintf("symbol %d (%s) is not reachable through .hash", i, s.Name))
+		}
+	}
+	return problems
+}
+
+func checkGnuHash(h *gnuHash, nsyms int, elfFs *elfFile) []string {
+	var problems []string
+	if h.Nbucket == 0 {
+		return append(problems, "nbucket is 0, no symbol can be found")
+	}
+	if h.BloomSize == 0 || h.BloomSize&(h.BloomSize-1) != 0 {
+		problems = append(problems, fmt.Sprintf("bloom size %d is not a power of two", h.BloomSize))
+	}
+	if int(h.Symoffset)+len(h.Chain) < nsyms {
+		problems = append(problems, fmt.Sprintf("chain covers symbols up to %d but .dynsym has %d entries", int(h.Symoffset)+len(h.Chain), nsyms))
+	}
+
+	wordBits := bloomWordBits(elfFs)
+	for i := uint32(1); int(i) < nsyms; i++ {
+		s, _ := getSymbol(i, dynSym, elfFs)
+		if i < h.Symoffset {
+			if isExportedSym(s) {
+				problems = append(problems, fmt.Sprintf("symbol %d (%s) is defined but below symoffset %d, lookups cannot find it", i, s.Name, h.Symoffset))
+			}
+			continue
+		}
+
+		hash := symlookup.GnuHash(s.Name)
+		if int(i-h.Symoffset) < len(h.Chain) && h.Chain[i-h.Symoffset]|1 != hash|1 {
+			problems = append(problems, fmt.Sprintf("symbol %d (%s) has stale chain hash 0x%08x, expected 0x%08x", i, s.Name, h.Chain[i-h.Symoffset]&^1, hash&^1))
+		}
+
+		found := false
+		for _, j := range h.bucketChain(hash % h.Nbucket) {
+			if j == i {
+				found = true
+				break
+			}
+		}
+		if !found {
+			problems = append(problems, fmt.Sprintf("symbol %d (%s) is not in the chain of bucket %d", i, s.Name, hash%h.Nbucket))
+		}
+
+		if isExportedSym(s) && h.BloomSize != 0 && !h.bloomHit(hash, wordBits) {
+			problems = append(problems, fmt.Sprintf("symbol %d (%s) is missing from the bloom filter", i, s.Name))
+		}
+	}
+	return problems
+}
+
+func printProblems(name string, problems []string) {
+	if len(problems) == 0 {
+		fmt.Printf("%s: every symbol is reachable\n", name)
+		return
+	}
+	fmt.Printf("%s: %d inconsistencies\n", name, len(problems))
+	for _, p := range problems {
+		fmt.Printf("  %s\n", p)
+	}
+}
+
+func printHashTables(elfFs *elfFile) {
+	sysv := loadSysvHash(elfFs)
+	gnu := loadGnuHash(elfFs)
+	if sysv == nil && gnu == nil {
+		fmt.Println("No .hash or .gnu.hash section found in target")
+		return
+	}
+
+	if sysv != nil {
+		nsyms := loadHashedSymbols(sysv.Section, elfFs)
+		fmt.Printf("Section '%s' (SHT_HASH): nbucket %d, nchain %d\n", elfFs.ElfSections.SectionName[sysv.Section], sysv.Nbucket, sysv.Nchain)
+		var lengths []int
+		for b := range sysv.Buckets {
+			lengths = append(lengths, len(sysv.bucketChain(uint32(b))))
+		}
+		printHistogram("", lengths)
+		printProblems(".hash", checkSysvHash(sysv, nsyms, elfFs))
+		fmt.Println()
+	}
+
+	if gnu != nil {
+		nsyms := loadHashedSymbols(gnu.Section, elfFs)
+		fmt.Printf("Section '%s' (SHT_GNU_HASH): nbucket %d, symoffset %d, bloom size %d (%d bit words), bloom shift %d\n",
+			elfFs.ElfSections.SectionName[gnu.Section], gnu.Nbucket, gnu.Symoffset, gnu.BloomSize, bloomWordBits(elfFs), gnu.BloomShift)
+		set := 0
+		for _, w := range gnu.Bloom {
+			set += bits.OnesCount64(w)
+		}
+		if gnu.BloomSize != 0 {
+			fmt.Printf("Bloom filter: %d of %d bits set (%.1f%%)\n", set, len(gnu.Bloom)*int(bloomWordBits(elfFs)),
+				float64(set)*100/float64(len(gnu.Bloom)*int(bloomWordBits(elfFs))))
+		}
+		var lengths []int
+		for b := range gnu.Buckets {
+			lengths = append(lengths, len(gnu.bucketChain(uint32(b))))
+		}
+		printHistogram("`.gnu.hash' ", lengths)
+		printProblems(".gnu.hash", checkGnuHash(gnu, nsyms, elfFs))
+	}
+}
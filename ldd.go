@@ -0,0 +1,530 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/* the two layouts of /etc/ld.so.cache, glibc writes the new one after the old one or on its own */
+const (
+	ldCacheOldMagic = "ld.so-1.7.0"
+	ldCacheNewMagic = "glibc-ld.so.cache1.1"
+)
+
+/* AT_PLATFORM as ld.so would see it, $PLATFORM in search paths */
+var ldPlatforms = map[elf.Machine]string{
+	elf.EM_X86_64: "x86_64", elf.EM_386: "i686", elf.EM_AARCH64: "aarch64", elf.EM_ARM: "v7l",
+	elf.EM_PPC64: "power8", elf.EM_PPC: "ppc", elf.EM_S390: "z13", elf.EM_RISCV: "riscv64", elf.EM_LOONGARCH: "loongarch64",
+}
+
+// ldObject is one object of the dependency tree. Needed holds the object
+// each DT_NEEDED entry resolved to, nil when it was not found; objects
+// loaded once are shared by everyone needing them, Loader being the one
+// that first pulled them in, as ld.so records it.
+type ldObject struct {
+	Name     string // the DT_NEEDED string, or the path given for the target
+	Path     string // where the object was found, on the host
+	Via      string // what found it: RPATH, LD_LIBRARY_PATH, RUNPATH, ld.so.cache, default, path or interpreter
+	File     *elfFile
+	Loader   *ldObject
+	Needed   []string
+	Deps     []*ldObject
+	Soname   string
+	RPATH    []string
+	RUNPATH  []string
+	NoDefLib bool
+	Symbolic bool // DT_SYMBOLIC, the object's own definitions come first in its lookups
+}
+
+/* the loaded objects in the order ld.so maps them, the target first, breadth first after it */
+type ldTree struct {
+	Sysroot string
+	Root    *ldObject
+	Interp  *ldObject
+	Order   []*ldObject
+	Missing int
+
+	cache   map[string][]string
+	libPath []string
+	lib     string               // what $LIB expands to
+	loaded  map[string]*ldObject // by DT_NEEDED name and soname
+	syms    map[*ldObject]*ldSymbols
+}
+
+// parseLdCache maps each library name of an ld.so.cache to the paths the
+// cache holds for it, in cache order. The new format is preferred when the
+// file carries both. Entries for other ABIs are not told apart here, the
+// candidates are checked against the object needing them instead.
+func parseLdCache(data []byte, order binary.ByteOrder) (map[string][]string, error) {
+	entries := map[string][]string{}
+	str := func(base, off uint64) string {
+		if base+off >= uint64(len(data)) {
+			return ""
+		}
+		s := data[base+off:]
+		if end := bytes.IndexByte(s, 0); end >= 0 {
+			s = s[:end]
+		}
+		return string(s)
+	}
+
+	newAt := uint64(0)
+	if bytes.HasPrefix(data, []byte(ldCacheOldMagic)) {
+		if len(data) < 16 {
+			return nil, fmt.Errorf("truncated header")
+		}
+		nlibs := uint64(order.Uint32(data[12:]))
+		strs := 16 + nlibs*12
+		if strs > uint64(len(data)) {
+			return nil, fmt.Errorf("%d entries do not fit the file", nlibs)
+		}
+		newAt = (strs + 7) &^ 7
+		if !bytes.HasPrefix(data[min(newAt, uint64(len(data))):], []byte(ldCacheNewMagic)) {
+			for i := uint64(0); i < nlibs; i++ {
+				e := data[16+i*12:]
+				key := str(strs, uint64(order.Uint32(e[4:])))
+				entries[key] = append(entries[key], str(strs, uint64(order.Uint32(e[8:]))))
+			}
+			return entries, nil
+		}
+	}
+	if !bytes.HasPrefix(data[newAt:], []byte(ldCacheNewMagic)) {
+		return nil, fmt.Errorf("unknown format")
+	}
+
+	hdr := data[newAt:]
+	if len(hdr) < 48 {
+		return nil, fmt.Errorf("truncated header")
+	}
+	nlibs := uint64(order.Uint32(hdr[20:]))
+	if 48+nlibs*24 > uint64(len(hdr)) {
+		return nil, fmt.Errorf("%d entries do not fit the file", nlibs)
+	}
+	for i := uint64(0); i < nlibs; i++ {
+		e := hdr[48+i*24:]
+		key := str(newAt, uint64(order.Uint32(e[4:])))
+		entries[key] = append(entries[key], str(newAt, uint64(order.Uint32(e[8:]))))
+	}
+	return entries, nil
+}
+
+/* expands $ORIGIN, $LIB and $PLATFORM, with or without braces */
+func expandLdTokens(dir, origin, lib string, elfFs *elfFile) string {
+	platform, ok := ldPlatforms[elfFs.FileHdr.Machine]
+	if !ok {
+		platform = strings.ToLower(strings.TrimPrefix(elfFs.FileHdr.Machine.String(), "EM_"))
+	}
+	r := strings.NewReplacer("${ORIGIN}", origin, "$ORIGIN", origin, "${LIB}", lib, "$LIB", lib,
+		"${PLATFORM}", platform, "$PLATFORM", platform)
+	return r.Replace(dir)
+}
+
+/* the directories a DT_RPATH, DT_RUNPATH or LD_LIBRARY_PATH string lists */
+func splitLdPath(list []string) []string {
+	var dirs []string
+	for _, l := range list {
+		for _, d := range strings.FieldsFunc(l, func(r rune) bool { return r == ':' || r == ';' }) {
+			dirs = append(dirs, d)
+		}
+	}
+	return dirs
+}
+
+/* EF_ARM_ABI_FLOAT_HARD, set in e_flags by the hard-float ABI */
+const efARMABIFloatHard = 0x400
+
+// multiarchTriplet names the target's ABI the way Debian and Ubuntu name
+// the directories under /lib and /usr/lib they install its libraries in,
+// empty for machines without one.
+func multiarchTriplet(elfFs *elfFile) string {
+	is64 := elfFs.FileHdr.Arch == elf.ELFCLASS64
+	le := elfFs.FileHdr.Endianness == binary.LittleEndian
+	switch elfFs.FileHdr.Machine {
+	case elf.EM_X86_64:
+		return yesNo(is64, "x86_64-linux-gnu", "x86_64-linux-gnux32")
+	case elf.EM_386:
+		return "i386-linux-gnu"
+	case elf.EM_AARCH64:
+		return yesNo(le, "aarch64-linux-gnu", "aarch64_be-linux-gnu")
+	case elf.EM_ARM:
+		if h, ok := elfFs.Hdr.(*elf.Header32); ok && h.Flags&efARMABIFloatHard != 0 {
+			return "arm-linux-gnueabihf"
+		}
+		return "arm-linux-gnueabi"
+	case elf.EM_PPC64:
+		return yesNo(le, "powerpc64le-linux-gnu", "powerpc64-linux-gnu")
+	case elf.EM_PPC:
+		return "powerpc-linux-gnu"
+	case elf.EM_S390:
+		return "s390x-linux-gnu"
+	case elf.EM_RISCV:
+		return yesNo(is64, "riscv64-linux-gnu", "riscv32-linux-gnu")
+	case elf.EM_MIPS:
+		if is64 {
+			return yesNo(le, "mips64el-linux-gnuabi64", "mips64-linux-gnuabi64")
+		}
+		return yesNo(le, "mipsel-linux-gnu", "mips-linux-gnu")
+	case elf.EM_SPARCV9:
+		return "sparc64-linux-gnu"
+	case elf.EM_LOONGARCH:
+		return "loongarch64-linux-gnu"
+	}
+	return ""
+}
+
+// ldDefaultDirs lists the directories ld.so searches last. Multiarch
+// distributions build glibc with the triplet directories first, the others
+// with /lib64 on 64-bit targets; searching both covers either layout.
+func ldDefaultDirs(elfFs *elfFile) []string {
+	var dirs []string
+	if t := multiarchTriplet(elfFs); t != "" {
+		dirs = append(dirs, "/lib/"+t, "/usr/lib/"+t)
+	}
+	if elfFs.FileHdr.Arch == elf.ELFCLASS64 {
+		dirs = append(dirs, "/lib64", "/usr/lib64")
+	}
+	return append(dirs, "/lib", "/usr/lib")
+}
+
+// libToken is what $LIB stands for, glibc's own library directory:
+// lib/<triplet> on multiarch distributions, lib64 or lib on the others,
+// told apart by the triplet directory the default search starts with.
+func (t *ldTree) libToken(elfFs *elfFile) string {
+	if m := multiarchTriplet(elfFs); m != "" {
+		if fi, err := os.Stat(t.realPath(t.hostPath("/lib/" + m))); err == nil && fi.IsDir() {
+			return "lib/" + m
+		}
+	}
+	return yesNo(elfFs.FileHdr.Arch == elf.ELFCLASS64, "lib64", "lib")
+}
+
+/* fills in what ld.so reads from an object's dynamic section */
+func (o *ldObject) readDynamic() {
+	dyn := loadDynamic(o.File)
+	o.Needed = dyn.strings(elf.DT_NEEDED)
+	if s := dyn.strings(elf.DT_SONAME); len(s) > 0 {
+		o.Soname = s[0]
+	}
+	o.RPATH = splitLdPath(dyn.strings(elf.DT_RPATH))
+	o.RUNPATH = splitLdPath(dyn.strings(elf.DT_RUNPATH))
+	o.NoDefLib = dyn.hasFlag1(elf.DF_1_NODEFLIB)
+	_, dtSymbolic := dyn.value(elf.DT_SYMBOLIC)
+	o.Symbolic = dtSymbolic || dyn.hasFlag(elf.DF_SYMBOLIC)
+}
+
+/* the host path of a path inside the sysroot */
+func (t *ldTree) hostPath(p string) string {
+	if t.Sysroot == "" || !filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(t.Sysroot, p)
+}
+
+/* a host path as it appears inside the sysroot, ok when it is inside */
+func (t *ldTree) rootPath(p string) (string, bool) {
+	if rel, err := filepath.Rel(t.Sysroot, p); t.Sysroot != "" && err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+		return "/" + rel, true
+	}
+	return p, false
+}
+
+/* a host path as printed, the path inside the sysroot when it is there */
+func (t *ldTree) showPath(p string) string {
+	p, _ = t.rootPath(p)
+	return p
+}
+
+// realPath follows the symbolic links in a host path inside the sysroot as
+// if the sysroot were the root, so the object opened is the one ld.so
+// would open in the sysroot rather than whatever an absolute link target
+// names on the host. Paths outside the sysroot are left to the host.
+func (t *ldTree) realPath(p string) string {
+	if in, ok := t.rootPath(p); ok {
+		return resolveInRoot(t.Sysroot, in)
+	}
+	return p
+}
+
+/* the number of symbolic links Linux follows in one lookup before failing with ELOOP */
+const maxSymlinks = 40
+
+// resolveInRoot walks p one component at a time below root, the way the
+// kernel walks it in a chroot: a symbolic link is replaced by its target,
+// an absolute target starting over at root, and .. stops at root. The
+// host path returned has no symbolic links left in it; it is "" when a
+// component is missing or the links loop, as the lookup would fail.
+func resolveInRoot(root, p string) string {
+	done, rest, links := "/", p, 0
+	for rest != "" {
+		var comp string
+		comp, rest, _ = strings.Cut(strings.TrimLeft(rest, "/"), "/")
+		switch comp {
+		case "", ".":
+			continue
+		case "..":
+			done = path.Dir(done)
+			continue
+		}
+		next := path.Join(done, comp)
+		fi, err := os.Lstat(filepath.Join(root, next))
+		if err != nil {
+			return ""
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			done = next
+			continue
+		}
+		target, err := os.Readlink(filepath.Join(root, next))
+		if links++; err != nil || links > maxSymlinks {
+			return ""
+		}
+		if path.IsAbs(target) {
+			done = "/"
+		}
+		rest = target + "/" + rest
+	}
+	return filepath.Join(root, done)
+}
+
+// searchDir turns a search path entry of obj into a host directory. Paths
+// with $ORIGIN are relative to where obj was found, on the host already,
+// the others are taken inside the sysroot.
+func (t *ldTree) searchDir(dir string, obj *ldObject) string {
+	if strings.Contains(dir, "ORIGIN") {
+		return expandLdTokens(dir, filepath.Dir(obj.Path), t.lib, obj.File)
+	}
+	return t.hostPath(expandLdTokens(dir, "", t.lib, obj.File))
+}
+
+// openCandidate opens path if it is an ELF object loader could use, of the
+// same class, data encoding and machine. ld.so skips anything else found
+// under a library's name and goes on searching, so does this.
+func openCandidate(path string, loader *elfFile) *elfFile {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	var lib *elfFile
+	err = catchError(func() {
+		elfFs, err := openElfReader(fh, path)
+		checkError(err)
+		lib = elfFs
+	})
+	if err != nil || lib == nil {
+		fh.Close()
+		return nil
+	}
+	if lib.FileHdr.Arch != loader.FileHdr.Arch || lib.FileHdr.Endianness != loader.FileHdr.Endianness ||
+		lib.FileHdr.Machine != loader.FileHdr.Machine {
+		fh.Close()
+		return nil
+	}
+	return lib
+}
+
+// find locates a DT_NEEDED name of obj the way _dl_map_object does: names
+// with a slash are paths, others are looked for in the DT_RPATH of obj and
+// of its loaders up to the target when obj has no DT_RUNPATH, then in
+// LD_LIBRARY_PATH, the DT_RUNPATH of obj, ld.so.cache and the default
+// directories, the last two unless obj was linked with -z nodefaultlib.
+func (t *ldTree) find(name string, obj *ldObject) (*elfFile, string, string) {
+	if strings.Contains(name, "/") {
+		p := t.hostPath(name)
+		return openCandidate(t.realPath(p), obj.File), p, "path"
+	}
+	try := func(dirs []string, from *ldObject) (*elfFile, string) {
+		for _, d := range dirs {
+			p := filepath.Join(t.searchDir(d, from), name)
+			if lib := openCandidate(t.realPath(p), obj.File); lib != nil {
+				return lib, p
+			}
+		}
+		return nil, ""
+	}
+
+	if len(obj.RUNPATH) == 0 {
+		for l := obj; l != nil; l = l.Loader {
+			if len(l.RUNPATH) > 0 {
+				continue
+			}
+			if lib, p := try(l.RPATH, l); lib != nil {
+				return lib, p, "RPATH"
+			}
+		}
+	}
+	if lib, p := try(t.libPath, t.Root); lib != nil {
+		return lib, p, "LD_LIBRARY_PATH"
+	}
+	if lib, p := try(obj.RUNPATH, obj); lib != nil {
+		return lib, p, "RUNPATH"
+	}
+	if obj.NoDefLib {
+		return nil, "", ""
+	}
+	for _, c := range t.cache[name] {
+		p := t.hostPath(c)
+		if lib := openCandidate(t.realPath(p), obj.File); lib != nil {
+			return lib, p, "ld.so.cache"
+		}
+	}
+	if lib, p := try(ldDefaultDirs(obj.File), obj); lib != nil {
+		return lib, p, "default"
+	}
+	return nil, "", ""
+}
+
+// loadDeps resolves the dependencies of elfFs without running anything,
+// breadth first as ld.so maps them. A name already loaded, under the name
+// it was needed by or its soname, is not searched for again. The
+// interpreter is loaded up front and answers to its soname as well.
+func loadDeps(elfFs *elfFile, sysroot string) *ldTree {
+	t := &ldTree{Sysroot: sysroot, loaded: map[string]*ldObject{}}
+	if sysroot != "" {
+		t.Sysroot = filepath.Clean(sysroot)
+	}
+	t.Root = &ldObject{Name: elfFs.Path, Path: elfFs.Path, File: elfFs}
+	t.Root.readDynamic()
+	t.Order = append(t.Order, t.Root)
+	t.libPath = splitLdPath([]string{os.Getenv("LD_LIBRARY_PATH")})
+	t.lib = t.libToken(elfFs)
+
+	cacheFile := t.hostPath("/etc/ld.so.cache")
+	if data, err := os.ReadFile(t.realPath(cacheFile)); err == nil {
+		if t.cache, err = parseLdCache(data, elfFs.FileHdr.Endianness); err != nil {
+			fmt.Printf("Warning: ignoring %s: %v\n", cacheFile, err)
+		}
+	}
+
+	elfFs.getProgHeaders()
+	if ph, ok := getProgHeaderByType(elf.PT_INTERP, elfFs); ok {
+		data := make([]byte, ph.Filesz)
+		elfFs.Fh.ReadAt(data, int64(ph.Off))
+		name := string(bytes.TrimRight(data, "\x00"))
+		t.Interp = &ldObject{Name: name, Path: t.hostPath(name), Via: "interpreter", Loader: t.Root}
+		if t.Interp.File = openCandidate(t.realPath(t.Interp.Path), elfFs); t.Interp.File != nil {
+			t.Interp.readDynamic()
+			t.loaded[name] = t.Interp
+			if t.Interp.Soname != "" {
+				t.loaded[t.Interp.Soname] = t.Interp
+			}
+		} else {
+			t.Missing++
+		}
+	}
+
+	for i := 0; i < len(t.Order); i++ {
+		obj := t.Order[i]
+		for _, name := range obj.Needed {
+			if dep, ok := t.loaded[name]; ok {
+				obj.Deps = append(obj.Deps, dep)
+				if dep == t.Interp && !contains(t.Order, dep) {
+					t.Order = append(t.Order, dep)
+				}
+				continue
+			}
+			lib, path, via := t.find(name, obj)
+			if lib == nil {
+				obj.Deps = append(obj.Deps, nil)
+				t.Missing++
+				continue
+			}
+			dep := &ldObject{Name: name, Path: path, Via: via, File: lib, Loader: obj}
+			dep.readDynamic()
+			t.loaded[name] = dep
+			if dep.Soname != "" {
+				if _, ok := t.loaded[dep.Soname]; !ok {
+					t.loaded[dep.Soname] = dep
+				}
+			}
+			obj.Deps = append(obj.Deps, dep)
+			t.Order = append(t.Order, dep)
+		}
+	}
+	if t.Interp != nil && t.Interp.File != nil && !contains(t.Order, t.Interp) {
+		t.Order = append(t.Order, t.Interp)
+	}
+	return t
+}
+
+/* prints the objects obj needs, each one's own dependencies under the object that loaded it */
+func (t *ldTree) printTree(obj *ldObject, depth int) {
+	indent := strings.Repeat("    ", depth)
+	for i, dep := range obj.Deps {
+		switch {
+		case dep == nil:
+			fmt.Printf("%s%s => not found\n", indent, obj.Needed[i])
+		case dep.Loader != obj || dep.Name != obj.Needed[i]:
+			fmt.Printf("%s%s => %s (already loaded)\n", indent, obj.Needed[i], t.showPath(dep.Path))
+		default:
+			fmt.Printf("%s%s => %s [%s]\n", indent, dep.Name, t.showPath(dep.Path), dep.Via)
+			t.printTree(dep, depth+1)
+		}
+	}
+}
+
+/* the objects as DOT nodes, missing ones drawn dashed in red */
+func (t *ldTree) printDot() {
+	fmt.Println("digraph dependencies {")
+	fmt.Println("\tnode [shape=box];")
+	id := func(o *ldObject) string { return t.showPath(o.Path) }
+	for _, obj := range t.Order {
+		fmt.Printf("\t%q [label=%q];\n", id(obj), filepath.Base(obj.Name))
+	}
+	var missing []string
+	for _, obj := range t.Order {
+		for i, dep := range obj.Deps {
+			if dep == nil {
+				missing = append(missing, obj.Needed[i])
+				fmt.Printf("\t%q -> %q;\n", id(obj), "not found: "+obj.Needed[i])
+				continue
+			}
+			fmt.Printf("\t%q -> %q;\n", id(obj), id(dep))
+		}
+	}
+	if t.Interp != nil && t.Interp.File == nil {
+		missing = append(missing, t.Interp.Name)
+		fmt.Printf("\t%q -> %q [label=interpreter];\n", id(t.Root), "not found: "+t.Interp.Name)
+	}
+	sort.Strings(missing)
+	for i, name := range missing {
+		if i == 0 || missing[i-1] != name {
+			fmt.Printf("\t%q [label=%q, color=red, style=dashed];\n", "not found: "+name, name+"\nnot found")
+		}
+	}
+	fmt.Println("}")
+}
+
+// printLdd is --ldd: the libraries elfFs loads, found as ld.so would find
+// them but without running anything, so untrusted binaries and the root
+// file systems of other architectures can be inspected. With a sysroot,
+// absolute paths, ld.so.cache and the default directories are taken inside
+// it. --ldd=dot prints the same graph in DOT.
+func printLdd(elfFs *elfFile, sysroot string, dot bool) {
+	if loadDynamic(elfFs) == nil {
+		fmt.Println("not a dynamic executable")
+		return
+	}
+	t := loadDeps(elfFs, sysroot)
+	if dot {
+		t.printDot()
+		return
+	}
+
+	fmt.Println(elfFs.Path)
+	t.printTree(t.Root, 1)
+	if t.Interp != nil {
+		if t.Interp.File == nil {
+			fmt.Printf("    %s => not found [interpreter]\n", t.Interp.Name)
+		} else {
+			fmt.Printf("    %s [interpreter]\n", t.showPath(t.Interp.Path))
+		}
+	}
+	fmt.Printf("\n%d objects loaded, %d not found\n", len(t.Order)-1, t.Missing)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+/* a sysroot laid out in dir, links maps a path inside it to its link target, files are copied in */
+func makeSysroot(t *testing.T, files, links map[string]string) string {
+	root := t.TempDir()
+	for p, src := range files {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(root, p)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, p), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for p, target := range links {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(root, p)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(target, filepath.Join(root, p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestResolveInRoot(t *testing.T) {
+	root := makeSysroot(t, map[string]string{
+		"/usr/lib/x86_64-linux-gnu/libfoo.so.1.0": "testdata/chk-lib.so",
+	}, map[string]string{
+		"/lib":                                   "usr/lib",
+		"/usr/lib/x86_64-linux-gnu/libfoo.so.1":  "/usr/lib/x86_64-linux-gnu/libfoo.so.1.0",
+		"/usr/lib64":                             "/lib/x86_64-linux-gnu",
+		"/usr/lib/x86_64-linux-gnu/up":           "../../../../../usr",
+		"/usr/lib/x86_64-linux-gnu/libhost.so":   "/etc/os-release",
+		"/usr/lib/x86_64-linux-gnu/libloop.so.1": "/usr/lib/x86_64-linux-gnu/libloop.so.2",
+		"/usr/lib/x86_64-linux-gnu/libloop.so.2": "/lib/x86_64-linux-gnu/libloop.so.1",
+	})
+	lib := filepath.Join(root, "usr/lib/x86_64-linux-gnu/libfoo.so.1.0")
+
+	tests := []struct{ path, want string }{
+		{"/lib/x86_64-linux-gnu/libfoo.so.1", lib},
+		{"/usr/lib64/libfoo.so.1", lib},
+		{"/usr/lib64/../x86_64-linux-gnu/libfoo.so.1.0", lib}, // .. leaves the directory the link led to
+		{"/usr/lib/x86_64-linux-gnu/up/lib/x86_64-linux-gnu/libfoo.so.1", lib},
+		{"/lib", filepath.Join(root, "usr/lib")},
+		{"/usr/lib/x86_64-linux-gnu/libhost.so", ""},
+		{"/lib/x86_64-linux-gnu/libloop.so.1", ""},
+		{"/lib/missing/../x86_64-linux-gnu/libfoo.so.1", ""},
+	}
+	for _, tt := range tests {
+		if got := resolveInRoot(root, tt.path); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	elfFs := &elfFile{FileHdr: enumIdent{binary.LittleEndian, elf.ELFCLASS64, elf.EM_X86_64}}
+	if got := (&ldTree{Sysroot: root}).libToken(elfFs); got != "lib/x86_64-linux-gnu" {
+		t.Errorf("$LIB in a multiarch sysroot: got %q", got)
+	}
+	if got := (&ldTree{Sysroot: t.TempDir()}).libToken(elfFs); got != "lib64" {
+		t.Errorf("$LIB in an empty sysroot: got %q", got)
+	}
+}
+
+// testdata/chk-use needs chkdyn.so, which the sysroot only has as an
+// absolute symbolic link; the host has nothing at the link target.
+func TestLoadDepsSysrootSymlink(t *testing.T) {
+	t.Setenv("LD_LIBRARY_PATH", "")
+	root := makeSysroot(t, map[string]string{
+		"/opt/chk/chk-lib.so": "testdata/chk-lib.so",
+	}, map[string]string{
+		"/lib/x86_64-linux-gnu/chkdyn.so": "/opt/chk/chk-lib.so",
+	})
+	elfFs, err := openElfFile("testdata/chk-use")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer elfFs.Fh.Close()
+
+	tr := loadDeps(elfFs, root)
+	dep := tr.Root.Deps[0]
+	if dep == nil {
+		t.Fatal("chkdyn.so not found")
+	}
+	defer dep.File.Fh.Close()
+	if p := tr.showPath(dep.Path); p != "/lib/x86_64-linux-gnu/chkdyn.so" || dep.Via != "default" {
+		t.Errorf("chkdyn.so found at %s [%s]", p, dep.Via)
+	}
+	if tr.Missing != 1 || tr.Interp == nil || tr.Interp.File != nil {
+		t.Errorf("%d not found, want only the interpreter", tr.Missing)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"debug/elf"
+	"fmt"
+	"strings"
+
+	"github.com/sad0p/go-readelf/symlookup"
+)
+
+/* the lookup table of a file, read through debug/elf from the handle already open */
+func loadLookupTable(elfFs *elfFile) (*symlookup.Table, error) {
+	f, err := elf.NewFile(elfFs.Fh)
+	if err != nil {
+		return nil, err
+	}
+	return symlookup.Load(f)
+}
+
+// printLookup is --lookup: each name[@version] resolved by symlookup as
+// ld.so would resolve it in this file, with the steps it took, and for a
+// failed lookup the definitions in .dynsym the hash table missed.
+func printLookup(elfFs *elfFile, queries []string) {
+	tab, err := loadLookupTable(elfFs)
+	if err != symlookup.ErrNoDynsym {
+		checkError(err)
+	}
+	for _, q := range queries {
+		name, version := q, ""
+		if at := strings.Index(q, "@"); at >= 0 {
+			name, version = q[:at], strings.TrimLeft(q[at:], "@")
+		}
+
+		fmt.Printf("Lookup %s\n", q)
+		if err == symlookup.ErrNoDynsym {
+			fmt.Println("  no .dynsym, nothing to search")
+			fmt.Println("  not found")
+			continue
+		}
+		m, ok := tab.Trace(name, version, func(s string) { fmt.Printf("  %s\n", s) })
+		if !ok {
+			fmt.Println("  not found")
+			reportUnreachable(tab, name)
+			continue
+		}
+
+		sep := "@@"
+		if m.Hidden {
+			sep = "@"
+		}
+		full := m.Symbol.Name
+		if m.Version != "" {
+			full += sep + m.Version
+		}
+		fmt.Printf("  found via %s: [%d] %s value 0x%x size %d %s %s section %d\n", m.Path, m.Index, displayName(full),
+			m.Symbol.Value, m.Symbol.Size, symTypeString(elf.ST_TYPE(m.Symbol.Info)), symBindString(elf.ST_BIND(m.Symbol.Info)), m.Symbol.Section)
+	}
+}
+
+/* a failed lookup may still have a definition in .dynsym that the hash table does not lead to */
+func reportUnreachable(tab *symlookup.Table, name string) {
+	for i, s := range tab.Symbols {
+		if i == 0 || s.Name != name || s.Section == elf.SHN_UNDEF {
+			continue
+		}
+		v := ""
+		if ver, _ := tab.VersionName(uint32(i)); ver != "" {
+			v = "@" + ver
+		}
+		fmt.Printf("  note: .dynsym [%d] defines %s%s, check its version or run -I for hash table problems\n", i, name, v)
+	}
+}
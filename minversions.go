@@ -0,0 +1,134 @@
+package main
+
+import (
+	"debug/elf"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/* symbols --min-versions names per version before counting the rest */
+const minVersionNames = 8
+
+/* a version .gnu.version_r asks a library for, with the undefined symbols bound to it */
+type neededVersion struct {
+	Name     string // GLIBC_2.34
+	Lib, Num string // GLIBC and 2.34, Num is empty for GLIBC_PRIVATE and the like
+	Weak     bool
+	Symbols  []string
+}
+
+/* the versions needed from one DT_NEEDED library, each series newest first */
+type libraryNeeds struct {
+	File     string
+	Versions []neededVersion
+}
+
+// neededVersions lists every DT_NEEDED library with the versions the file
+// needs from it. Libraries .gnu.version_r names without a DT_NEEDED entry,
+// as ld.so itself, follow. Versions are grouped by series, GLIBCXX apart
+// from CXXABI, numbered ones newest first and the unnumbered ones last.
+func neededVersions(elfFs *elfFile) []libraryNeeds {
+	vers := loadSymVersions(elfFs)
+	var list []libraryNeeds
+	byFile := map[string]int{}
+	for _, name := range loadDynamic(elfFs).strings(elf.DT_NEEDED) {
+		byFile[name] = len(list)
+		list = append(list, libraryNeeds{File: name})
+	}
+	if vers == nil {
+		return list
+	}
+
+	elfFs.loadDynSymbols()
+	symbols := map[uint16][]string{}
+	for i := uint32(1); i < uint32(len(elfFs.DynSymbols)); i++ {
+		s, ok := getSymbol(i, dynSym, elfFs)
+		vs, found := vers.symVersion(i)
+		if ok && found && elf.SectionIndex(s.Shndx) == elf.SHN_UNDEF {
+			symbols[vs&^versymHidden] = append(symbols[vs&^versymHidden], displayName(s.Name))
+		}
+	}
+
+	for _, n := range vers.Verneeds {
+		k, ok := byFile[n.File]
+		if !ok {
+			k = len(list)
+			byFile[n.File] = k
+			list = append(list, libraryNeeds{File: n.File})
+		}
+		for _, a := range n.Aux {
+			v := neededVersion{Name: a.Name, Weak: a.Flags&verFlgWeak != 0, Symbols: symbols[a.Other]}
+			v.Lib, v.Num = splitVersion(a.Name)
+			sort.Strings(v.Symbols)
+			list[k].Versions = append(list[k].Versions, v)
+		}
+	}
+
+	for _, l := range list {
+		sort.SliceStable(l.Versions, func(a, b int) bool {
+			x, y := l.Versions[a], l.Versions[b]
+			switch {
+			case (x.Num == "") != (y.Num == ""):
+				return y.Num == ""
+			case x.Lib != y.Lib:
+				return x.Lib < y.Lib
+			}
+			return compareVersions(x.Num, y.Num) > 0
+		})
+	}
+	return list
+}
+
+// printMinVersions is --min-versions: for every DT_NEEDED library the newest
+// version of each series the file needs from it, which is the oldest
+// release of that library it can run against, followed by every version
+// needed with the symbols bound to it. The symbols of the newest one are
+// what would have to go to run on an older release.
+func printMinVersions(elfFs *elfFile) {
+	list := neededVersions(elfFs)
+	if len(list) == 0 {
+		fmt.Println("No needed libraries found in target")
+		return
+	}
+
+	newest := map[string]string{}
+	for _, l := range list {
+		if len(l.Versions) == 0 {
+			fmt.Printf("%s: no versioned symbols\n", l.File)
+			continue
+		}
+		var heads []string
+		for i, v := range l.Versions {
+			if v.Num != "" && (i == 0 || l.Versions[i-1].Lib != v.Lib) {
+				heads = append(heads, v.Name)
+				if cur, ok := newest[v.Lib]; !ok || compareVersions(v.Num, cur) > 0 {
+					newest[v.Lib] = v.Num
+				}
+			}
+		}
+		fmt.Println(strings.TrimRight(l.File+": "+strings.Join(heads, ", "), " "))
+
+		for _, v := range l.Versions {
+			name := v.Name
+			if v.Weak {
+				name += " (weak)"
+			}
+			names := v.Symbols
+			if len(names) > minVersionNames {
+				names = append(names[:minVersionNames:minVersionNames], fmt.Sprintf("and %d more", len(v.Symbols)-minVersionNames))
+			}
+			fmt.Println(strings.TrimRight(fmt.Sprintf("  %-24s %5d  %s", name, len(v.Symbols), strings.Join(names, ", ")), " "))
+		}
+	}
+
+	if len(newest) == 0 {
+		return
+	}
+	var series []string
+	for lib, num := range newest {
+		series = append(series, lib+"_"+num)
+	}
+	sort.Strings(series)
+	fmt.Printf("\nMinimum versions: %s\n", strings.Join(series, ", "))
+}
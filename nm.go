@@ -0,0 +1,160 @@
+package main
+
+import (
+	"debug/elf"
+	"fmt"
+	"sort"
+)
+
+/* short options as nm spells them, they replace the readelf ones once --nm is given */
+var nmShortOptions = map[byte]func(*displayOptions){
+	'D': func(o *displayOptions) { o.DynSyms = true },
+	'u': func(o *displayOptions) { o.Filter.Undefined = true },
+	'S': func(o *displayOptions) { o.NmSizes = true },
+	'n': func(o *displayOptions) { o.Filter.Sort = "addr" },
+	'p': func(o *displayOptions) { o.Filter.Sort = "" },
+	'r': func(o *displayOptions) { o.Filter.Reverse = true },
+	'g': func(o *displayOptions) { o.Filter.Binds = []elf.SymBind{elf.STB_GLOBAL, elf.STB_WEAK, stbGNUUnique} },
+	'C': func(o *displayOptions) { demangleNames = true },
+}
+
+var nmLongOptions = map[string]byte{
+	"--dynamic":        'D',
+	"--undefined-only": 'u',
+	"--print-size":     'S',
+	"--numeric-sort":   'n',
+	"--no-sort":        'p',
+	"--reverse-sort":   'r',
+	"--extern-only":    'g',
+	"--demangle":       'C',
+}
+
+// nmLetter is the symbol class nm prints: U, w and v for undefined symbols,
+// A, C, i and u for absolute, common, ifunc and unique ones, W and V for weak
+// definitions, otherwise the kind of section the symbol is defined in. Local
+// symbols get the lower case letter.
+func nmLetter(s elfSymbol, ndx uint32, ok bool, elfFs *elfFile) byte {
+	bind, typ := elf.ST_BIND(s.Info), elf.ST_TYPE(s.Info)
+	switch {
+	case elf.SectionIndex(s.Shndx) == elf.SHN_UNDEF && !ok:
+		switch {
+		case bind != elf.STB_WEAK:
+			return 'U'
+		case typ == elf.STT_OBJECT:
+			return 'v'
+		}
+		return 'w'
+	case elf.SectionIndex(s.Shndx) == elf.SHN_COMMON && !ok:
+		return 'C'
+	case typ == sttGNUIFunc:
+		return 'i'
+	case bind == stbGNUUnique:
+		return 'u'
+	case bind == elf.STB_WEAK && typ == elf.STT_OBJECT:
+		return 'V'
+	case bind == elf.STB_WEAK:
+		return 'W'
+	}
+
+	c := byte('?')
+	switch {
+	case elf.SectionIndex(s.Shndx) == elf.SHN_ABS && !ok:
+		c = 'A'
+	case ok && ndx < uint32(len(elfFs.ElfSections.SectionName)):
+		sh := getSectionHeader(ndx, elfFs)
+		switch {
+		case sh.Flags&elf.SHF_ALLOC == 0:
+			c = 'N'
+		case sh.Flags&elf.SHF_EXECINSTR != 0:
+			c = 'T'
+		case sh.Type == elf.SHT_NOBITS:
+			c = 'B'
+		case sh.Flags&elf.SHF_WRITE == 0:
+			c = 'R'
+		default:
+			c = 'D'
+		}
+	}
+	if bind == elf.STB_LOCAL && c != '?' {
+		c += 'a' - 'A'
+	}
+	return c
+}
+
+// printNm is --nm: the symbols of .symtab, or of .dynsym with -D, in the
+// BSD format of nm sorted by name. Dynamic symbols carry their version,
+// @@ marking the default definition. File and section symbols are left out.
+func printNm(elfFs *elfFile, opts displayOptions) {
+	symType, table := sym, uint32(0)
+	src := elfFs
+	var vers *symVersions
+	if opts.DynSyms {
+		symType, table = dynSym, elfFs.loadDynSymbols()
+		vers = loadSymVersions(elfFs)
+	} else if src = elfFs.loadSymtab(); src != nil {
+		table = getSectionNdx(".symtab", src)
+	}
+	if table == 0 {
+		fmt.Printf("%s: no symbols\n", elfFs.Path)
+		return
+	}
+	n := len(elfFs.Symbols)
+	if symType == dynSym {
+		n = len(elfFs.DynSymbols)
+	}
+	width := 16
+	if elfFs.FileHdr.Arch == elf.ELFCLASS32 {
+		width = 8
+	}
+	xindex := loadSymtabShndx(table, src)
+
+	var list []uint32
+	for i := uint32(1); i < uint32(n); i++ {
+		s, ok := getSymbol(i, symType, elfFs)
+		if !ok || elf.ST_TYPE(s.Info) == elf.STT_FILE || elf.ST_TYPE(s.Info) == elf.STT_SECTION {
+			continue
+		}
+		if opts.Filter.match(s, s.Name, symSectionString(s, i, xindex, src)) {
+			list = append(list, i)
+		}
+	}
+	/* like nm, names sort in their mangled form even with -C, undefined symbols have the lowest address and those at the same one are listed by name */
+	if opts.Filter.Sort != "addr" {
+		opts.Filter.order(list, symType, elfFs)
+	} else {
+		sort.SliceStable(list, func(a, b int) bool {
+			sa, _ := getSymbol(list[a], symType, elfFs)
+			sb, _ := getSymbol(list[b], symType, elfFs)
+			if opts.Filter.Reverse {
+				sa, sb = sb, sa
+			}
+			ua := elf.SectionIndex(sa.Shndx) == elf.SHN_UNDEF
+			ub := elf.SectionIndex(sb.Shndx) == elf.SHN_UNDEF
+			switch {
+			case ua != ub:
+				return ua
+			case sa.Value != sb.Value:
+				return sa.Value < sb.Value
+			}
+			return sa.Name < sb.Name
+		})
+	}
+
+	for _, i := range list {
+		s, _ := getSymbol(i, symType, elfFs)
+		/* a separate debug file keeps the section headers but not their contents, judge by the target's */
+		ndx, ok := symSectionIndex(s, i, xindex)
+		c := nmLetter(s, ndx, ok, elfFs)
+
+		name := vers.versionedName(i, s.Name, displayName(s.Name))
+
+		switch {
+		case c == 'U' || c == 'w' || c == 'v':
+			fmt.Printf("%*s %c %s\n", width, "", c, name)
+		case opts.NmSizes && s.Size != 0:
+			fmt.Printf("%0*x %0*x %c %s\n", width, s.Value, width, s.Size, c, name)
+		default:
+			fmt.Printf("%0*x %c %s\n", width, s.Value, c, name)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"debug/elf"
+	"fmt"
+	"math/bits"
+	"sort"
+	"strings"
+)
+
+/* a dynamic relocation as --plt shows it, Name is the symbol with its version */
+type gotReloc struct {
+	Entry relocEntry
+	Name  string
+}
+
+/* a PLT entry, Slot is the GOT entry it jumps through */
+type pltStub struct {
+	Addr    uint64
+	Section string
+	Slot    uint64
+	HasSlot bool
+	Header  bool // PLT0, the stub calling the lazy resolver
+}
+
+// pltLayout is the size of PLT0 and of each stub in a PLT section. Only
+// .plt starts with PLT0, the IBT and MPX sections (.plt.sec, .plt.bnd) and
+// .plt.got hold one stub per symbol.
+func pltLayout(name string, sh sectionHeader, mType elf.Machine) (header, entry uint64) {
+	switch mType {
+	case elf.EM_X86_64, elf.EM_386:
+		if name == ".plt" {
+			return 16, 16
+		}
+		if sh.Entsize >= 8 {
+			return 0, sh.Entsize
+		}
+		if name == ".plt.got" {
+			return 0, 8
+		}
+		return 0, 16
+	case elf.EM_AARCH64:
+		return 32, 16
+	case elf.EM_ARM:
+		return 20, 12
+	}
+	return 0, 0
+}
+
+// stubSlot decodes the GOT slot a stub loads its target from. x86 stubs
+// jump through it, rip relative on x86-64 and absolute or %ebx (.got.plt)
+// relative on i386; the lazy .plt stubs of IBT binaries only push the
+// index of their .rel(a).plt entry. AArch64 stubs load it with an
+// adrp/ldr pair and ARM ones add up to three immediates to pc before
+// their ldr pc.
+func stubSlot(elfFs *elfFile, addr uint64, code []byte, gotPlt uint64, pltRelocs []relocEntry) (uint64, bool) {
+	order := elfFs.FileHdr.Endianness
+	switch m := elfFs.FileHdr.Machine; m {
+	case elf.EM_X86_64, elf.EM_386:
+		p := 0
+		/* endbr64 / endbr32, then a bnd prefix */
+		if len(code) >= 4 && code[0] == 0xf3 && code[1] == 0x0f && code[2] == 0x1e && (code[3] == 0xfa || code[3] == 0xfb) {
+			p = 4
+		}
+		if p < len(code) && code[p] == 0xf2 {
+			p++
+		}
+		if p+6 > len(code) {
+			return 0, false
+		}
+		disp := uint64(int64(int32(order.Uint32(code[p+2:]))))
+		switch {
+		case code[p] == 0xff && code[p+1] == 0x25 && m == elf.EM_X86_64:
+			return addr + uint64(p) + 6 + disp, true
+		case code[p] == 0xff && code[p+1] == 0x25:
+			return uint64(uint32(disp)), true
+		case code[p] == 0xff && code[p+1] == 0xa3:
+			return uint64(uint32(gotPlt + disp)), true
+		case code[p] == 0x68:
+			n := uint64(order.Uint32(code[p+1:]))
+			if m == elf.EM_386 {
+				n /= 8 /* i386 pushes the byte offset of its Elf32_Rel */
+			}
+			if n < uint64(len(pltRelocs)) {
+				return pltRelocs[n].Off, true
+			}
+		}
+
+	case elf.EM_AARCH64:
+		var page uint64
+		adrp := false
+		for p := 0; p+4 <= len(code); p += 4 {
+			w := order.Uint32(code[p:])
+			switch {
+			case w&0x9f000000 == 0x90000000:
+				imm := int64(w>>29&3|w>>5&0x7ffff<<2) << 43 >> 31
+				page, adrp = (addr+uint64(p))&^0xfff+uint64(imm), true
+			case adrp && w&0xffc00000 == 0xf9400000:
+				return page + uint64(w>>10&0xfff)*8, true
+			case adrp && w&0xffc00000 == 0xb9400000:
+				return page + uint64(w>>10&0xfff)*4, true
+			}
+		}
+
+	case elf.EM_ARM:
+		var base uint64
+		for p := 0; p+4 <= len(code); p += 4 {
+			w := order.Uint32(code[p:])
+			imm := uint64(bits.RotateLeft32(w&0xff, -int(w>>8&0xf)*2))
+			switch w & 0x0ffff000 {
+			case 0x028fc000: /* add ip, pc, #imm */
+				base = addr + uint64(p) + 8 + imm
+			case 0x028cc000: /* add ip, ip, #imm */
+				base += imm
+			case 0x05bcf000: /* ldr pc, [ip, #imm]! */
+				return uint32Class(elfFs, base+uint64(w&0xfff)), true
+			}
+		}
+	}
+	return 0, false
+}
+
+/* addresses wrap at 32 bits in ELFCLASS32 files */
+func uint32Class(elfFs *elfFile, addr uint64) uint64 {
+	if elfFs.FileHdr.Arch == elf.ELFCLASS32 {
+		return uint64(uint32(addr))
+	}
+	return addr
+}
+
+/* the dynamic relocations by the address they patch, and the .rel(a).plt ones in table order */
+func gotRelocs(elfFs *elfFile) (map[uint64]gotReloc, []relocEntry) {
+	elfFs.getRelocations()
+	vers := loadSymVersions(elfFs)
+	slots := map[uint64]gotReloc{}
+	var plt []relocEntry
+	for k, v := range elfFs.Rels {
+		sh := getSectionHeader(k, elfFs)
+		if sh.Flags&elf.SHF_ALLOC == 0 {
+			continue
+		}
+		symType := sym
+		if getSectionHeader(sh.Link, elfFs).Type == elf.SHT_DYNSYM {
+			symType = dynSym
+		}
+		entries := relocEntries(v, elfFs.FileHdr.Machine)
+		if sh.Name == ".rela.plt" || sh.Name == ".rel.plt" {
+			plt = entries
+		}
+		for _, e := range entries {
+			r := gotReloc{Entry: e}
+			if s, ok := getSymbol(e.Sym, symType, elfFs); ok && e.Sym != 0 {
+				r.Name = displayName(s.Name)
+				if symType == dynSym {
+					r.Name = vers.versionedName(e.Sym, s.Name, r.Name)
+				}
+			}
+			slots[e.Off] = r
+		}
+	}
+	return slots, plt
+}
+
+// printPlt is --plt: every stub of .plt, .plt.sec, .plt.bnd and .plt.got
+// with the GOT slot it jumps through, the relocation filling that slot and
+// its symbol, followed by each slot of .got.plt and .got with the contents
+// it has on disk. A JUMP_SLOT slot that does not start out pointing back
+// into the PLT, as lazy binding has it, or at 0 is flagged.
+func printPlt(elfFs *elfFile) {
+	elfFs.loadAllSymbols()
+	width, word := 16, uint64(8)
+	if elfFs.FileHdr.Arch == elf.ELFCLASS32 {
+		width, word = 8, 4
+	}
+	slots, pltRelocs := gotRelocs(elfFs)
+	idx := newSymbolIndex(elfFs)
+	gotPlt := getSectionHeader(getSectionNdx(".got.plt", elfFs), elfFs).Addr
+	if elfFs.FileHdr.Machine == elf.EM_386 && gotPlt == 0 {
+		gotPlt = getSectionHeader(getSectionNdx(".got", elfFs), elfFs).Addr
+	}
+
+	var stubs []pltStub
+	var pltRanges []bloatRange
+	for _, name := range []string{".plt", ".plt.sec", ".plt.bnd", ".plt.got"} {
+		ndx := getSectionNdx(name, elfFs)
+		if ndx == 0 {
+			continue
+		}
+		sh := getSectionHeader(ndx, elfFs)
+		pltRanges = append(pltRanges, bloatRange{sh.Addr, sh.Addr + sh.Size, name})
+		header, entry := pltLayout(name, sh, elfFs.FileHdr.Machine)
+		if entry == 0 {
+			fmt.Printf("%s: PLT stubs of %s are not supported\n", name, elfFs.FileHdr.Machine)
+			continue
+		}
+		code := getSectionData(ndx, elfFs)
+		if header > 0 && header <= uint64(len(code)) {
+			stubs = append(stubs, pltStub{Addr: sh.Addr, Section: name, Header: true})
+		}
+		for off := header; off+entry <= uint64(len(code)); off += entry {
+			st := pltStub{Addr: sh.Addr + off, Section: name}
+			st.Slot, st.HasSlot = stubSlot(elfFs, st.Addr, code[off:off+entry], gotPlt, pltRelocs)
+			stubs = append(stubs, st)
+		}
+	}
+	sort.SliceStable(stubs, func(a, b int) bool { return stubs[a].Addr < stubs[b].Addr })
+	inPlt := func(v uint64) bool {
+		for _, r := range pltRanges {
+			if v >= r.Lo && v < r.Hi {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(stubs) == 0 {
+		fmt.Println("No PLT sections found in target")
+	} else {
+		fmt.Printf("%-*s  %-9s %-*s  %-*s  %-22s %s\n", width, "Stub", "Section", width, "GOT slot", width, "Initial", "Type", "Symbol")
+	}
+	for _, st := range stubs {
+		switch {
+		case st.Header:
+			fmt.Printf("%0*x  %-9s %-*s  %-*s  %-22s %s\n", width, st.Addr, st.Section, width, "-", width, "-", "-", "<PLT0>")
+			continue
+		case !st.HasSlot:
+			fmt.Printf("%0*x  %-9s %-*s  %-*s  %-22s %s\n", width, st.Addr, st.Section, width, "?", width, "-", "-", "<undecoded stub>")
+			continue
+		}
+		initial, _ := readWord(elfFs, st.Slot)
+		r, ok := slots[st.Slot]
+		typ, name := "-", ""
+		if ok {
+			typ = resolveRelocType(r.Entry.Type, elfFs.FileHdr.Machine)
+			name = pltSymbol(elfFs, r, initial, idx)
+		}
+		fmt.Println(strings.TrimRight(fmt.Sprintf("%0*x  %-9s %0*x  %0*x  %-22s %s", width, st.Addr, st.Section, width, st.Slot, width, initial, typ, name), " "))
+	}
+
+	hijacked := 0
+	for _, name := range []string{".got.plt", ".got"} {
+		ndx := getSectionNdx(name, elfFs)
+		if ndx == 0 {
+			continue
+		}
+		sh := getSectionHeader(ndx, elfFs)
+		data := getSectionData(ndx, elfFs)
+		fmt.Printf("\nGOT section %s at 0x%x has %d slots:\n", name, sh.Addr, uint64(len(data))/word)
+		fmt.Printf("%-6s %-*s  %-*s  %-22s %s\n", "Slot", width, "Address", width, "Initial", "Type", "Symbol")
+		for off := uint64(0); off+word <= uint64(len(data)); off += word {
+			addr := sh.Addr + off
+			var initial uint64
+			if word == 4 {
+				initial = uint64(elfFs.FileHdr.Endianness.Uint32(data[off:]))
+			} else {
+				initial = elfFs.FileHdr.Endianness.Uint64(data[off:])
+			}
+
+			typ, what := "-", ""
+			if r, ok := slots[addr]; ok {
+				typ = resolveRelocType(r.Entry.Type, elfFs.FileHdr.Machine)
+				what = pltSymbol(elfFs, r, initial, idx)
+				if relocKind(r.Entry.Type, elfFs.FileHdr.Machine) == relocJumpSlot && initial != 0 && !inPlt(initial) {
+					what += "  ! starts outside the PLT at " + placeString(elfFs, idx, 0, initial)
+					hijacked++
+				}
+			} else if initial != 0 {
+				what = "<" + placeString(elfFs, idx, 0, initial) + ">"
+			}
+			fmt.Println(strings.TrimRight(fmt.Sprintf("%-6s %0*x  %0*x  %-22s %s", fmt.Sprintf("[%d]", off/word), width, addr, width, initial, typ, what), " "))
+		}
+	}
+	if hijacked > 0 {
+		fmt.Printf("\n%d JUMP_SLOT entries do not start out pointing into the PLT\n", hijacked)
+	}
+}
+
+/* the symbol a slot is bound to, relative and IRELATIVE ones name the address or resolver they hold */
+func pltSymbol(elfFs *elfFile, r gotReloc, initial uint64, idx symbolIndex) string {
+	if r.Name != "" {
+		return r.Name
+	}
+	target := initial
+	if r.Entry.HasAddend {
+		target = uint64(r.Entry.Addend)
+	}
+	return "<" + placeString(elfFs, idx, 0, target) + ">"
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// policyRules is the rule file --policy reads. Every rule is optional. The
+// boolean ones are named after the --checksec field they set the wanted
+// value of, so "runpath": false forbids a RUNPATH and "canary": true asks
+// for a stack protector.
+type policyRules struct {
+	PIE         *bool    `json:"pie"`   // PIE executable or shared object
+	RELRO       string   `json:"relro"` // the least RELRO accepted, "partial" or "full"
+	Canary      *bool    `json:"canary"`
+	NX          *bool    `json:"nx"`
+	Fortified   *bool    `json:"fortified"`
+	RPATH       *bool    `json:"rpath"`
+	RUNPATH     *bool    `json:"runpath"`
+	TEXTREL     *bool    `json:"textrel"`
+	IBT         *bool    `json:"ibt"`
+	SHSTK       *bool    `json:"shstk"`
+	ExecStack   *bool    `json:"exec_stack"`
+	BuildID     *bool    `json:"build_id"`
+	WXSegments  *bool    `json:"wx_segments"`  // segments both writable and executable
+	DenySymbols []string `json:"deny_symbols"` // globs matched against the names of .dynsym and .symtab
+	MaxGlibc    string   `json:"max_glibc"`    // the newest GLIBC_ version the file may need, as 2.34
+}
+
+var relroLevels = map[string]int{"none": 0, "partial": 1, "full": 2}
+
+/* reads the rule file, rejecting the fields and values it does not know so typos do not pass silently */
+func loadPolicy(file string) (policyRules, error) {
+	var rules policyRules
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return rules, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(&rules); err != nil {
+		return rules, fmt.Errorf("%s: %v", file, err)
+	}
+	if _, ok := relroLevels[rules.RELRO]; !ok && rules.RELRO != "" {
+		return rules, fmt.Errorf("%s: relro must be none, partial or full", file)
+	}
+	for _, g := range rules.DenySymbols {
+		if _, err = path.Match(g, ""); err != nil {
+			return rules, fmt.Errorf("%s: deny_symbols: %q: %v", file, g, err)
+		}
+	}
+	rules.MaxGlibc = strings.TrimPrefix(rules.MaxGlibc, "GLIBC_")
+	return rules, nil
+}
+
+/* appends a violation of a boolean rule to list */
+func checkRule(list []string, rule string, want *bool, have bool, detail string) []string {
+	if want == nil || *want == have {
+		return list
+	}
+	if detail != "" {
+		return append(list, fmt.Sprintf("%s: want %t, have %t (%s)", rule, *want, have, detail))
+	}
+	return append(list, fmt.Sprintf("%s: want %t, have %t", rule, *want, have))
+}
+
+// evalPolicy lists the rules elfFs breaks. The hardening rules take the
+// verdicts of checksec, denied symbols are looked for among both the
+// imported and the defined names and the glibc limit is checked against
+// the GLIBC_ versions .gnu.version_r asks for.
+func evalPolicy(rules policyRules, elfFs *elfFile) []string {
+	var list []string
+	r := checksec(elfFs)
+
+	list = checkRule(list, "pie", rules.PIE, r.PIE == "pie" || r.PIE == "dso", r.PIE)
+	if rules.RELRO != "" && relroLevels[r.RELRO] < relroLevels[rules.RELRO] {
+		list = append(list, fmt.Sprintf("relro: want %s, have %s", rules.RELRO, r.RELRO))
+	}
+	list = checkRule(list, "canary", rules.Canary, r.Canary, "")
+	list = checkRule(list, "nx", rules.NX, r.NX, "")
+	list = checkRule(list, "fortified", rules.Fortified, len(r.Fortified) > 0, "")
+	list = checkRule(list, "rpath", rules.RPATH, len(r.RPATH) > 0, strings.Join(r.RPATH, ":"))
+	list = checkRule(list, "runpath", rules.RUNPATH, len(r.RUNPATH) > 0, strings.Join(r.RUNPATH, ":"))
+	list = checkRule(list, "textrel", rules.TEXTREL, r.TEXTREL, "")
+	list = checkRule(list, "ibt", rules.IBT, r.IBT, "")
+	list = checkRule(list, "shstk", rules.SHSTK, r.SHSTK, "")
+	list = checkRule(list, "exec_stack", rules.ExecStack, r.ExecStack, "")
+	list = checkRule(list, "build_id", rules.BuildID, getBuildID(elfFs) != nil, "")
+
+	if rules.WXSegments != nil {
+		var wx []string
+		for i := 0; i < numProgHeaders(elfFs); i++ {
+			ph := getProgHeader(i, elfFs)
+			if ph.Type == elf.PT_LOAD && ph.Flags&(elf.PF_W|elf.PF_X) == elf.PF_W|elf.PF_X {
+				wx = append(wx, fmt.Sprintf("[%d] at 0x%x", i, ph.Vaddr))
+			}
+		}
+		list = checkRule(list, "wx_segments", rules.WXSegments, len(wx) > 0, strings.Join(wx, ", "))
+	}
+
+	if len(rules.DenySymbols) > 0 {
+		seen := map[string]bool{}
+		scan := func(symType, n int) {
+			for i := uint32(1); i < uint32(n); i++ {
+				s, ok := getSymbol(i, symType, elfFs)
+				/* .symtab names of versioned symbols carry their @VERSION or @@VERSION, .dynsym ones do not */
+				name, _, _ := strings.Cut(s.Name, "@")
+				if t := elf.ST_TYPE(s.Info); !ok || name == "" || t == elf.STT_FILE || t == elf.STT_SECTION || seen[name] {
+					continue
+				}
+				for _, g := range rules.DenySymbols {
+					if m, _ := path.Match(g, name); m {
+						seen[name] = true
+						use := yesNo(elf.SectionIndex(s.Shndx) == elf.SHN_UNDEF, "imports", "defines")
+						list = append(list, fmt.Sprintf("deny_symbols: %s %s (matches %q)", use, name, g))
+						break
+					}
+				}
+			}
+		}
+		/* checksec has loaded the file's own tables */
+		scan(dynSym, len(elfFs.DynSymbols))
+		scan(sym, len(elfFs.Symbols))
+	}
+
+	if rules.MaxGlibc != "" {
+		for _, l := range neededVersions(elfFs) {
+			for _, v := range l.Versions {
+				if v.Lib == "GLIBC" && compareVersions(v.Num, rules.MaxGlibc) > 0 {
+					list = append(list, fmt.Sprintf("max_glibc: %s needs %s, newer than GLIBC_%s (%s)", l.File, v.Name, rules.MaxGlibc,
+						strings.Join(v.Symbols, ", ")))
+					break
+				}
+			}
+		}
+	}
+	return list
+}
+
+// printPolicy is --policy: each file checked against the rules, with the
+// rules it breaks listed under it. It fails when any file breaks a rule or
+// cannot be read, so a CI job can gate a release on it.
+func printPolicy(files []string, ruleFile string) bool {
+	rules, err := loadPolicy(ruleFile)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return false
+	}
+
+	failed := 0
+	for _, file := range files {
+		var list []string
+		err := catchError(func() {
+			fh, err := os.Open(file)
+			checkError(err)
+			defer fh.Close()
+			var magic [8]byte
+			fh.ReadAt(magic[:], 0)
+			if isArchive(magic[:]) {
+				checkError(fmt.Errorf("%s is an archive, check the objects it was built from", file))
+			}
+			elfFs, err := openElfReader(fh, file)
+			checkError(err)
+			list = evalPolicy(rules, elfFs)
+		})
+		switch {
+		case err != nil:
+			fmt.Printf("%s: FAIL\n  error: %v\n", file, err)
+			failed++
+		case len(list) > 0:
+			fmt.Printf("%s: FAIL\n", file)
+			for _, v := range list {
+				fmt.Printf("  %s\n", v)
+			}
+			failed++
+		default:
+			fmt.Printf("%s: ok\n", file)
+		}
+	}
+	if failed > 0 {
+		fmt.Printf("\n%d of %d files violate %s\n", failed, len(files), ruleFile)
+	}
+	return failed == 0
+}
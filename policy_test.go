@@ -0,0 +1,34 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testdata/symver.o defines foo_v1 and foo_v2 and binds them with .symver
+// to foo@VERS_1 and foo@@VERS_2, names .symtab keeps with their version.
+func TestPolicyDenyVersioned(t *testing.T) {
+	elfFs, err := openElfFile("testdata/symver.o")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer elfFs.Fh.Close()
+
+	tests := []struct {
+		deny []string
+		want []string
+	}{
+		{[]string{"foo"}, []string{`deny_symbols: defines foo (matches "foo")`}},
+		{[]string{"foo_*", "foo"}, []string{
+			`deny_symbols: defines foo_v1 (matches "foo_*")`,
+			`deny_symbols: defines foo_v2 (matches "foo_*")`,
+			`deny_symbols: defines foo (matches "foo")`,
+		}},
+		{[]string{"VERS_*"}, nil},
+	}
+	for _, tt := range tests {
+		if got := evalPolicy(policyRules{DenySymbols: tt.deny}, elfFs); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("deny %q: got %q, want %q", tt.deny, got, tt.want)
+		}
+	}
+}
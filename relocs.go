@@ -0,0 +1,417 @@
+package main
+
+import (
+	"debug/elf"
+	"fmt"
+	"sort"
+)
+
+/* what a relocation does, which decides how its target is shown and counted */
+const (
+	relocOther = iota
+	relocRelative
+	relocJumpSlot
+	relocGlobDat
+	relocCopy
+	relocIRelative
+)
+
+/* IRELATIVE types missing from debug/elf */
+const (
+	rPPCIRelative   elf.R_PPC   = 248
+	rRISCVIRelative elf.R_RISCV = 58
+	r390IRelative   elf.R_390   = 61
+)
+
+func relocKind(t uint32, mType elf.Machine) int {
+	if rel, ok := relativeRelocType(mType); ok && t == rel {
+		return relocRelative
+	}
+	switch mType {
+	case elf.EM_X86_64:
+		switch elf.R_X86_64(t) {
+		case elf.R_X86_64_JMP_SLOT:
+			return relocJumpSlot
+		case elf.R_X86_64_GLOB_DAT:
+			return relocGlobDat
+		case elf.R_X86_64_COPY:
+			return relocCopy
+		case elf.R_X86_64_IRELATIVE:
+			return relocIRelative
+		}
+	case elf.EM_386:
+		switch elf.R_386(t) {
+		case elf.R_386_JMP_SLOT:
+			return relocJumpSlot
+		case elf.R_386_GLOB_DAT:
+			return relocGlobDat
+		case elf.R_386_COPY:
+			return relocCopy
+		case elf.R_386_IRELATIVE:
+			return relocIRelative
+		}
+	case elf.EM_ARM:
+		switch elf.R_ARM(t) {
+		case elf.R_ARM_JUMP_SLOT:
+			return relocJumpSlot
+		case elf.R_ARM_GLOB_DAT:
+			return relocGlobDat
+		case elf.R_ARM_COPY:
+			return relocCopy
+		case elf.R_ARM_IRELATIVE:
+			return relocIRelative
+		}
+	case elf.EM_AARCH64:
+		switch elf.R_AARCH64(t) {
+		case elf.R_AARCH64_JUMP_SLOT:
+			return relocJumpSlot
+		case elf.R_AARCH64_GLOB_DAT:
+			return relocGlobDat
+		case elf.R_AARCH64_COPY:
+			return relocCopy
+		case elf.R_AARCH64_IRELATIVE:
+			return relocIRelative
+		}
+	case elf.EM_PPC:
+		switch elf.R_PPC(t) {
+		case elf.R_PPC_JMP_SLOT:
+			return relocJumpSlot
+		case elf.R_PPC_GLOB_DAT:
+			return relocGlobDat
+		case elf.R_PPC_COPY:
+			return relocCopy
+		case rPPCIRelative:
+			return relocIRelative
+		}
+	case elf.EM_PPC64:
+		switch elf.R_PPC64(t) {
+		case elf.R_PPC64_JMP_SLOT:
+			return relocJumpSlot
+		case elf.R_PPC64_GLOB_DAT:
+			return relocGlobDat
+		case elf.R_PPC64_COPY:
+			return relocCopy
+		case elf.R_PPC64_IRELATIVE:
+			return relocIRelative
+		}
+	case elf.EM_S390:
+		switch elf.R_390(t) {
+		case elf.R_390_JMP_SLOT:
+			return relocJumpSlot
+		case elf.R_390_GLOB_DAT:
+			return relocGlobDat
+		case elf.R_390_COPY:
+			return relocCopy
+		case r390IRelative:
+			return relocIRelative
+		}
+	case elf.EM_SPARCV9:
+		switch elf.R_SPARC(t) {
+		case elf.R_SPARC_JMP_SLOT:
+			return relocJumpSlot
+		case elf.R_SPARC_GLOB_DAT:
+			return relocGlobDat
+		case elf.R_SPARC_COPY:
+			return relocCopy
+		}
+	case elf.EM_RISCV:
+		switch elf.R_RISCV(t) {
+		case elf.R_RISCV_JUMP_SLOT:
+			return relocJumpSlot
+		case elf.R_RISCV_COPY:
+			return relocCopy
+		case rRISCVIRelative:
+			return relocIRelative
+		}
+	case elf.EM_LOONGARCH:
+		switch elf.R_LARCH(t) {
+		case elf.R_LARCH_JUMP_SLOT:
+			return relocJumpSlot
+		case elf.R_LARCH_COPY:
+			return relocCopy
+		case elf.R_LARCH_IRELATIVE:
+			return relocIRelative
+		}
+	}
+	return relocOther
+}
+
+/* flattened view of a REL, RELA or RELR entry, RELR ones are relative relocations without a symbol */
+type relocEntry struct {
+	Off       uint64
+	Type, Sym uint32
+	Addend    int64
+	HasAddend bool
+}
+
+func relocEntries(v interface{}, mType elf.Machine) []relocEntry {
+	var entries []relocEntry
+	switch r := v.(type) {
+	case []elf.Rel32:
+		for _, rel := range r {
+			entries = append(entries, relocEntry{uint64(rel.Off), elf.R_TYPE32(rel.Info), elf.R_SYM32(rel.Info), 0, false})
+		}
+	case []elf.Rela32:
+		for _, rel := range r {
+			entries = append(entries, relocEntry{uint64(rel.Off), elf.R_TYPE32(rel.Info), elf.R_SYM32(rel.Info), int64(rel.Addend), true})
+		}
+	case []elf.Rel64:
+		for _, rel := range r {
+			entries = append(entries, relocEntry{rel.Off, elf.R_TYPE64(rel.Info), elf.R_SYM64(rel.Info), 0, false})
+		}
+	case []elf.Rela64:
+		for _, rel := range r {
+			entries = append(entries, relocEntry{rel.Off, elf.R_TYPE64(rel.Info), elf.R_SYM64(rel.Info), rel.Addend, true})
+		}
+	case relrOffsets:
+		t, _ := relativeRelocType(mType)
+		for _, off := range r {
+			entries = append(entries, relocEntry{off, t, 0, 0, false})
+		}
+	}
+	return entries
+}
+
+/* a defined function, object or label, places and values are named after the one covering them */
+type addrSymbol struct {
+	Lo, Hi uint64
+	Name   string
+	Label  bool // an assembler label without a size
+}
+
+// symbolIndex holds the named functions, objects and labels sorted by address. In
+// relocatable files symbol values are offsets into their section, so the
+// symbols are kept per section there and under 0 otherwise.
+type symbolIndex map[uint32][]addrSymbol
+
+func newSymbolIndex(elfFs *elfFile) symbolIndex {
+	idx := symbolIndex{}
+	rel := elfHeaderType(elfFs) == elf.ET_REL
+	add := func(symType, n int) {
+		for i := uint32(1); i < uint32(n); i++ {
+			s, ok := getSymbol(i, symType, elfFs)
+			typ := elf.ST_TYPE(s.Info)
+			if !ok || s.Name == "" || elf.SectionIndex(s.Shndx) == elf.SHN_UNDEF {
+				continue
+			}
+			switch typ {
+			case elf.STT_FUNC, elf.STT_OBJECT, sttGNUIFunc:
+			case elf.STT_NOTYPE:
+				/* assembler labels such as _start, but not absolute values or ARM and RISC-V mapping symbols */
+				if elf.SectionIndex(s.Shndx) >= elf.SHN_LORESERVE || s.Name[0] == '$' {
+					continue
+				}
+			default:
+				continue
+			}
+			sec := uint32(0)
+			if rel {
+				sec = uint32(s.Shndx)
+			}
+			a := addrSymbol{Lo: s.Value, Hi: s.Value + max(s.Size, 1), Name: s.Name}
+			if typ == elf.STT_NOTYPE && s.Size == 0 {
+				sh := getSectionHeader(uint32(s.Shndx), elfFs)
+				end := sh.Size
+				if !rel {
+					end += sh.Addr
+				}
+				a.Hi, a.Label = max(end, a.Hi), true
+			}
+			idx[sec] = append(idx[sec], a)
+		}
+	}
+	add(sym, len(elfFs.Symbols))
+	add(dynSym, len(elfFs.DynSymbols))
+
+	for _, list := range idx {
+		sort.SliceStable(list, func(a, b int) bool { return list[a].Lo < list[b].Lo })
+		/* a sizeless label covers up to the next symbol or the end of its section */
+		for i := range list {
+			if !list[i].Label {
+				continue
+			}
+			j := sort.Search(len(list), func(j int) bool { return list[j].Lo > list[i].Lo })
+			if j < len(list) {
+				list[i].Hi = min(list[i].Hi, list[j].Lo)
+			}
+		}
+	}
+	return idx
+}
+
+/* the symbol starting closest at or below addr, provided it still covers addr */
+func (idx symbolIndex) lookup(sec uint32, addr uint64) (addrSymbol, bool) {
+	list := idx[sec]
+	i := sort.Search(len(list), func(i int) bool { return list[i].Lo > addr }) - 1
+	for j := i; j >= 0 && list[j].Lo == list[i].Lo; j-- {
+		if addr < list[j].Hi {
+			return list[j], true
+		}
+	}
+	return addrSymbol{}, false
+}
+
+func symOffset(name string, off int64) string {
+	switch {
+	case off > 0:
+		return fmt.Sprintf("%s+0x%x", name, off)
+	case off < 0:
+		return fmt.Sprintf("%s-0x%x", name, -off)
+	}
+	return name
+}
+
+// placeString names an address as symbol+offset, or failing that as an
+// offset into the section holding it. sec is the section addr is relative
+// to in relocatable files.
+func placeString(elfFs *elfFile, idx symbolIndex, sec uint32, addr uint64) string {
+	rel := elfHeaderType(elfFs) == elf.ET_REL
+	if !rel {
+		sec = 0
+	}
+	if s, ok := idx.lookup(sec, addr); ok {
+		return symOffset(displayName(s.Name), int64(addr-s.Lo))
+	}
+	if rel {
+		return symOffset(getSectionHeader(sec, elfFs).Name, int64(addr))
+	}
+	if ndx, ok := sectionAt(elfFs, addr); ok {
+		sh := getSectionHeader(ndx, elfFs)
+		return symOffset(sh.Name, int64(addr-sh.Addr))
+	}
+	return fmt.Sprintf("0x%x", addr)
+}
+
+/* the allocated section containing addr, the empty ones only match their own address */
+func sectionAt(elfFs *elfFile, addr uint64) (uint32, bool) {
+	for i := uint32(1); i < uint32(len(elfFs.ElfSections.SectionName)); i++ {
+		sh := getSectionHeader(i, elfFs)
+		if sh.Flags&elf.SHF_ALLOC != 0 && sh.Flags&elf.SHF_TLS == 0 && addr >= sh.Addr && (addr < sh.Addr+sh.Size || addr == sh.Addr) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+/* reads a word of the loaded image, how REL and RELR relocations keep their addend */
+func readWord(elfFs *elfFile, addr uint64) (uint64, bool) {
+	if elfFs.FileHdr.Arch == elf.ELFCLASS32 {
+		if buf := readImage(elfFs, addr, 4); buf != nil {
+			return uint64(elfFs.FileHdr.Endianness.Uint32(buf)), true
+		}
+		return 0, false
+	}
+	if buf := readImage(elfFs, addr, 8); buf != nil {
+		return elfFs.FileHdr.Endianness.Uint64(buf), true
+	}
+	return 0, false
+}
+
+/* width of the field REL relocations of type t patch, and keep their addend in; 0 for instruction fields */
+func relocFieldSize(t uint32, mType elf.Machine) uint64 {
+	switch mType {
+	case elf.EM_386:
+		switch elf.R_386(t) {
+		case elf.R_386_32, elf.R_386_PC32, elf.R_386_GOT32, elf.R_386_GOT32X, elf.R_386_PLT32, elf.R_386_GOTOFF, elf.R_386_GOTPC,
+			elf.R_386_TLS_GD, elf.R_386_TLS_LDM, elf.R_386_TLS_LDO_32, elf.R_386_TLS_IE, elf.R_386_TLS_GOTIE, elf.R_386_TLS_LE,
+			elf.R_386_TLS_IE_32, elf.R_386_TLS_LE_32, elf.R_386_TLS_GOTDESC:
+			return 4
+		case elf.R_386_16, elf.R_386_PC16:
+			return 2
+		case elf.R_386_8, elf.R_386_PC8:
+			return 1
+		}
+	case elf.EM_ARM:
+		switch elf.R_ARM(t) {
+		case elf.R_ARM_ABS32, elf.R_ARM_REL32, elf.R_ARM_TARGET1, elf.R_ARM_TARGET2, elf.R_ARM_GOTOFF, elf.R_ARM_GOTPC,
+			elf.R_ARM_GOT32, elf.R_ARM_GOT_PREL, elf.R_ARM_TLS_GD32, elf.R_ARM_TLS_LDM32, elf.R_ARM_TLS_LDO32, elf.R_ARM_TLS_IE32, elf.R_ARM_TLS_LE32:
+			return 4
+		case elf.R_ARM_ABS16:
+			return 2
+		case elf.R_ARM_ABS8:
+			return 1
+		}
+	case elf.EM_MIPS:
+		switch elf.R_MIPS(t) {
+		case elf.R_MIPS_32, elf.R_MIPS_REL32, elf.R_MIPS_GPREL32:
+			return 4
+		case elf.R_MIPS_64:
+			return 8
+		case elf.R_MIPS_16:
+			return 2
+		}
+	}
+	return 0
+}
+
+// implicitAddend reads the addend a REL entry of a relocatable file keeps
+// in the field it patches, in the data of the section relSec applies to.
+func implicitAddend(elfFs *elfFile, relSec sectionHeader, e relocEntry) (int64, bool) {
+	size := relocFieldSize(e.Type, elfFs.FileHdr.Machine)
+	sh := getSectionHeader(relSec.Info, elfFs)
+	if size == 0 || sh.Type == elf.SHT_NOBITS || sh.Flags&elf.SHF_COMPRESSED != 0 || e.Off > sh.Size || size > sh.Size-e.Off {
+		return 0, false
+	}
+	buf := make([]byte, size)
+	if _, err := elfFs.Fh.ReadAt(buf, int64(sh.Off+e.Off)); err != nil {
+		return 0, false
+	}
+	order := elfFs.FileHdr.Endianness
+	switch size {
+	case 1:
+		return int64(int8(buf[0])), true
+	case 2:
+		return int64(int16(order.Uint16(buf))), true
+	case 4:
+		return int64(int32(order.Uint32(buf))), true
+	}
+	return int64(order.Uint64(buf)), true
+}
+
+// relocTarget describes where a relocation applies and what it resolves
+// to. The place is named after the symbol or section covering it, or as a
+// GOT slot for JUMP_SLOT and GLOB_DAT. Relative relocations resolve to the
+// load address plus their addend, read from the place itself for REL and
+// RELR, others to their symbol plus addend, which REL entries of
+// relocatable files keep in the relocated section.
+func relocTarget(elfFs *elfFile, relSec sectionHeader, e relocEntry, s elfSymbol, symName string, idx symbolIndex) string {
+	kind := relocKind(e.Type, elfFs.FileHdr.Machine)
+	if !e.HasAddend && relSec.Type == elf.SHT_REL && elfHeaderType(elfFs) == elf.ET_REL {
+		e.Addend, e.HasAddend = implicitAddend(elfFs, relSec, e)
+	}
+
+	place := placeString(elfFs, idx, relSec.Info, e.Off)
+	if kind == relocJumpSlot || kind == relocGlobDat {
+		if ndx, ok := sectionAt(elfFs, e.Off); ok {
+			sh := getSectionHeader(ndx, elfFs)
+			word := uint64(8)
+			if elfFs.FileHdr.Arch == elf.ELFCLASS32 {
+				word = 4
+			}
+			place = fmt.Sprintf("%s[%d]", sh.Name, (e.Off-sh.Addr)/word)
+		}
+	}
+
+	var value string
+	switch {
+	case kind == relocRelative || kind == relocIRelative:
+		addend, ok := uint64(e.Addend), e.HasAddend
+		if !ok {
+			addend, ok = readWord(elfFs, e.Off)
+		}
+		if !ok {
+			return place
+		}
+		value = fmt.Sprintf("0x%x <%s>", addend, placeString(elfFs, idx, 0, addend))
+	case e.Sym == 0:
+		if !e.HasAddend {
+			return place
+		}
+		value = fmt.Sprintf("0x%x", uint64(e.Addend))
+	case elf.ST_TYPE(s.Info) == elf.STT_SECTION && elfHeaderType(elfFs) == elf.ET_REL:
+		value = placeString(elfFs, idx, uint32(s.Shndx), uint64(e.Addend))
+	default:
+		value = symOffset(symName, e.Addend)
+	}
+	return place + " -> " + value
+}
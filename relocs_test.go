@@ -0,0 +1,147 @@
+package main
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeRelr(t *testing.T) {
+	tests := []struct {
+		name  string
+		ident enumIdent
+		words []uint64
+		want  relrOffsets
+	}{
+		{
+			"64-bit", enumIdent{binary.LittleEndian, elf.ELFCLASS64, elf.EM_X86_64},
+			/* an address, a bitmap over the next words, one over the 63 after those, then a new address */
+			[]uint64{0x10000, 0x17, 0x8000000000000001, 0x20000},
+			relrOffsets{0x10000, 0x10008, 0x10010, 0x10020, 0x103f0, 0x20000},
+		},
+		{
+			"32-bit", enumIdent{binary.BigEndian, elf.ELFCLASS32, elf.EM_PPC},
+			[]uint64{0x1000, 0x7, 0x80000001},
+			relrOffsets{0x1000, 0x1004, 0x1008, 0x10f8},
+		},
+	}
+	for _, tt := range tests {
+		elfFs := &elfFile{FileHdr: tt.ident}
+		var data []byte
+		for _, w := range tt.words {
+			var b [8]byte
+			if tt.ident.Arch == elf.ELFCLASS32 {
+				tt.ident.Endianness.PutUint32(b[:], uint32(w))
+				data = append(data, b[:4]...)
+			} else {
+				tt.ident.Endianness.PutUint64(b[:], w)
+				data = append(data, b[:]...)
+			}
+		}
+		if got := decodeRelr(data, elfFs); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %#x, want %#x", tt.name, got, tt.want)
+		}
+	}
+}
+
+func sleb128(v int64) []byte {
+	var out []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 && c&0x40 == 0 || v == -1 && c&0x40 != 0 {
+			return append(out, c)
+		}
+		out = append(out, c|0x80)
+	}
+}
+
+func TestDecodeAPS2(t *testing.T) {
+	data := []byte("APS2")
+	for _, v := range []int64{
+		5, 0x1000, // count, starting offset
+		2, apsGroupHasAddend, 0x10, 8, 0x200, 8, 8, -0x100, // offset delta, info and addend delta per entry
+		3, apsGroupedByInfo | apsGroupedByOffsetDelta, 8, 0x100000006, // all three share both, no addend
+	} {
+		data = append(data, sleb128(v)...)
+	}
+
+	elf64 := &elfFile{FileHdr: enumIdent{binary.LittleEndian, elf.ELFCLASS64, elf.EM_X86_64}}
+	got, err := decodeAPS2(data, true, elf64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []elf.Rela64{
+		{Off: 0x1010, Info: 8, Addend: 0x200},
+		{Off: 0x1018, Info: 8, Addend: 0x100},
+		{Off: 0x1020, Info: 0x100000006},
+		{Off: 0x1028, Info: 0x100000006},
+		{Off: 0x1030, Info: 0x100000006},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RELA: got %+v, want %+v", got, want)
+	}
+
+	elf32 := &elfFile{FileHdr: enumIdent{binary.LittleEndian, elf.ELFCLASS32, elf.EM_ARM}}
+	got, err = decodeAPS2(data, false, elf32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rels, ok := got.([]elf.Rel32); !ok || len(rels) != 5 || rels[4] != (elf.Rel32{Off: 0x1030, Info: 6}) {
+		t.Errorf("REL: got %+v", got)
+	}
+
+	for _, bad := range [][]byte{data[:len(data)-1], append([]byte("APS1"), data[4:]...)} {
+		if _, err := decodeAPS2(bad, true, elf64); err == nil {
+			t.Errorf("% x decoded without an error", bad)
+		}
+	}
+}
+
+// testdata/rel386.o is assembled with as --32 from
+//
+//		.section .rodata
+//	.Ls1:	.string "abcd"
+//	.Ls2:	.string "efgh"
+//		.text
+//		.globl _start
+//	_start:	movl $.Ls2, %eax
+//		call ext
+//		movl $.Ls1+2, %ebx
+//	here:	jmp here
+//		.data
+//		.long _start+3
+//		.long here
+//
+// Its REL entries keep their addends in the relocated sections.
+func TestRelocTargetREL(t *testing.T) {
+	elfFs, err := openElfFile("testdata/rel386.o")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer elfFs.Fh.Close()
+	elfFs.loadAllSymbols()
+	elfFs.getRelocations()
+	idx := newSymbolIndex(elfFs)
+
+	want := map[string][]string{
+		".rel.text": {"_start+0x1 -> .rodata+0x5", "_start+0x6 -> ext-0x4", "_start+0xb -> .rodata+0x2"},
+		".rel.data": {".data -> _start+0x3", ".data+0x4 -> here"},
+	}
+	for ndx, rels := range elfFs.Rels {
+		sh := getSectionHeader(ndx, elfFs)
+		var got []string
+		for _, e := range relocEntries(rels, elfFs.FileHdr.Machine) {
+			s, _ := getSymbol(e.Sym, sym, elfFs)
+			got = append(got, relocTarget(elfFs, sh, e, s, symDisplayName(s, e.Sym, nil, elfFs), idx))
+		}
+		if !reflect.DeepEqual(got, want[sh.Name]) {
+			t.Errorf("%s: got %q, want %q", sh.Name, got, want[sh.Name])
+		}
+		delete(want, sh.Name)
+	}
+	if len(want) != 0 {
+		t.Errorf("relocation sections missing: %v", want)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"debug/elf"
+	"fmt"
+	"sort"
+)
+
+/* rows of the exported symbol list --reloc-stats prints before folding the rest into one line */
+const relocStatsRows = 30
+
+/* a name and how often it came up, sorted most first and then by name */
+type relocCount struct {
+	Name  string
+	Count int
+}
+
+func sortedCounts(m map[string]int) []relocCount {
+	var list []relocCount
+	for name, n := range m {
+		list = append(list, relocCount{name, n})
+	}
+	sort.Slice(list, func(a, b int) bool {
+		if list[a].Count != list[b].Count {
+			return list[a].Count > list[b].Count
+		}
+		return list[a].Name < list[b].Name
+	})
+	return list
+}
+
+func percentOf(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
+
+// printRelocStats is --reloc-stats: the dynamic relocations counted per
+// section and type, split into relative and symbolic ones, with the costs
+// ld.so pays for them at startup. Symbolic relocations against symbols the
+// file exports with default visibility are listed per symbol, each is a
+// lookup -Bsymbolic or protected visibility would turn into a relative
+// relocation. The startup estimate counts the lookups done at load time
+// (JUMP_SLOT ones are deferred unless the file is BIND_NOW), the IFUNC
+// resolvers run and the 4 KiB pages relocations write to.
+func printRelocStats(elfFs *elfFile) {
+	elfFs.loadAllSymbols()
+	elfFs.getRelocations()
+	dyn := loadDynamic(elfFs)
+	m := elfFs.FileHdr.Machine
+
+	_, dtBindNow := dyn.value(elf.DT_BIND_NOW)
+	bindNow := dtBindNow || dyn.hasFlag(elf.DF_BIND_NOW) || dyn.hasFlag1(elf.DF_1_NOW)
+	_, dtTextrel := dyn.value(elf.DT_TEXTREL)
+	textrel := dtTextrel || dyn.hasFlag(elf.DF_TEXTREL)
+
+	elfFs.getProgHeaders()
+	var readOnly []progHeader
+	for i := 0; i < numProgHeaders(elfFs); i++ {
+		if ph := getProgHeader(i, elfFs); ph.Type == elf.PT_LOAD && ph.Flags&elf.PF_W == 0 {
+			readOnly = append(readOnly, ph)
+		}
+	}
+
+	var secs []uint32
+	for k := range elfFs.Rels {
+		if getSectionHeader(k, elfFs).Flags&elf.SHF_ALLOC != 0 {
+			secs = append(secs, k)
+		}
+	}
+	sort.Slice(secs, func(a, b int) bool { return secs[a] < secs[b] })
+	if len(secs) == 0 {
+		fmt.Println("No dynamic relocations found in target")
+		return
+	}
+
+	var total, relative, symbolic, other, copies, irelative, ifunc, textRelocs, lazy, lookups int
+	types := map[string]int{}
+	exported := map[string]int{}
+	looked := map[string]bool{}
+	pages := map[uint64]bool{}
+
+	fmt.Printf("%-20s %10s\n", "Section", "Entries")
+	for _, k := range secs {
+		sh := getSectionHeader(k, elfFs)
+		symType := sym
+		if getSectionHeader(sh.Link, elfFs).Type == elf.SHT_DYNSYM {
+			symType = dynSym
+		}
+		entries := relocEntries(elfFs.Rels[k], m)
+		fmt.Printf("%-20s %10d\n", sh.Name, len(entries))
+
+		for _, e := range entries {
+			total++
+			types[resolveRelocType(e.Type, m)]++
+			pages[e.Off>>12] = true
+			for _, ph := range readOnly {
+				if e.Off >= ph.Vaddr && e.Off < ph.Vaddr+ph.Memsz {
+					textRelocs++
+					break
+				}
+			}
+
+			kind := relocKind(e.Type, m)
+			switch kind {
+			case relocCopy:
+				copies++
+			case relocIRelative:
+				irelative++
+			}
+			s, ok := getSymbol(e.Sym, symType, elfFs)
+			switch {
+			case kind == relocRelative:
+				relative++
+				continue
+			case e.Sym == 0 || !ok:
+				other++
+				continue
+			}
+
+			symbolic++
+			if elf.ST_TYPE(s.Info) == sttGNUIFunc {
+				ifunc++
+			}
+			bind := elf.ST_BIND(s.Info)
+			if elf.SectionIndex(s.Shndx) != elf.SHN_UNDEF && (bind == elf.STB_GLOBAL || bind == elf.STB_WEAK) &&
+				elf.ST_VISIBILITY(s.Other) == elf.STV_DEFAULT {
+				exported[displayName(s.Name)]++
+			}
+			if kind == relocJumpSlot && !bindNow {
+				lazy++
+				continue
+			}
+			lookups++
+			looked[s.Name] = true
+		}
+	}
+	fmt.Printf("%-20s %10d\n", "Total", total)
+
+	fmt.Printf("\n%-28s %10s\n", "Type", "Count")
+	for _, c := range sortedCounts(types) {
+		fmt.Printf("%-28s %10d  %5.1f%%\n", c.Name, c.Count, percentOf(c.Count, total))
+	}
+
+	exports := 0
+	for _, n := range exported {
+		exports += n
+	}
+	fmt.Println()
+	fmt.Printf("%-44s %10d  %5.1f%%\n", "Relative", relative, percentOf(relative, total))
+	fmt.Printf("%-44s %10d  %5.1f%%\n", "Symbolic", symbolic, percentOf(symbolic, total))
+	fmt.Printf("%-44s %10d\n", "  against symbols exported from this file", exports)
+	fmt.Printf("%-44s %10d  %5.1f%%\n", "Other (no symbol, not relative)", other, percentOf(other, total))
+	fmt.Printf("%-44s %10d\n", "Copy relocations", copies)
+	fmt.Printf("%-44s %10d\n", "IRELATIVE relocations", irelative)
+	fmt.Printf("%-44s %10d\n", "Symbolic relocations against IFUNC symbols", ifunc)
+	if textrel {
+		fmt.Printf("%-44s %10d (DT_TEXTREL set)\n", "Relocations in read-only segments", textRelocs)
+	} else {
+		fmt.Printf("%-44s %10d\n", "Relocations in read-only segments", textRelocs)
+	}
+
+	fmt.Println("\nStartup estimate:")
+	fmt.Printf("%-44s %10d (%d distinct symbols)\n", "  symbol lookups at load time", lookups, len(looked))
+	if bindNow {
+		fmt.Printf("%-44s %10d (BIND_NOW)\n", "  symbol lookups deferred to first call", lazy)
+	} else {
+		fmt.Printf("%-44s %10d\n", "  symbol lookups deferred to first call", lazy)
+	}
+	fmt.Printf("%-44s %10d\n", "  relative relocations applied", relative)
+	fmt.Printf("%-44s %10d\n", "  IFUNC resolvers run", irelative+ifunc)
+	fmt.Printf("%-44s %10d (%d KiB)\n", "  pages written by relocations", len(pages), len(pages)*4)
+
+	if len(exported) == 0 {
+		return
+	}
+	fmt.Println("\nSymbolic relocations against exported symbols:")
+	fmt.Printf("%10s  %s\n", "Count", "Symbol")
+	list := sortedCounts(exported)
+	for i, c := range list {
+		if i == relocStatsRows {
+			rest := 0
+			for _, c := range list[i:] {
+				rest += c.Count
+			}
+			fmt.Printf("%10d  [%d other symbols]\n", rest, len(list)-i)
+			break
+		}
+		fmt.Printf("%10d  %s\n", c.Count, c.Name)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/* flattened view of an Elf32_Phdr or Elf64_Phdr */
+type progHeader struct {
+	Type   elf.ProgType
+	Flags  elf.ProgFlag
+	Off    uint64
+	Vaddr  uint64
+	Paddr  uint64
+	Filesz uint64
+	Memsz  uint64
+	Align  uint64
+}
+
+//Program Header Table Offset = Phoff
+//Number of Program Header Table Entries = Phnum
+
+func (elfFs *elfFile) getProgHeaders() {
+	switch h := elfFs.Hdr.(type) {
+	case *elf.Header64:
+		elfFs.ElfProgs = make([]elf.Prog64, h.Phnum)
+		sr := io.NewSectionReader(elfFs.Fh, int64(h.Phoff), int64(h.Phentsize)*int64(h.Phnum))
+		err := binary.Read(sr, elfFs.FileHdr.Endianness, elfFs.ElfProgs.([]elf.Prog64))
+		checkError(err)
+
+	case *elf.Header32:
+		elfFs.ElfProgs = make([]elf.Prog32, h.Phnum)
+		sr := io.NewSectionReader(elfFs.Fh, int64(h.Phoff), int64(h.Phentsize)*int64(h.Phnum))
+		err := binary.Read(sr, elfFs.FileHdr.Endianness, elfFs.ElfProgs.([]elf.Prog32))
+		checkError(err)
+	}
+}
+
+func getProgHeader(ndx int, elfFs *elfFile) progHeader {
+	var ph progHeader
+	switch p := elfFs.ElfProgs.(type) {
+	case []elf.Prog32:
+		if ndx < len(p) {
+			ph = progHeader{elf.ProgType(p[ndx].Type), elf.ProgFlag(p[ndx].Flags), uint64(p[ndx].Off), uint64(p[ndx].Vaddr),
+				uint64(p[ndx].Paddr), uint64(p[ndx].Filesz), uint64(p[ndx].Memsz), uint64(p[ndx].Align)}
+		}
+	case []elf.Prog64:
+		if ndx < len(p) {
+			ph = progHeader{elf.ProgType(p[ndx].Type), elf.ProgFlag(p[ndx].Flags), p[ndx].Off, p[ndx].Vaddr,
+				p[ndx].Paddr, p[ndx].Filesz, p[ndx].Memsz, p[ndx].Align}
+		}
+	}
+	return ph
+}
+
+func numProgHeaders(elfFs *elfFile) int {
+	switch p := elfFs.ElfProgs.(type) {
+	case []elf.Prog32:
+		return len(p)
+	case []elf.Prog64:
+		return len(p)
+	}
+	return 0
+}
+
+func progFlagsKey(fl elf.ProgFlag) string {
+	key := []byte("   ")
+	if fl&elf.PF_R != 0 {
+		key[0] = 'R'
+	}
+	if fl&elf.PF_W != 0 {
+		key[1] = 'W'
+	}
+	if fl&elf.PF_X != 0 {
+		key[2] = 'E'
+	}
+	return string(key)
+}
+
+// sectionInSegment mirrors binutils' ELF_SECTION_IN_SEGMENT_STRICT, TLS
+// sections only belong to TLS, RELRO and LOAD segments, and .tbss takes no
+// room outside PT_TLS. The unsigned wrap of "filesz - 1" is intentional.
+func sectionInSegment(sh sectionHeader, ph progHeader) bool {
+	tls := sh.Flags&elf.SHF_TLS != 0
+	if tls && sh.Type == elf.SHT_NOBITS && ph.Type != elf.PT_TLS {
+		return false
+	}
+	if tls && ph.Type != elf.PT_TLS && ph.Type != elf.PT_GNU_RELRO && ph.Type != elf.PT_LOAD {
+		return false
+	}
+	if ph.Type == elf.PT_TLS && !tls || ph.Type == elf.PT_PHDR {
+		return false
+	}
+
+	size := sh.Size
+	if sh.Type != elf.SHT_NOBITS {
+		if sh.Off < ph.Off || sh.Off-ph.Off > ph.Filesz-1 || sh.Off-ph.Off+size > ph.Filesz {
+			return false
+		}
+	}
+	if sh.Flags&elf.SHF_ALLOC != 0 {
+		if sh.Addr < ph.Vaddr || sh.Addr-ph.Vaddr > ph.Memsz-1 || sh.Addr-ph.Vaddr+size > ph.Memsz {
+			return false
+		}
+	}
+
+	/* empty sections at the edges of PT_DYNAMIC and PT_NOTE are left out */
+	if (ph.Type == elf.PT_DYNAMIC || ph.Type == elf.PT_NOTE) && size == 0 && ph.Memsz != 0 {
+		inFile := sh.Type == elf.SHT_NOBITS || sh.Off > ph.Off && sh.Off-ph.Off < ph.Filesz
+		inMem := sh.Flags&elf.SHF_ALLOC == 0 || sh.Addr > ph.Vaddr && sh.Addr-ph.Vaddr < ph.Memsz
+		return inFile && inMem
+	}
+	return true
+}
+
+func printProgramHeaders(elfFs *elfFile, wide bool) {
+	elfFs.getProgHeaders()
+	n := numProgHeaders(elfFs)
+
+	var etype elf.Type
+	var entry, phoff uint64
+	switch h := elfFs.Hdr.(type) {
+	case *elf.Header32:
+		etype, entry, phoff = elf.Type(h.Type), uint64(h.Entry), uint64(h.Phoff)
+	case *elf.Header64:
+		etype, entry, phoff = elf.Type(h.Type), h.Entry, h.Phoff
+	}
+	if n == 0 {
+		fmt.Println("There are no program headers in this file")
+		return
+	}
+
+	fmt.Printf("Elf file type is %s\n", etype)
+	fmt.Printf("Entry point 0x%x\n", entry)
+	fmt.Printf("%d Program Headers @ Offset 0x%x\n", n, phoff)
+
+	width := 16
+	if elfFs.FileHdr.Arch == elf.ELFCLASS32 {
+		width = 8
+	}
+	if wide {
+		fmt.Printf("[NR]  %-16s%-*s  %-*s  %-*s  %-*s  %-*s  Flg Align\n", "Type", width+2, "Offset", width+2, "VirtAddr",
+			width+2, "PhysAddr", width+2, "FileSiz", width+2, "MemSiz")
+	} else {
+		fmt.Printf("[NR]  %-16s%-*s  %-*s  %s\n", "Type", width+2, "Offset", width+2, "VirtAddr", "PhysAddr")
+		fmt.Printf("      %-16s%-*s  %-*s  Flg Align\n", "", width+2, "FileSiz", width+2, "MemSiz")
+	}
+
+	for i := 0; i < n; i++ {
+		ph := getProgHeader(i, elfFs)
+		t := strings.TrimPrefix(ph.Type.String(), "PT_")
+		if wide {
+			fmt.Printf("[%-2d]  %-16s0x%0*x  0x%0*x  0x%0*x  0x%0*x  0x%0*x  %s 0x%x\n", i, t, width, ph.Off, width, ph.Vaddr,
+				width, ph.Paddr, width, ph.Filesz, width, ph.Memsz, progFlagsKey(ph.Flags), ph.Align)
+		} else {
+			fmt.Printf("[%-2d]  %-16s0x%0*x  0x%0*x  0x%0*x\n", i, t, width, ph.Off, width, ph.Vaddr, width, ph.Paddr)
+			fmt.Printf("      %-16s0x%0*x  0x%0*x  %s 0x%x\n", "", width, ph.Filesz, width, ph.Memsz, progFlagsKey(ph.Flags), ph.Align)
+		}
+
+		if ph.Type == elf.PT_INTERP {
+			interp := make([]byte, ph.Filesz)
+			if _, err := elfFs.Fh.ReadAt(interp, int64(ph.Off)); err == nil {
+				fmt.Printf("      [Requesting program interpreter: %s]\n", bytes.TrimRight(interp, "\x00"))
+			}
+		}
+	}
+
+	if len(elfFs.ElfSections.SectionName) == 0 {
+		return
+	}
+	fmt.Println("\nSection to Segment mapping:")
+	fmt.Println(" Segment Sections...")
+	for i := 0; i < n; i++ {
+		ph := getProgHeader(i, elfFs)
+		fmt.Printf("  %02d     ", i)
+		for ndx := uint32(1); ndx < uint32(len(elfFs.ElfSections.SectionName)); ndx++ {
+			if sh := getSectionHeader(ndx, elfFs); sectionInSegment(sh, ph) {
+				fmt.Printf("%s ", sh.Name)
+			}
+		}
+		fmt.Println()
+	}
+}
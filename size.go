@@ -0,0 +1,231 @@
+package main
+
+import (
+	"debug/elf"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+/* one object's share of a size report, Names, Sizes and Addrs are the sections the SysV format lists */
+type sizeEntry struct {
+	Name, Archive   string // Archive is set for archive members
+	Text, Data, Bss uint64
+	Names           []string
+	Sizes, Addrs    []uint64
+}
+
+/* running totals over every object reported, per section name in first seen order for the SysV format */
+type sizeReport struct {
+	Format          string // "berkeley" or "sysv"
+	Objects         int
+	Text, Data, Bss uint64
+	SecNames        []string
+	SecTotals       map[string]uint64
+}
+
+func shstrIndex(elfFs *elfFile) uint32 {
+	switch h := elfFs.Hdr.(type) {
+	case *elf.Header64:
+		_, ndx := sectionCounts(elfFs, int64(h.Shoff), uint32(h.Shnum), uint32(h.Shstrndx))
+		return ndx
+	case *elf.Header32:
+		_, ndx := sectionCounts(elfFs, int64(h.Shoff), uint32(h.Shnum), uint32(h.Shstrndx))
+		return ndx
+	}
+	return 0
+}
+
+// sizeSections returns the sections size reports, the ones BFD turns into
+// sections of their own. Symbol and string tables of .symtab, the section
+// name table and relocations applying to a section are left out.
+func sizeSections(elfFs *elfFile) []uint32 {
+	shstrndx := shstrIndex(elfFs)
+	n := uint32(len(elfFs.ElfSections.SectionName))
+	var list []uint32
+	for i := uint32(1); i < n; i++ {
+		sh := getSectionHeader(i, elfFs)
+		switch sh.Type {
+		case elf.SHT_SYMTAB, elf.SHT_SYMTAB_SHNDX:
+			continue
+		case elf.SHT_STRTAB:
+			if i == shstrndx || isSymtabStrings(i, elfFs) {
+				continue
+			}
+		case elf.SHT_REL, elf.SHT_RELA:
+			if sh.Flags&elf.SHF_ALLOC == 0 && sh.Info != 0 && sh.Link < n &&
+				getSectionHeader(sh.Link, elfFs).Type == elf.SHT_SYMTAB {
+				continue
+			}
+		}
+		list = append(list, i)
+	}
+	return list
+}
+
+func isSymtabStrings(ndx uint32, elfFs *elfFile) bool {
+	for _, s := range getSectionByType(elf.SHT_SYMTAB, elfFs) {
+		if getSectionHeader(s, elfFs).Link == ndx {
+			return true
+		}
+	}
+	return false
+}
+
+// sizeOf sorts the allocated sections into text, data and bss as size(1)
+// does: code and read-only sections count as text, the remaining ones as data
+// when they occupy file space and as bss when they do not.
+func sizeOf(elfFs *elfFile, name, archive string) sizeEntry {
+	e := sizeEntry{Name: name, Archive: archive}
+	for _, i := range sizeSections(elfFs) {
+		sh := getSectionHeader(i, elfFs)
+		e.Names = append(e.Names, sh.Name)
+		e.Sizes = append(e.Sizes, sh.Size)
+		e.Addrs = append(e.Addrs, sh.Addr)
+		if sh.Flags&elf.SHF_ALLOC == 0 {
+			continue
+		}
+		switch {
+		case sh.Flags&elf.SHF_EXECINSTR != 0 || sh.Flags&elf.SHF_WRITE == 0:
+			e.Text += sh.Size
+		case sh.Type != elf.SHT_NOBITS:
+			e.Data += sh.Size
+		default:
+			e.Bss += sh.Size
+		}
+	}
+	return e
+}
+
+func (r *sizeReport) add(e sizeEntry) {
+	r.Objects++
+	r.Text += e.Text
+	r.Data += e.Data
+	r.Bss += e.Bss
+	for i, name := range e.Names {
+		if _, ok := r.SecTotals[name]; !ok {
+			r.SecNames = append(r.SecNames, name)
+		}
+		r.SecTotals[name] += e.Sizes[i]
+	}
+
+	if r.Format == "berkeley" {
+		name := e.Name
+		if e.Archive != "" {
+			name += " (ex " + e.Archive + ")"
+		}
+		printBerkeleyRow(e.Text, e.Data, e.Bss, name)
+		return
+	}
+	if e.Archive != "" {
+		fmt.Printf("%s   (ex %s):\n", e.Name, e.Archive)
+	} else {
+		fmt.Printf("%s  :\n", e.Name)
+	}
+	printSysvTable(e.Names, e.Sizes, e.Addrs)
+}
+
+func printBerkeleyRow(text, data, bss uint64, name string) {
+	total := text + data + bss
+	fmt.Printf("%7d\t%7d\t%7d\t%7d\t%7x\t%s\n", text, data, bss, total, total, name)
+}
+
+/* the section, size and addr columns, addrs nil for the per-section totals which have no address */
+func printSysvTable(names []string, sizes, addrs []uint64) {
+	nameW, sizeW, addrW := len("section"), len("size"), len("addr")
+	var total uint64
+	for i := range names {
+		nameW = max(nameW, len(names[i]))
+		sizeW = max(sizeW, len(strconv.FormatUint(sizes[i], 10)))
+		if addrs != nil {
+			addrW = max(addrW, len(strconv.FormatUint(addrs[i], 10)))
+		}
+		total += sizes[i]
+	}
+
+	if addrs != nil {
+		fmt.Printf("%-*s   %*s   %*s\n", nameW, "section", sizeW, "size", addrW, "addr")
+	} else {
+		fmt.Printf("%-*s   %*s\n", nameW, "section", sizeW, "size")
+	}
+	for i := range names {
+		if addrs != nil {
+			fmt.Printf("%-*s   %*d   %*d\n", nameW, names[i], sizeW, sizes[i], addrW, addrs[i])
+		} else {
+			fmt.Printf("%-*s   %*d\n", nameW, names[i], sizeW, sizes[i])
+		}
+	}
+	fmt.Printf("%-*s   %*d\n\n\n", nameW, "Total", sizeW, total)
+}
+
+/* reports one file, every ELF member of an archive in its place */
+func (r *sizeReport) file(path string) bool {
+	fh, err := os.Open(path)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return false
+	}
+	defer fh.Close()
+
+	var magic [8]byte
+	fh.ReadAt(magic[:], 0)
+	if !isArchive(magic[:]) {
+		elfFs, err := openElfReader(fh, path)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return false
+		}
+		return runGuarded(path, func() { r.add(sizeOf(elfFs, path, "")) })
+	}
+
+	ok := true
+	return runGuarded(path, func() {
+		st, err := fh.Stat()
+		checkError(err)
+		ar, err := parseArchive(fh, st.Size(), path)
+		checkError(err)
+		for _, m := range ar.Members {
+			member, err := ar.openMember(m, fh)
+			if err != nil {
+				fmt.Printf("Skipping %s: %v\n", ar.memberName(&m), err)
+				continue
+			}
+			if !runGuarded(ar.memberName(&m), func() { r.add(sizeOf(member, m.Name, path)) }) {
+				ok = false
+			}
+			member.Fh.Close()
+		}
+	}) && ok
+}
+
+// printSizes is --size: text, data and bss per file or archive member in
+// the Berkeley format, or every section in the SysV one. With more than one
+// object a (TOTALS) row, or a table summing each section name, follows.
+func printSizes(files []string, format string) bool {
+	r := &sizeReport{Format: format, SecTotals: map[string]uint64{}}
+	if format == "berkeley" {
+		fmt.Printf("%7s\t%7s\t%7s\t%7s\t%7s\t%s\n", "text", "data", "bss", "dec", "hex", "filename")
+	}
+
+	ok := true
+	for _, file := range files {
+		if !r.file(file) {
+			ok = false
+		}
+	}
+	if r.Objects < 2 {
+		return ok
+	}
+
+	if format == "berkeley" {
+		printBerkeleyRow(r.Text, r.Data, r.Bss, "(TOTALS)")
+		return ok
+	}
+	sizes := make([]uint64, len(r.SecNames))
+	for i, name := range r.SecNames {
+		sizes[i] = r.SecTotals[name]
+	}
+	fmt.Printf("(TOTALS)  :\n")
+	printSysvTable(r.SecNames, sizes, nil)
+	return ok
+}
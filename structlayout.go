@@ -0,0 +1,497 @@
+package main
+
+import (
+	"debug/dwarf"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const cacheLineSize = 64
+
+// DWARF expression opcodes found in DW_AT_data_member_location
+const (
+	opConstu     = 0x10
+	opPlusUconst = 0x23
+)
+
+type structMember struct {
+	Name     string
+	Type     *dwarfEntry
+	BitOff   uint64 // from the start of the struct
+	BitSize  uint64 // zero unless a bitfield
+	ByteSize uint64
+}
+
+type structLayout struct {
+	Name       string
+	Entry      *dwarfEntry
+	Size       uint64
+	Members    []structMember
+	Holes      int
+	SumHoles   uint64 // bytes
+	BitHoles   int
+	SumBitHole uint64
+	Padding    uint64
+	SumMembers uint64
+}
+
+/* strips typedefs and cv qualifiers to reach the underlying type */
+func (d *dwarfData) stripType(t *dwarfEntry) *dwarfEntry {
+	for depth := 0; t != nil && depth < 32; depth++ {
+		switch t.Tag {
+		case dwarf.TagTypedef, dwarf.TagConstType, dwarf.TagVolatileType, dwarf.TagRestrictType, dwarf.TagAtomicType:
+			t = d.refVal(t, dwarf.AttrType)
+		default:
+			return t
+		}
+	}
+	return t
+}
+
+func (d *dwarfData) typeSize(t *dwarfEntry) uint64 {
+	for depth := 0; t != nil && depth < 32; depth++ {
+		if sz, ok := t.uintVal(dwarf.AttrByteSize); ok {
+			return sz
+		}
+		switch t.Tag {
+		case dwarf.TagPointerType, dwarf.TagReferenceType, dwarf.TagRvalueReferenceType, dwarf.TagPtrToMemberType:
+			return uint64(t.Unit.AddrSize)
+		case dwarf.TagArrayType:
+			n := uint64(1)
+			for _, c := range d.arrayDims(t) {
+				n *= c
+			}
+			return n * d.typeSize(d.refVal(t, dwarf.AttrType))
+		}
+		t = d.refVal(t, dwarf.AttrType)
+	}
+	return 0
+}
+
+func (d *dwarfData) arrayDims(t *dwarfEntry) []uint64 {
+	var dims []uint64
+	for _, c := range t.Children {
+		if c.Tag != dwarf.TagSubrangeType {
+			continue
+		}
+		if n, ok := c.uintVal(dwarf.AttrCount); ok {
+			dims = append(dims, n)
+		} else if ub, ok := c.uintVal(dwarf.AttrUpperBound); ok {
+			lb, _ := c.uintVal(dwarf.AttrLowerBound)
+			dims = append(dims, ub-lb+1)
+		} else {
+			/* flexible array member */
+			dims = append(dims, 0)
+		}
+	}
+	return dims
+}
+
+/* name with enclosing namespaces and classes, the way C++ spells it */
+func (d *dwarfData) qualifiedName(t *dwarfEntry) string {
+	name := d.entryName(t)
+	if name == "" {
+		return ""
+	}
+	for p := t.Parent; p != nil; p = p.Parent {
+		switch p.Tag {
+		case dwarf.TagNamespace, dwarf.TagStructType, dwarf.TagClassType, dwarf.TagUnionType:
+			pn := d.entryName(p)
+			if pn == "" {
+				pn = "(anonymous namespace)"
+			}
+			name = pn + "::" + name
+		}
+	}
+	return name
+}
+
+func (d *dwarfData) tagPrefix(t *dwarfEntry) string {
+	switch t.Tag {
+	case dwarf.TagStructType:
+		return "struct "
+	case dwarf.TagClassType:
+		return "class "
+	case dwarf.TagUnionType:
+		return "union "
+	case dwarf.TagEnumerationType:
+		return "enum "
+	}
+	return ""
+}
+
+/* C declarator for a member of type t named name */
+func (d *dwarfData) declare(t *dwarfEntry, name string) string {
+	join := func(typ, decl string) string {
+		if decl == "" {
+			return typ
+		}
+		return typ + " " + decl
+	}
+
+	for depth := 0; depth < 32; depth++ {
+		if t == nil {
+			return join("void", name)
+		}
+		switch t.Tag {
+		case dwarf.TagPointerType, dwarf.TagReferenceType, dwarf.TagRvalueReferenceType:
+			sym := "*"
+			if t.Tag == dwarf.TagReferenceType {
+				sym = "&"
+			} else if t.Tag == dwarf.TagRvalueReferenceType {
+				sym = "&&"
+			}
+			target := d.refVal(t, dwarf.AttrType)
+			if target != nil && (target.Tag == dwarf.TagArrayType || target.Tag == dwarf.TagSubroutineType) {
+				name = "(" + sym + name + ")"
+			} else {
+				name = sym + name
+			}
+			t = target
+		case dwarf.TagConstType, dwarf.TagVolatileType, dwarf.TagRestrictType, dwarf.TagAtomicType:
+			q := map[dwarf.Tag]string{
+				dwarf.TagConstType:    "const",
+				dwarf.TagVolatileType: "volatile",
+				dwarf.TagRestrictType: "restrict",
+				dwarf.TagAtomicType:   "_Atomic",
+			}[t.Tag]
+			target := d.refVal(t, dwarf.AttrType)
+			if target != nil && (target.Tag == dwarf.TagPointerType || target.Tag == dwarf.TagArrayType) {
+				name = q + " " + name
+				t = target
+				continue
+			}
+			return q + " " + d.declare(target, name)
+		case dwarf.TagArrayType:
+			for _, n := range d.arrayDims(t) {
+				if n == 0 {
+					name += "[]"
+				} else {
+					name += fmt.Sprintf("[%d]", n)
+				}
+			}
+			t = d.refVal(t, dwarf.AttrType)
+		case dwarf.TagSubroutineType:
+			var params []string
+			for _, c := range t.Children {
+				switch c.Tag {
+				case dwarf.TagFormalParameter:
+					params = append(params, d.declare(d.refVal(c, dwarf.AttrType), ""))
+				case dwarf.TagUnspecifiedParameters:
+					params = append(params, "...")
+				}
+			}
+			if len(params) == 0 && !t.flag(dwarf.AttrPrototyped) {
+				name += "()"
+			} else if len(params) == 0 {
+				name += "(void)"
+			} else {
+				name += "(" + strings.Join(params, ", ") + ")"
+			}
+			t = d.refVal(t, dwarf.AttrType)
+		default:
+			typ := d.entryName(t)
+			if typ == "" {
+				typ = "{...}"
+			}
+			return join(d.tagPrefix(t)+typ, name)
+		}
+	}
+	return name
+}
+
+/* byte offset encoded in DW_AT_data_member_location, either a constant or a tiny expression */
+func memberLocation(a *dwarfAttr, order binary.ByteOrder) uint64 {
+	if a == nil {
+		return 0
+	}
+	if isConstForm(a.Form) {
+		return a.Val
+	}
+	if a.Block != nil {
+		b := &dwarfBuf{data: a.Block, order: order}
+		switch b.u8() {
+		case opPlusUconst, opConstu:
+			return b.uleb()
+		}
+	}
+	return 0
+}
+
+func (d *dwarfData) layoutStruct(t *dwarfEntry) *structLayout {
+	l := &structLayout{Entry: t, Name: d.tagPrefix(t) + d.qualifiedName(t)}
+	l.Size, _ = t.uintVal(dwarf.AttrByteSize)
+
+	for _, c := range t.Children {
+		if c.Tag != dwarf.TagMember && c.Tag != dwarf.TagInheritance {
+			continue
+		}
+		if c.flag(dwarf.AttrExternal) || c.flag(dwarf.AttrDeclaration) {
+			/* static data member */
+			continue
+		}
+
+		m := structMember{Name: d.entryName(c), Type: d.refVal(c, dwarf.AttrType)}
+		if c.Tag == dwarf.TagInheritance {
+			m.Name = "<ancestor>"
+		}
+		m.ByteSize = d.typeSize(m.Type)
+		byteOff := memberLocation(c.attr(dwarf.AttrDataMemberLoc), d.Order)
+		m.BitOff = byteOff * 8
+
+		if bs, ok := c.uintVal(dwarf.AttrBitSize); ok {
+			m.BitSize = bs
+			if dbo, ok := c.uintVal(dwarf.AttrDataBitOffset); ok {
+				m.BitOff = dbo
+			} else if bo, ok := c.uintVal(dwarf.AttrBitOffset); ok {
+				/* DWARF 2/3 count from the most significant bit of the storage unit */
+				storage := m.ByteSize
+				if sz, ok := c.uintVal(dwarf.AttrByteSize); ok {
+					storage = sz
+				}
+				if d.Order == binary.LittleEndian {
+					m.BitOff = byteOff*8 + storage*8 - bo - bs
+				} else {
+					m.BitOff = byteOff*8 + bo
+				}
+			}
+		}
+		l.Members = append(l.Members, m)
+	}
+
+	if t.Tag == dwarf.TagUnionType {
+		var largest uint64
+		for _, m := range l.Members {
+			if m.ByteSize > largest {
+				largest = m.ByteSize
+			}
+		}
+		l.SumMembers = largest
+		if l.Size > largest {
+			l.Padding = l.Size - largest
+		}
+		return l
+	}
+
+	var end uint64 // in bits
+	for _, m := range l.Members {
+		if m.BitSize == 0 {
+			l.SumMembers += m.ByteSize
+		}
+		if m.BitOff > end {
+			gap := m.BitOff - end
+			if gap%8 == 0 {
+				l.Holes++
+				l.SumHoles += gap / 8
+			} else {
+				l.BitHoles++
+				l.SumBitHole += gap
+			}
+		}
+		if e := m.BitOff + memberBits(m); e > end {
+			end = e
+		}
+	}
+
+	/* bitfields count towards members by the bytes they span */
+	var bitBits uint64
+	for _, m := range l.Members {
+		if m.BitSize != 0 {
+			bitBits += m.BitSize
+		}
+	}
+	l.SumMembers += (bitBits + 7) / 8
+
+	if l.Size*8 > end {
+		l.Padding = (l.Size*8 - end) / 8
+	}
+	return l
+}
+
+func memberBits(m structMember) uint64 {
+	if m.BitSize != 0 {
+		return m.BitSize
+	}
+	return m.ByteSize * 8
+}
+
+func printStructLayout(d *dwarfData, l *structLayout) {
+	fmt.Printf("%s {\n", l.Name)
+
+	var end uint64
+	line := uint64(0)
+	for _, m := range l.Members {
+		if l.Entry.Tag != dwarf.TagUnionType && m.BitOff > end {
+			gap := m.BitOff - end
+			if gap%8 == 0 {
+				fmt.Printf("\n\t/* XXX %d bytes hole, try to pack */\n\n", gap/8)
+			} else {
+				fmt.Printf("\n\t/* XXX %d bits hole, try to pack */\n\n", gap)
+			}
+		}
+
+		if cl := m.BitOff / 8 / cacheLineSize; cl > line {
+			line = cl
+			fmt.Printf("\t/* --- cacheline %d boundary (%d bytes) --- */\n", cl, cl*cacheLineSize)
+		}
+
+		decl := d.declare(m.Type, m.Name)
+		if m.BitSize != 0 {
+			decl += fmt.Sprintf(":%d", m.BitSize)
+			fmt.Printf("\t%-40s /* %5d:%2d %5d */\n", decl+";", m.BitOff/8, m.BitOff%8, m.ByteSize)
+		} else {
+			fmt.Printf("\t%-40s /* %5d    %5d */\n", decl+";", m.BitOff/8, m.ByteSize)
+		}
+
+		if e := m.BitOff + memberBits(m); e > end {
+			end = e
+		}
+	}
+
+	fmt.Println()
+	cachelines := (l.Size + cacheLineSize - 1) / cacheLineSize
+	fmt.Printf("\t/* size: %d, cachelines: %d, members: %d */\n", l.Size, cachelines, len(l.Members))
+	if l.Holes > 0 || l.BitHoles > 0 {
+		fmt.Printf("\t/* sum members: %d, holes: %d, sum holes: %d */\n", l.SumMembers, l.Holes, l.SumHoles)
+		if l.BitHoles > 0 {
+			fmt.Printf("\t/* bit holes: %d, sum bit holes: %d bits */\n", l.BitHoles, l.SumBitHole)
+		}
+	}
+	if l.Padding > 0 {
+		fmt.Printf("\t/* padding: %d */\n", l.Padding)
+	}
+	if last := l.Size % cacheLineSize; last != 0 && cachelines > 0 {
+		fmt.Printf("\t/* last cacheline: %d bytes */\n", last)
+	}
+	fmt.Println("};")
+}
+
+/* complete struct, class and union definitions, first definition of each name wins */
+func (d *dwarfData) structTypes() []*dwarfEntry {
+	var types []*dwarfEntry
+	seen := make(map[string]bool)
+
+	var walk func(e *dwarfEntry)
+	walk = func(e *dwarfEntry) {
+		for _, c := range e.Children {
+			switch c.Tag {
+			case dwarf.TagStructType, dwarf.TagClassType, dwarf.TagUnionType:
+				if _, ok := c.uintVal(dwarf.AttrByteSize); ok && !c.flag(dwarf.AttrDeclaration) {
+					name := d.tagPrefix(c) + d.qualifiedName(c)
+					if d.entryName(c) != "" && !seen[name] {
+						seen[name] = true
+						types = append(types, c)
+					}
+				}
+				walk(c)
+			case dwarf.TagNamespace, dwarf.TagModule:
+				walk(c)
+			}
+		}
+	}
+	for _, u := range d.Units {
+		if u.Root != nil {
+			walk(u.Root)
+		}
+	}
+	return types
+}
+
+/* looks a struct up by tag name or by a typedef naming it */
+func (d *dwarfData) findStruct(name string) *dwarfEntry {
+	name = strings.TrimSpace(name)
+	for _, t := range d.structTypes() {
+		qn := d.qualifiedName(t)
+		if d.entryName(t) == name || qn == name || d.tagPrefix(t)+qn == name {
+			return t
+		}
+	}
+
+	for _, u := range d.Units {
+		if u.Root == nil {
+			continue
+		}
+		for _, c := range u.Root.Children {
+			if c.Tag != dwarf.TagTypedef || d.entryName(c) != name {
+				continue
+			}
+			t := d.stripType(c)
+			if t != nil && (t.Tag == dwarf.TagStructType || t.Tag == dwarf.TagClassType || t.Tag == dwarf.TagUnionType) {
+				return t
+			}
+		}
+	}
+	return nil
+}
+
+func printStructs(elfFs *elfFile, names []string) {
+	d, err := loadDwarf(elfFs)
+	checkError(err)
+	if d == nil {
+		checkError(fmt.Errorf("no DWARF type information found - .debug_info missing from target"))
+	}
+
+	missing := 0
+	for i, name := range names {
+		if i > 0 {
+			fmt.Println()
+		}
+		t := d.findStruct(name)
+		if t == nil {
+			fmt.Printf("struct %s not found\n", name)
+			missing++
+			continue
+		}
+		l := d.layoutStruct(t)
+		if d.entryName(t) == "" {
+			l.Name = d.tagPrefix(t) + name
+		}
+		printStructLayout(d, l)
+	}
+	/* fail the target, so scripts can tell a missing struct from an empty one */
+	if missing > 0 {
+		checkError(fmt.Errorf("%d of %d structs not found", missing, len(names)))
+	}
+}
+
+func printStructHoles(elfFs *elfFile, args []string) {
+	threshold := uint64(1)
+	if len(args) > 0 {
+		n, err := strconv.ParseUint(args[0], 0, 64)
+		if err != nil {
+			fmt.Printf("Invalid hole threshold %s\n", args[0])
+			return
+		}
+		threshold = n
+	}
+
+	d, err := loadDwarf(elfFs)
+	checkError(err)
+	if d == nil {
+		fmt.Println("No DWARF type information found - .debug_info missing from target")
+		return
+	}
+
+	var found []*structLayout
+	for _, t := range d.structTypes() {
+		if t.Tag == dwarf.TagUnionType {
+			continue
+		}
+		l := d.layoutStruct(t)
+		if l.Holes > 0 && l.SumHoles >= threshold {
+			found = append(found, l)
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].SumHoles > found[j].SumHoles
+	})
+
+	fmt.Printf("%d structs with at least %d bytes of holes\n", len(found), threshold)
+	fmt.Printf("%-40s %8s %6s %10s %8s\n", "Name", "Size", "Holes", "Sum Holes", "Padding")
+	for _, l := range found {
+		fmt.Printf("%-40s %8d %6d %10d %8d\n", l.Name, l.Size, l.Holes, l.SumHoles, l.Padding)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"debug/elf"
+	"fmt"
+
+	"github.com/sad0p/go-readelf/demangle"
+)
+
+/* st_other bits outside the visibility that some processors give a meaning */
+const (
+	stoPPC64LocalMask    = 0xe0
+	stoPPC64LocalBit     = 5
+	stoAArch64VariantPCS = 0x80
+	stoRISCVVariantCC    = 0x80
+
+	stoMIPSOptional  = 0x04
+	stoMIPSPLT       = 0x08
+	stoMIPSPIC       = 0x20
+	stoMIPSMicroMIPS = 0x80
+	stoMIPS16        = 0xf0
+
+	shnX86_64LCommon  = 0xff02
+	shnMIPSSCommon    = 0xff03
+	shnMIPSSUndefined = 0xff04
+)
+
+// symOtherString decodes the st_other bits above the visibility, as readelf
+// shows them in brackets after it. Bits no processor claims are printed raw.
+func symOtherString(other uint8, machine elf.Machine) string {
+	rest := other &^ 0x3
+	if rest == 0 {
+		return ""
+	}
+
+	switch machine {
+	case elf.EM_PPC64:
+		/* 1 marks a function that does not preserve r2, 7 is reserved */
+		if v := rest >> stoPPC64LocalBit; rest&stoPPC64LocalMask == rest && v <= 6 {
+			if v >= 2 {
+				v = ((1 << v) >> 2) << 2
+			}
+			return fmt.Sprintf("[<localentry>: %d]", v)
+		}
+	case elf.EM_AARCH64:
+		if rest == stoAArch64VariantPCS {
+			return "[VARIANT_PCS]"
+		}
+	case elf.EM_RISCV:
+		if rest == stoRISCVVariantCC {
+			return "[VARIANT_CC]"
+		}
+	case elf.EM_MIPS, elf.EM_MIPS_RS3_LE:
+		switch rest {
+		case stoMIPSOptional:
+			return "[OPTIONAL]"
+		case stoMIPSPLT:
+			return "[MIPS PLT]"
+		case stoMIPSPIC:
+			return "[MIPS PIC]"
+		case stoMIPSMicroMIPS:
+			return "[MICROMIPS]"
+		case stoMIPSMicroMIPS | stoMIPSPIC:
+			return "[MICROMIPS, MIPS PIC]"
+		case stoMIPS16:
+			return "[MIPS16]"
+		}
+	}
+	return fmt.Sprintf("[<other>: %x]", rest)
+}
+
+/* extended section indices of symbols whose st_shndx is SHN_XINDEX */
+func loadSymtabShndx(symtabNdx uint32, elfFs *elfFile) []uint32 {
+	for _, ndx := range getSectionByType(elf.SHT_SYMTAB_SHNDX, elfFs) {
+		if getSectionHeader(ndx, elfFs).Link != symtabNdx {
+			continue
+		}
+		data := getSectionData(ndx, elfFs)
+		shndx := make([]uint32, len(data)/4)
+		for i := range shndx {
+			shndx[i] = elfFs.FileHdr.Endianness.Uint32(data[i*4:])
+		}
+		return shndx
+	}
+	return nil
+}
+
+// symSectionIndex resolves st_shndx to a section number, following
+// .symtab_shndx for SHN_XINDEX. Reserved indices come back unchanged with
+// ok false.
+func symSectionIndex(s elfSymbol, i uint32, xindex []uint32) (uint32, bool) {
+	switch {
+	case elf.SectionIndex(s.Shndx) == elf.SHN_XINDEX:
+		if int(i) < len(xindex) {
+			return xindex[i], true
+		}
+		return uint32(s.Shndx), false
+	case elf.SectionIndex(s.Shndx) == elf.SHN_UNDEF || s.Shndx >= uint16(elf.SHN_LORESERVE):
+		return uint32(s.Shndx), false
+	}
+	return uint32(s.Shndx), true
+}
+
+/* the Ndx column: the section's name, or the name of a reserved index */
+func symSectionString(s elfSymbol, i uint32, xindex []uint32, elfFs *elfFile) string {
+	ndx, ok := symSectionIndex(s, i, xindex)
+	if ok {
+		if ndx < uint32(len(elfFs.ElfSections.SectionName)) {
+			return elfFs.ElfSections.SectionName[ndx]
+		}
+		return fmt.Sprintf("bad section index[%d]", ndx)
+	}
+
+	switch elf.SectionIndex(ndx) {
+	case elf.SHN_UNDEF:
+		return "UND"
+	case elf.SHN_ABS:
+		return "ABS"
+	case elf.SHN_COMMON:
+		return "COM"
+	case elf.SHN_XINDEX:
+		return "XINDEX"
+	}
+	switch m := elfFs.FileHdr.Machine; {
+	case ndx == shnX86_64LCommon && (m == elf.EM_X86_64):
+		return "LARGE_COM"
+	case ndx == shnMIPSSCommon && (m == elf.EM_MIPS || m == elf.EM_MIPS_RS3_LE):
+		return "SCOM"
+	case ndx == shnMIPSSUndefined && (m == elf.EM_MIPS || m == elf.EM_MIPS_RS3_LE):
+		return "SUND"
+	}
+	switch {
+	case ndx >= uint32(elf.SHN_LOPROC) && ndx <= uint32(elf.SHN_HIPROC):
+		return fmt.Sprintf("PRC[0x%04x]", ndx)
+	case ndx >= uint32(elf.SHN_LOOS) && ndx <= uint32(elf.SHN_HIOS):
+		return fmt.Sprintf("OS [0x%04x]", ndx)
+	}
+	return fmt.Sprintf("RSV[0x%04x]", ndx)
+}
+
+/* section symbols carry no name of their own, they are shown under their section's */
+func symDisplayName(s elfSymbol, i uint32, xindex []uint32, elfFs *elfFile) string {
+	if s.Name != "" || elf.ST_TYPE(s.Info) != elf.STT_SECTION {
+		return s.Name
+	}
+	if ndx, ok := symSectionIndex(s, i, xindex); ok && ndx < uint32(len(elfFs.ElfSections.SectionName)) {
+		return elfFs.ElfSections.SectionName[ndx]
+	}
+	return ""
+}
+
+/* set by -C, names are shown in source form wherever a symbol is printed */
+var demangleNames bool
+
+/* GNU readelf and nm demangle without c++filt's verbose expansions */
+func displayName(name string) string {
+	if demangleNames {
+		return demangle.Filter(name, demangle.NoVerbose)
+	}
+	return name
+}
+
+/* lists one loaded symbol table, from says where a .symtab came from when it is not the target itself */
+func printSymbolTable(elfFs *elfFile, tableNdx uint32, symType int, from string, flt *symbolFilter) {
+	n := len(elfFs.Symbols)
+	if symType == dynSym {
+		n = len(elfFs.DynSymbols)
+	}
+	width := 16
+	if elfFs.FileHdr.Arch == elf.ELFCLASS32 {
+		width = 8
+	}
+	xindex := loadSymtabShndx(tableNdx, elfFs)
+
+	var list []uint32
+	for i := uint32(0); i < uint32(n); i++ {
+		s, ok := getSymbol(i, symType, elfFs)
+		if ok && flt.match(s, symDisplayName(s, i, xindex, elfFs), symSectionString(s, i, xindex, elfFs)) {
+			list = append(list, i)
+		}
+	}
+	flt.order(list, symType, elfFs)
+
+	if flt.active() {
+		fmt.Printf("\nSymbol table '%s'%s contains %d entries, %d matching:\n", elfFs.ElfSections.SectionName[tableNdx], from, n, len(list))
+	} else {
+		fmt.Printf("\nSymbol table '%s'%s contains %d entries:\n", elfFs.ElfSections.SectionName[tableNdx], from, n)
+	}
+	fmt.Printf("   Num: %-*s %5s %-14s%-15s%-13s %-18s %s\n", width, "Value", "Size", "Type", "Bind", "Vis", "Ndx", "Name")
+	for _, i := range list {
+		s, _ := getSymbol(i, symType, elfFs)
+		vis := elf.ST_VISIBILITY(s.Other).String()
+		if other := symOtherString(s.Other, elfFs.FileHdr.Machine); other != "" {
+			vis += " " + other
+		}
+		fmt.Printf("%6d: %0*x %5d %-14s%-15s%-13s %-18s %s\n", i, width, s.Value, s.Size,
+			symTypeString(elf.ST_TYPE(s.Info)), symBindString(elf.ST_BIND(s.Info)), vis,
+			symSectionString(s, i, xindex, elfFs), displayName(symDisplayName(s, i, xindex, elfFs)))
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"debug/elf"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/* which symbols -s lists and in what order, every set criterion has to match */
+type symbolFilter struct {
+	Globs     []string
+	Regex     *regexp.Regexp
+	Types     []elf.SymType
+	Binds     []elf.SymBind
+	Vis       []elf.SymVis
+	Sections  []string
+	Defined   bool
+	Undefined bool
+	MinSize   uint64
+	MaxSize   uint64 // 0 for no limit
+	Sort      string // "", "addr", "size" or "name"
+	Reverse   bool
+}
+
+var symTypeNames = map[string]elf.SymType{
+	"NOTYPE": elf.STT_NOTYPE, "OBJECT": elf.STT_OBJECT, "FUNC": elf.STT_FUNC, "SECTION": elf.STT_SECTION,
+	"FILE": elf.STT_FILE, "COMMON": elf.STT_COMMON, "TLS": elf.STT_TLS, "IFUNC": sttGNUIFunc, "GNU_IFUNC": sttGNUIFunc,
+}
+
+var symBindNames = map[string]elf.SymBind{
+	"LOCAL": elf.STB_LOCAL, "GLOBAL": elf.STB_GLOBAL, "WEAK": elf.STB_WEAK, "UNIQUE": stbGNUUnique, "GNU_UNIQUE": stbGNUUnique,
+}
+
+var symVisNames = map[string]elf.SymVis{
+	"DEFAULT": elf.STV_DEFAULT, "INTERNAL": elf.STV_INTERNAL, "HIDDEN": elf.STV_HIDDEN, "PROTECTED": elf.STV_PROTECTED,
+}
+
+/* comma separated names, with or without their STT_/STB_/STV_ prefix */
+func parseSymNames[T any](list, prefix string, names map[string]T) ([]T, error) {
+	var out []T
+	for _, n := range strings.Split(list, ",") {
+		v, ok := names[strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(n)), prefix)]
+		if !ok {
+			return nil, fmt.Errorf("unknown %s name %q", strings.TrimSuffix(prefix, "_"), n)
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+/* decimal, 0x hex, with an optional K or M suffix */
+func parseSize(s string) (uint64, error) {
+	mult := uint64(1)
+	switch {
+	case strings.HasSuffix(s, "K") || strings.HasSuffix(s, "k"):
+		mult, s = 1024, s[:len(s)-1]
+	case strings.HasSuffix(s, "M") || strings.HasSuffix(s, "m"):
+		mult, s = 1024*1024, s[:len(s)-1]
+	}
+	n, err := strconv.ParseUint(s, 0, 64)
+	return n * mult, err
+}
+
+/* applies one --option=value of the symbol view, reporting whether opt is one of them */
+func (flt *symbolFilter) set(opt, val string) (bool, error) {
+	var err error
+	switch opt {
+	case "--sym-name":
+		if _, err = path.Match(val, ""); err == nil {
+			flt.Globs = append(flt.Globs, val)
+		}
+	case "--sym-regex":
+		flt.Regex, err = regexp.Compile(val)
+	case "--sym-type":
+		flt.Types, err = parseSymNames(val, "STT_", symTypeNames)
+	case "--sym-bind":
+		flt.Binds, err = parseSymNames(val, "STB_", symBindNames)
+	case "--sym-vis":
+		flt.Vis, err = parseSymNames(val, "STV_", symVisNames)
+	case "--sym-section":
+		flt.Sections = append(flt.Sections, strings.Split(val, ",")...)
+	case "--size-min":
+		flt.MinSize, err = parseSize(val)
+	case "--size-max":
+		flt.MaxSize, err = parseSize(val)
+	case "--sort":
+		switch val {
+		case "addr", "size", "name":
+			flt.Sort = val
+		default:
+			err = fmt.Errorf("sort key must be addr, size or name")
+		}
+	default:
+		return false, nil
+	}
+	return true, err
+}
+
+func (flt *symbolFilter) active() bool {
+	return len(flt.Globs) > 0 || flt.Regex != nil || len(flt.Types) > 0 || len(flt.Binds) > 0 || len(flt.Vis) > 0 ||
+		len(flt.Sections) > 0 || flt.Defined || flt.Undefined || flt.MinSize > 0 || flt.MaxSize > 0
+}
+
+func contains[T comparable](list []T, v T) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func (flt *symbolFilter) match(s elfSymbol, name, section string) bool {
+	if len(flt.Globs) > 0 {
+		hit := false
+		for _, g := range flt.Globs {
+			if ok, _ := path.Match(g, name); ok {
+				hit = true
+				break
+			}
+		}
+		if !hit {
+			return false
+		}
+	}
+	if flt.Regex != nil && !flt.Regex.MatchString(name) {
+		return false
+	}
+	if len(flt.Types) > 0 && !contains(flt.Types, elf.ST_TYPE(s.Info)) ||
+		len(flt.Binds) > 0 && !contains(flt.Binds, elf.ST_BIND(s.Info)) ||
+		len(flt.Vis) > 0 && !contains(flt.Vis, elf.ST_VISIBILITY(s.Other)) ||
+		len(flt.Sections) > 0 && !contains(flt.Sections, section) {
+		return false
+	}
+
+	undef := elf.SectionIndex(s.Shndx) == elf.SHN_UNDEF
+	if flt.Defined && undef || flt.Undefined && !undef {
+		return false
+	}
+	return s.Size >= flt.MinSize && (flt.MaxSize == 0 || s.Size <= flt.MaxSize)
+}
+
+/* reorders the listed symbol indices, ties keep table order */
+func (flt *symbolFilter) order(ndx []uint32, symType int, elfFs *elfFile) {
+	if flt.Sort == "" {
+		if flt.Reverse {
+			sort.SliceStable(ndx, func(a, b int) bool { return ndx[a] > ndx[b] })
+		}
+		return
+	}
+
+	key := make(map[uint32]elfSymbol, len(ndx))
+	for _, i := range ndx {
+		key[i], _ = getSymbol(i, symType, elfFs)
+	}
+	less := func(a, b elfSymbol) bool {
+		switch flt.Sort {
+		case "addr":
+			return a.Value < b.Value
+		case "size":
+			return a.Size < b.Size
+		}
+		return a.Name < b.Name
+	}
+	sort.SliceStable(ndx, func(a, b int) bool {
+		if flt.Reverse {
+			return less(key[ndx[b]], key[ndx[a]])
+		}
+		return less(key[ndx[a]], key[ndx[b]])
+	})
+}
+
+/* a symbol found by --symbol-at, Table is the table it came from */
+type symbolHit struct {
+	Symbol elfSymbol
+	Index  uint32
+	Table  string
+	Offset uint64
+}
+
+// symbolsAt finds the symbols defined in a section whose [value, value+size)
+// covers addr, zero sized ones only when addr is their value. Functions and objects are
+// preferred over untyped labels and the closest start wins, aliases at the
+// same address are all returned.
+func symbolsAt(elfFs *elfFile, addr uint64) []symbolHit {
+	var hits []symbolHit
+	rank := func(s elfSymbol) int {
+		switch elf.ST_TYPE(s.Info) {
+		case elf.STT_FUNC, elf.STT_OBJECT, sttGNUIFunc, elf.STT_TLS:
+			return 2
+		case elf.STT_NOTYPE:
+			return 1
+		}
+		return 0
+	}
+
+	scan := func(symType int, n int, table string) {
+		for i := uint32(1); i < uint32(n); i++ {
+			s, ok := getSymbol(i, symType, elfFs)
+			if !ok || s.Name == "" || rank(s) == 0 || elf.SectionIndex(s.Shndx) == elf.SHN_UNDEF {
+				continue
+			}
+			/* absolute values, commons and the other reserved indices are no addresses, SHN_XINDEX only moves the index */
+			if ndx := elf.SectionIndex(s.Shndx); ndx >= elf.SHN_LORESERVE && ndx != elf.SHN_XINDEX {
+				continue
+			}
+			if addr < s.Value || addr >= s.Value+s.Size && addr != s.Value {
+				continue
+			}
+			h := symbolHit{s, i, table, addr - s.Value}
+			if len(hits) > 0 {
+				best := hits[0].Symbol
+				switch {
+				case rank(s) < rank(best) || rank(s) == rank(best) && s.Value < best.Value:
+					continue
+				case rank(s) > rank(best) || s.Value > best.Value:
+					hits = hits[:0]
+				}
+				/* the same definition exported in both tables is listed once */
+				for _, o := range hits {
+					if o.Symbol.Name == s.Name && o.Symbol.Value == s.Value {
+						ok = false
+					}
+				}
+				if !ok {
+					continue
+				}
+			}
+			hits = append(hits, h)
+		}
+	}
+	scan(sym, len(elfFs.Symbols), ".symtab")
+	scan(dynSym, len(elfFs.DynSymbols), ".dynsym")
+	return hits
+}
+
+func printSymbolAt(elfFs *elfFile, addrs []string) {
+	elfFs.loadAllSymbols()
+
+	missing := 0
+	for _, a := range addrs {
+		addr, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(a), "0x"), 16, 64)
+		if err != nil {
+			fmt.Printf("Invalid address %s\n", a)
+			missing++
+			continue
+		}
+		hits := symbolsAt(elfFs, addr)
+		if len(hits) == 0 {
+			fmt.Printf("0x%x: no symbol covers this address\n", addr)
+			missing++
+			continue
+		}
+		for _, h := range hits {
+			s := h.Symbol
+			fmt.Printf("0x%x: %s+0x%x (%s %s, value 0x%x, size %d, section %s, %s[%d])\n", addr, displayName(s.Name), h.Offset,
+				symTypeString(elf.ST_TYPE(s.Info)), symBindString(elf.ST_BIND(s.Info)), s.Value, s.Size,
+				symSectionString(s, h.Index, nil, elfFs), h.Table, h.Index)
+		}
+	}
+	/* the other addresses are still shown, but the target fails like --struct does */
+	if missing > 0 {
+		checkError(fmt.Errorf("%d of %d addresses not resolved", missing, len(addrs)))
+	}
+}
@@ -0,0 +1,151 @@
+package symlookup
+
+import (
+	"debug/elf"
+	"encoding/binary"
+)
+
+// ElfHash is the SysV ELF hash .hash is keyed on.
+func ElfHash(name string) uint32 {
+	var h uint32
+	for i := 0; i < len(name); i++ {
+		h = h<<4 + uint32(name[i])
+		if g := h & 0xf0000000; g != 0 {
+			h ^= g >> 24
+		}
+		h &^= 0xf0000000
+	}
+	return h
+}
+
+// GnuHash is the DJB hash .gnu.hash is keyed on.
+func GnuHash(name string) uint32 {
+	h := uint32(5381)
+	for i := 0; i < len(name); i++ {
+		h = h*33 + uint32(name[i])
+	}
+	return h
+}
+
+type sysvHash struct {
+	nbucket uint32
+	nchain  uint32
+	buckets []uint32
+	chains  []uint32
+}
+
+type gnuHash struct {
+	nbucket    uint32
+	symoffset  uint32
+	bloomShift uint32
+	wordBits   uint32
+	bloom      []uint64
+	buckets    []uint32
+	chain      []uint32 // hash values of symbols from symoffset on, low bit ends a bucket's chain
+}
+
+/* .hash entries are 32 bit words except on 64 bit s390 and alpha */
+func parseSysvHash(data []byte, f *elf.File) *sysvHash {
+	es := 4
+	if f.Class == elf.ELFCLASS64 && (f.Machine == elf.EM_S390 || f.Machine == elf.EM_ALPHA) {
+		es = 8
+	}
+	word := func(i int) uint32 {
+		if es == 8 {
+			return uint32(f.ByteOrder.Uint64(data[i*8:]))
+		}
+		return f.ByteOrder.Uint32(data[i*4:])
+	}
+	if len(data) < 2*es {
+		return nil
+	}
+
+	h := &sysvHash{nbucket: word(0), nchain: word(1)}
+	if uint64(2+h.nbucket+h.nchain)*uint64(es) > uint64(len(data)) {
+		return nil
+	}
+	for i := 0; i < int(h.nbucket); i++ {
+		h.buckets = append(h.buckets, word(2+i))
+	}
+	for i := 0; i < int(h.nchain); i++ {
+		h.chains = append(h.chains, word(2+int(h.nbucket)+i))
+	}
+	return h
+}
+
+func parseGnuHash(data []byte, class elf.Class, order binary.ByteOrder) *gnuHash {
+	if len(data) < 16 {
+		return nil
+	}
+	h := &gnuHash{
+		nbucket:    order.Uint32(data),
+		symoffset:  order.Uint32(data[4:]),
+		bloomShift: order.Uint32(data[12:]),
+		wordBits:   64,
+	}
+	bloomSize := order.Uint32(data[8:])
+	if class == elf.ELFCLASS32 {
+		h.wordBits = 32
+	}
+	bloomWord := int(h.wordBits / 8)
+	off := 16
+	if uint64(off)+uint64(bloomSize)*uint64(bloomWord)+uint64(h.nbucket)*4 > uint64(len(data)) {
+		return nil
+	}
+	for i := uint32(0); i < bloomSize; i++ {
+		if bloomWord == 4 {
+			h.bloom = append(h.bloom, uint64(order.Uint32(data[off:])))
+		} else {
+			h.bloom = append(h.bloom, order.Uint64(data[off:]))
+		}
+		off += bloomWord
+	}
+	for i := uint32(0); i < h.nbucket; i++ {
+		h.buckets = append(h.buckets, order.Uint32(data[off:]))
+		off += 4
+	}
+	for ; off+4 <= len(data); off += 4 {
+		h.chain = append(h.chain, order.Uint32(data[off:]))
+	}
+	return h
+}
+
+/* the bloom word a hash selects */
+func (h *gnuHash) bloomWord(hash uint32) uint32 {
+	return (hash / h.wordBits) % uint32(max(len(h.bloom), 1))
+}
+
+/* the two bits a name sets in the bloom filter, both must be present for a lookup to proceed */
+func (h *gnuHash) bloomHit(hash uint32) bool {
+	if len(h.bloom) == 0 {
+		return false
+	}
+	w := h.bloom[h.bloomWord(hash)]
+	mask := uint64(1)<<(hash%h.wordBits) | uint64(1)<<((hash>>h.bloomShift)%h.wordBits)
+	return w&mask == mask
+}
+
+/* symbol indexes in the chain of bucket b, stops at the end marker or the end of the section */
+func (h *gnuHash) bucketChain(b uint32) []uint32 {
+	var syms []uint32
+	i := h.buckets[b]
+	if i == 0 || i < h.symoffset {
+		return nil
+	}
+	for ; int(i-h.symoffset) < len(h.chain); i++ {
+		syms = append(syms, i)
+		if h.chain[i-h.symoffset]&1 != 0 {
+			break
+		}
+	}
+	return syms
+}
+
+/* walks a .hash chain, the step limit guards against cycles in corrupt tables */
+func (h *sysvHash) bucketChain(b uint32) []uint32 {
+	var syms []uint32
+	for i := h.buckets[b]; i != 0 && i < h.nchain && len(syms) <= int(h.nchain); i = h.chains[i] {
+		syms = append(syms, i)
+	}
+	return syms
+}
@@ -0,0 +1,331 @@
+// Package symlookup resolves dynamic symbols the way glibc's ld.so does:
+// through .gnu.hash when present, else .hash, else a linear scan of
+// .dynsym, with the version checks of its check_match. A definition the
+// preferred table cannot reach is not found, just as at run time, which is
+// what "symbol lookup error" reports come down to.
+package symlookup
+
+import (
+	"debug/elf"
+	"errors"
+	"fmt"
+)
+
+/* GNU symbol binding and type, missing from debug/elf before go1.23 */
+const (
+	stbGNUUnique elf.SymBind = 10
+	sttGNUIFunc  elf.SymType = 10
+)
+
+const (
+	versymHidden = 0x8000 // the definition is not the default one (name@VER rather than name@@VER)
+	versymGlobal = 1
+)
+
+// ErrNoDynsym is returned by Load for files without a dynamic symbol table.
+var ErrNoDynsym = errors.New("symlookup: no .dynsym")
+
+// Table is the dynamic symbol table of one file with what a lookup in it
+// needs: the hash tables and the symbol versions.
+type Table struct {
+	// Symbols is .dynsym in table order, Symbols[0] being the null symbol.
+	Symbols []elf.Symbol
+
+	versym []uint16 // parallel to Symbols, empty for unversioned files
+	names  map[uint16]string
+	hashes map[uint16]uint32
+	gnu    *gnuHash
+	sysv   *sysvHash
+}
+
+// Match is a definition a lookup found. Path names the way it was reached:
+// ".gnu.hash", ".hash" or "linear scan".
+type Match struct {
+	Index   uint32
+	Symbol  elf.Symbol
+	Version string
+	Hidden  bool // a non-default version, name@VER rather than name@@VER
+	Path    string
+}
+
+// Load reads the dynamic symbols of f, its .gnu.hash and .hash when they
+// index them, and .gnu.version with the version names .gnu.version_d and
+// .gnu.version_r give. Truncated hash tables are left out.
+func Load(f *elf.File) (*Table, error) {
+	syms, err := f.DynamicSymbols()
+	if err != nil {
+		if errors.Is(err, elf.ErrNoSymbols) {
+			return nil, ErrNoDynsym
+		}
+		return nil, err
+	}
+	t := &Table{Symbols: append([]elf.Symbol{{}}, syms...), names: map[uint16]string{}, hashes: map[uint16]uint32{}}
+
+	dynsym := -1
+	for i, s := range f.Sections {
+		if s.Type == elf.SHT_DYNSYM {
+			dynsym = i
+			break
+		}
+	}
+	for _, s := range f.Sections {
+		if s.Type != elf.SHT_GNU_VERSYM && s.Type != elf.SHT_GNU_VERDEF && s.Type != elf.SHT_GNU_VERNEED &&
+			(s.Type != elf.SHT_GNU_HASH && s.Type != elf.SHT_HASH || int(s.Link) != dynsym) {
+			continue
+		}
+		data, err := s.Data()
+		if err != nil {
+			return nil, fmt.Errorf("symlookup: %s: %v", s.Name, err)
+		}
+		switch s.Type {
+		case elf.SHT_GNU_HASH:
+			if t.gnu == nil {
+				t.gnu = parseGnuHash(data, f.Class, f.ByteOrder)
+			}
+		case elf.SHT_HASH:
+			if t.sysv == nil {
+				t.sysv = parseSysvHash(data, f)
+			}
+		case elf.SHT_GNU_VERSYM:
+			for i := 0; i+2 <= len(data); i += 2 {
+				t.versym = append(t.versym, f.ByteOrder.Uint16(data[i:]))
+			}
+		default:
+			strtab, err := linkedData(f, s)
+			if err != nil {
+				return nil, err
+			}
+			if s.Type == elf.SHT_GNU_VERDEF {
+				t.parseVerdefs(data, strtab, f)
+			} else {
+				t.parseVerneeds(data, strtab, f)
+			}
+		}
+	}
+	return t, nil
+}
+
+func linkedData(f *elf.File, s *elf.Section) ([]byte, error) {
+	if int(s.Link) >= len(f.Sections) {
+		return nil, fmt.Errorf("symlookup: %s links to missing section %d", s.Name, s.Link)
+	}
+	data, err := f.Sections[s.Link].Data()
+	if err != nil {
+		return nil, fmt.Errorf("symlookup: %s: %v", f.Sections[s.Link].Name, err)
+	}
+	return data, nil
+}
+
+func cstring(strtab []byte, off uint32) string {
+	if int(off) >= len(strtab) {
+		return ""
+	}
+	end := int(off)
+	for end < len(strtab) && strtab[end] != 0 {
+		end++
+	}
+	return string(strtab[off:end])
+}
+
+/* the first name of each version definition, the one symbols refer to by index */
+func (t *Table) parseVerdefs(data, strtab []byte, f *elf.File) {
+	order := f.ByteOrder
+	off := uint64(0)
+	for i := 0; off+20 <= uint64(len(data)) && i < 0x10000; i++ {
+		ndx := order.Uint16(data[off+4:])
+		aux := off + uint64(order.Uint32(data[off+12:]))
+		if order.Uint16(data[off+6:]) > 0 && aux+8 <= uint64(len(data)) {
+			t.names[ndx] = cstring(strtab, order.Uint32(data[aux:]))
+			t.hashes[ndx] = order.Uint32(data[off+8:])
+		}
+		next := uint64(order.Uint32(data[off+16:]))
+		if next == 0 {
+			break
+		}
+		off += next
+	}
+}
+
+func (t *Table) parseVerneeds(data, strtab []byte, f *elf.File) {
+	order := f.ByteOrder
+	off := uint64(0)
+	for i := 0; off+16 <= uint64(len(data)) && i < 0x10000; i++ {
+		cnt := order.Uint16(data[off+2:])
+		aux := off + uint64(order.Uint32(data[off+8:]))
+		for j := uint16(0); j < cnt && aux+16 <= uint64(len(data)); j++ {
+			other := order.Uint16(data[aux+6:])
+			t.names[other] = cstring(strtab, order.Uint32(data[aux+8:]))
+			t.hashes[other] = order.Uint32(data[aux:])
+			next := uint64(order.Uint32(data[aux+12:]))
+			if next == 0 {
+				break
+			}
+			aux += next
+		}
+		next := uint64(order.Uint32(data[off+12:]))
+		if next == 0 {
+			break
+		}
+		off += next
+	}
+}
+
+/* version index of symbol i, with the hidden bit */
+func (t *Table) symVersion(i uint32) (uint16, bool) {
+	if int(i) >= len(t.versym) {
+		return versymGlobal, false
+	}
+	return t.versym[i], true
+}
+
+// VersionName returns the version symbol i is defined or needed at, and
+// whether that is a non-default (hidden) one. Unversioned symbols and the
+// base and global indices give an empty name.
+func (t *Table) VersionName(i uint32) (string, bool) {
+	vs, _ := t.symVersion(i)
+	return t.names[vs&^versymHidden], vs&versymHidden != 0
+}
+
+// Bindable reports whether ld.so binds references to symbols of type st.
+func Bindable(st elf.SymType) bool {
+	switch st {
+	case elf.STT_NOTYPE, elf.STT_OBJECT, elf.STT_FUNC, elf.STT_COMMON, elf.STT_TLS, sttGNUIFunc:
+		return true
+	}
+	return false
+}
+
+// Fallback is the state ld.so carries across an unversioned lookup: the
+// default versioned definitions seen, one of which it takes when no plain
+// definition turns up.
+type Fallback struct {
+	index uint32
+	count int
+}
+
+// Index returns the default versioned definition to fall back on, when
+// exactly one was seen.
+func (fb *Fallback) Index() (uint32, bool) {
+	return fb.index, fb.count == 1
+}
+
+// CheckMatch follows glibc's check_match for symbol i: undefined and
+// valueless symbols never match, a requested version must name the
+// symbol's version (a plain global only passes for unversioned files), and
+// an unversioned request takes base or global definitions directly and
+// otherwise records default versions in fb.
+func (t *Table) CheckMatch(i uint32, name, version string, fb *Fallback) bool {
+	if int(i) >= len(t.Symbols) || i == 0 {
+		return false
+	}
+	s := t.Symbols[i]
+	st := elf.ST_TYPE(s.Info)
+	if s.Value == 0 && s.Section != elf.SHN_ABS && st != elf.STT_TLS || s.Section == elf.SHN_UNDEF {
+		return false
+	}
+	if !Bindable(st) || s.Name != name {
+		return false
+	}
+	switch elf.ST_BIND(s.Info) {
+	case elf.STB_GLOBAL, elf.STB_WEAK, stbGNUUnique:
+	default:
+		return false
+	}
+
+	vs, versioned := t.symVersion(i)
+	ndx := vs &^ versymHidden
+	if version != "" {
+		if !versioned {
+			return true
+		}
+		if (t.hashes[ndx] != ElfHash(version) || t.names[ndx] != version) && (t.hashes[ndx] != 0 || vs&versymHidden != 0) {
+			return false
+		}
+		return true
+	}
+
+	if versioned && ndx >= 3 {
+		if vs&versymHidden == 0 {
+			if fb.count == 0 {
+				fb.index = i
+			}
+			fb.count++
+		}
+		return false
+	}
+	return true
+}
+
+// MatchAt describes symbol i as a Match reached through path.
+func (t *Table) MatchAt(i uint32, path string) Match {
+	m := Match{Index: i, Path: path}
+	if int(i) < len(t.Symbols) {
+		m.Symbol = t.Symbols[i]
+	}
+	if _, ok := t.symVersion(i); ok {
+		m.Version, m.Hidden = t.VersionName(i)
+	}
+	return m
+}
+
+// Lookup resolves name, optionally at version, as ld.so resolves it in this
+// one file.
+func (t *Table) Lookup(name, version string) (Match, bool) {
+	return t.Trace(name, version, func(string) {})
+}
+
+// Trace is Lookup reporting each step through trace: the hash and bucket
+// used, a bloom filter rejection, the version fallback.
+func (t *Table) Trace(name, version string, trace func(string)) (Match, bool) {
+	var fb Fallback
+	finish := func(path string) (Match, bool) {
+		if i, ok := fb.Index(); ok {
+			trace(fmt.Sprintf("%s: no unversioned definition, using the only default version", path))
+			return t.MatchAt(i, path), true
+		}
+		if fb.count > 1 {
+			trace(fmt.Sprintf("%s: %d default versioned definitions, an unversioned lookup is ambiguous", path, fb.count))
+		}
+		return Match{}, false
+	}
+
+	if h := t.gnu; h != nil {
+		hash := GnuHash(name)
+		trace(fmt.Sprintf(".gnu.hash: hash 0x%08x, bloom word %d, bucket %d", hash, h.bloomWord(hash), hash%max(h.nbucket, 1)))
+		if h.nbucket == 0 || !h.bloomHit(hash) {
+			trace(".gnu.hash: rejected by the bloom filter")
+			return Match{}, false
+		}
+		for _, i := range h.bucketChain(hash % h.nbucket) {
+			if (h.chain[i-h.symoffset]^hash)>>1 != 0 {
+				continue
+			}
+			if t.CheckMatch(i, name, version, &fb) {
+				return t.MatchAt(i, ".gnu.hash"), true
+			}
+		}
+		return finish(".gnu.hash")
+	}
+
+	if h := t.sysv; h != nil {
+		hash := ElfHash(name)
+		trace(fmt.Sprintf(".hash: hash 0x%08x, bucket %d", hash, hash%max(h.nbucket, 1)))
+		if h.nbucket == 0 {
+			return Match{}, false
+		}
+		for _, i := range h.bucketChain(hash % h.nbucket) {
+			if t.CheckMatch(i, name, version, &fb) {
+				return t.MatchAt(i, ".hash"), true
+			}
+		}
+		return finish(".hash")
+	}
+
+	trace("no hash table, scanning .dynsym")
+	for i := uint32(1); int(i) < len(t.Symbols); i++ {
+		if t.CheckMatch(i, name, version, &fb) {
+			return t.MatchAt(i, "linear scan"), true
+		}
+	}
+	return finish("linear scan")
+}
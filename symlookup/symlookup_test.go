@@ -0,0 +1,54 @@
+package symlookup
+
+import (
+	"debug/elf"
+	"testing"
+)
+
+func TestHashes(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		elf, gnu uint32
+	}{
+		{"", 0, 0x1505},
+		{"printf", 0x077905a6, 0x156b2bb8},
+	} {
+		if h := ElfHash(tt.name); h != tt.elf {
+			t.Errorf("ElfHash(%q) = 0x%08x, want 0x%08x", tt.name, h, tt.elf)
+		}
+		if h := GnuHash(tt.name); h != tt.gnu {
+			t.Errorf("GnuHash(%q) = 0x%08x, want 0x%08x", tt.name, h, tt.gnu)
+		}
+	}
+}
+
+func TestLookupVersions(t *testing.T) {
+	def := func(name string) elf.Symbol {
+		return elf.Symbol{Name: name, Info: elf.ST_INFO(elf.STB_GLOBAL, elf.STT_FUNC), Section: 12, Value: 0x1000}
+	}
+	tab := &Table{
+		/* foo@@V2, foo@V1, an unversioned bar and an undefined baz, versions from 3 as ld.so takes 2 for unversioned */
+		Symbols: []elf.Symbol{{}, def("foo"), def("foo"), def("bar"), {Name: "baz", Info: elf.ST_INFO(elf.STB_GLOBAL, elf.STT_FUNC)}},
+		versym:  []uint16{0, 4, 3 | versymHidden, versymGlobal, 0},
+		names:   map[uint16]string{3: "V1", 4: "V2"},
+		hashes:  map[uint16]uint32{3: ElfHash("V1"), 4: ElfHash("V2")},
+	}
+
+	for _, tt := range []struct {
+		name, version string
+		index         uint32
+		hidden        bool
+	}{
+		{"foo", "", 1, false}, // the only default version
+		{"foo", "V2", 1, false},
+		{"foo", "V1", 2, true},
+		{"bar", "", 3, false},
+		{"foo", "V3", 0, false},
+		{"baz", "", 0, false},
+	} {
+		m, ok := tab.Lookup(tt.name, tt.version)
+		if ok != (tt.index != 0) || ok && (m.Index != tt.index || m.Hidden != tt.hidden || m.Path != "linear scan") {
+			t.Errorf("Lookup(%q, %q) = %+v, %t, want index %d hidden %t", tt.name, tt.version, m, ok, tt.index, tt.hidden)
+		}
+	}
+}
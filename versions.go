@@ -0,0 +1,224 @@
+package main
+
+import (
+	"debug/elf"
+	"strconv"
+	"strings"
+)
+
+// Symbol versioning, the GNU extension the dynamic loader uses to pick
+// between several definitions of the same name.
+
+const (
+	verFlgBase = 0x1
+	verFlgWeak = 0x2
+
+	versymHidden = 0x8000 // the definition is not the default one (name@VER rather than name@@VER)
+	versymLocal  = 0
+	versymGlobal = 1
+)
+
+type verdefEntry struct {
+	Ndx   uint16
+	Flags uint16
+	Hash  uint32
+	Names []string // the version first, then the versions it inherits from
+}
+
+type vernauxEntry struct {
+	Hash  uint32
+	Flags uint16
+	Other uint16 // the versym index symbols use to refer to this version
+	Name  string
+}
+
+type verneedEntry struct {
+	File string
+	Aux  []vernauxEntry
+}
+
+type symVersions struct {
+	Versym   []uint16 // parallel to .dynsym
+	Verdefs  []verdefEntry
+	Verneeds []verneedEntry
+	Names    map[uint16]string // versym index to version name
+	Hashes   map[uint16]uint32
+}
+
+/* string table a version section names through sh_link */
+func versionStrtab(ndx uint32, elfFs *elfFile) []byte {
+	return getSectionData(getSectionHeader(ndx, elfFs).Link, elfFs)
+}
+
+func parseVerdefs(ndx uint32, elfFs *elfFile) []verdefEntry {
+	data := getSectionData(ndx, elfFs)
+	strtab := versionStrtab(ndx, elfFs)
+	order := elfFs.FileHdr.Endianness
+
+	var defs []verdefEntry
+	off := uint64(0)
+	for i := 0; off+20 <= uint64(len(data)) && i < 0x10000; i++ {
+		d := verdefEntry{
+			Flags: order.Uint16(data[off+2:]),
+			Ndx:   order.Uint16(data[off+4:]),
+			Hash:  order.Uint32(data[off+8:]),
+		}
+		cnt := order.Uint16(data[off+6:])
+		aux := off + uint64(order.Uint32(data[off+12:]))
+		for j := uint16(0); j < cnt && aux+8 <= uint64(len(data)); j++ {
+			d.Names = append(d.Names, getSymbolName(order.Uint32(data[aux:]), strtab))
+			next := uint64(order.Uint32(data[aux+4:]))
+			if next == 0 {
+				break
+			}
+			aux += next
+		}
+		defs = append(defs, d)
+
+		next := uint64(order.Uint32(data[off+16:]))
+		if next == 0 {
+			break
+		}
+		off += next
+	}
+	return defs
+}
+
+func parseVerneeds(ndx uint32, elfFs *elfFile) []verneedEntry {
+	data := getSectionData(ndx, elfFs)
+	strtab := versionStrtab(ndx, elfFs)
+	order := elfFs.FileHdr.Endianness
+
+	var needs []verneedEntry
+	off := uint64(0)
+	for i := 0; off+16 <= uint64(len(data)) && i < 0x10000; i++ {
+		n := verneedEntry{File: getSymbolName(order.Uint32(data[off+4:]), strtab)}
+		cnt := order.Uint16(data[off+2:])
+		aux := off + uint64(order.Uint32(data[off+8:]))
+		for j := uint16(0); j < cnt && aux+16 <= uint64(len(data)); j++ {
+			n.Aux = append(n.Aux, vernauxEntry{
+				Hash:  order.Uint32(data[aux:]),
+				Flags: order.Uint16(data[aux+4:]),
+				Other: order.Uint16(data[aux+6:]),
+				Name:  getSymbolName(order.Uint32(data[aux+8:]), strtab),
+			})
+			next := uint64(order.Uint32(data[aux+12:]))
+			if next == 0 {
+				break
+			}
+			aux += next
+		}
+		needs = append(needs, n)
+
+		next := uint64(order.Uint32(data[off+12:]))
+		if next == 0 {
+			break
+		}
+		off += next
+	}
+	return needs
+}
+
+/* collects .gnu.version, .gnu.version_d and .gnu.version_r, nil when the file is unversioned */
+func loadSymVersions(elfFs *elfFile) *symVersions {
+	v := &symVersions{Names: make(map[uint16]string), Hashes: make(map[uint16]uint32)}
+	found := false
+
+	for _, ndx := range getSectionByType(elf.SHT_GNU_VERSYM, elfFs) {
+		data := getSectionData(ndx, elfFs)
+		for i := 0; i+2 <= len(data); i += 2 {
+			v.Versym = append(v.Versym, elfFs.FileHdr.Endianness.Uint16(data[i:]))
+		}
+		found = true
+	}
+	for _, ndx := range getSectionByType(elf.SHT_GNU_VERDEF, elfFs) {
+		v.Verdefs = append(v.Verdefs, parseVerdefs(ndx, elfFs)...)
+		found = true
+	}
+	for _, ndx := range getSectionByType(elf.SHT_GNU_VERNEED, elfFs) {
+		v.Verneeds = append(v.Verneeds, parseVerneeds(ndx, elfFs)...)
+		found = true
+	}
+	if !found {
+		return nil
+	}
+
+	for _, d := range v.Verdefs {
+		if len(d.Names) > 0 {
+			v.Names[d.Ndx] = d.Names[0]
+			v.Hashes[d.Ndx] = d.Hash
+		}
+	}
+	for _, n := range v.Verneeds {
+		for _, a := range n.Aux {
+			v.Names[a.Other] = a.Name
+			v.Hashes[a.Other] = a.Hash
+		}
+	}
+	return v
+}
+
+/* version index of dynamic symbol i, with the hidden bit */
+func (v *symVersions) symVersion(i uint32) (uint16, bool) {
+	if v == nil || int(i) >= len(v.Versym) {
+		return versymGlobal, false
+	}
+	return v.Versym[i], true
+}
+
+/* whether versym index ndx is one of the versions this file defines, rather than one it needs */
+func (v *symVersions) isDefined(ndx uint16) bool {
+	for _, d := range v.Verdefs {
+		if d.Ndx == ndx {
+			return true
+		}
+	}
+	return false
+}
+
+// versionedName appends the version of dynamic symbol i to shown, its name
+// as it is to be printed: @@ when this file defines the default version,
+// @ for hidden and needed ones. Symbols naming a version definition carry
+// no suffix.
+func (v *symVersions) versionedName(i uint32, name, shown string) string {
+	vs, found := v.symVersion(i)
+	if !found || vs&^versymHidden <= versymGlobal || v.Names[vs&^versymHidden] == name {
+		return shown
+	}
+	if vs&versymHidden == 0 && v.isDefined(vs) {
+		return shown + "@@" + v.Names[vs]
+	}
+	return shown + "@" + v.Names[vs&^versymHidden]
+}
+
+// splitVersion cuts a version name such as GLIBC_2.34 or GLIBCXX_3.4.29 into
+// its library and number. Names without a dotted number after their last
+// underscore, GLIBC_PRIVATE among them, come back whole with no number.
+func splitVersion(name string) (string, string) {
+	i := strings.LastIndexByte(name, '_')
+	if i < 0 || i+1 == len(name) || name[i+1] < '0' || name[i+1] > '9' {
+		return name, ""
+	}
+	return name[:i], name[i+1:]
+}
+
+/* compares two dotted version numbers field by field, 2.9 before 2.34 */
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,628 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+)
+
+// A small decoder for the .xz container with the LZMA2 filter, enough for
+// the MiniDebugInfo payload of .gnu_debugdata. Other filters are rejected.
+
+var xzMagic = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+
+const (
+	xzCheckNone   = 0x00
+	xzCheckCRC32  = 0x01
+	xzCheckCRC64  = 0x04
+	xzCheckSHA256 = 0x0a
+
+	xzFilterLZMA2 = 0x21
+)
+
+var errXzCorrupt = errors.New("xz: corrupt data")
+
+func xzCheckSize(t byte) int {
+	if t == 0 {
+		return 0
+	}
+	return 4 << ((t - 1) / 3)
+}
+
+/* xz multibyte integers, 7 bits per byte, at most 9 bytes */
+func xzVarint(data []byte, pos *int) (uint64, error) {
+	var v uint64
+	for i := 0; i < 9; i++ {
+		if *pos >= len(data) {
+			return 0, errXzCorrupt
+		}
+		c := data[*pos]
+		*pos++
+		v |= uint64(c&0x7f) << (7 * uint(i))
+		if c&0x80 == 0 {
+			return v, nil
+		}
+	}
+	return 0, errXzCorrupt
+}
+
+/* decompresses a complete .xz file held in memory */
+func xzDecompress(data []byte) ([]byte, error) {
+	if len(data) < 24 || !bytes.Equal(data[:6], xzMagic) {
+		return nil, errors.New("xz: bad stream header magic")
+	}
+	flags := data[6:8]
+	if flags[0] != 0 || flags[1]&0xf0 != 0 {
+		return nil, errors.New("xz: unsupported stream flags")
+	}
+	if crc32.ChecksumIEEE(flags) != binary.LittleEndian.Uint32(data[8:12]) {
+		return nil, errors.New("xz: stream header CRC mismatch")
+	}
+	checkType := flags[1]
+
+	var out []byte
+	pos := 12
+	for {
+		if pos >= len(data) {
+			return nil, errXzCorrupt
+		}
+		if data[pos] == 0 {
+			/* index indicator, every block has been decoded */
+			return out, nil
+		}
+
+		blockStart := pos
+		hdrSize := (int(data[pos]) + 1) * 4
+		if pos+hdrSize > len(data) {
+			return nil, errXzCorrupt
+		}
+		hdr := data[pos : pos+hdrSize]
+		if crc32.ChecksumIEEE(hdr[:hdrSize-4]) != binary.LittleEndian.Uint32(hdr[hdrSize-4:]) {
+			return nil, errors.New("xz: block header CRC mismatch")
+		}
+
+		bflags := hdr[1]
+		hp := 2
+		var compSize, uncompSize uint64
+		var err error
+		if bflags&0x40 != 0 {
+			if compSize, err = xzVarint(hdr, &hp); err != nil {
+				return nil, err
+			}
+		}
+		if bflags&0x80 != 0 {
+			if uncompSize, err = xzVarint(hdr, &hp); err != nil {
+				return nil, err
+			}
+		}
+
+		var dictProp byte
+		numFilters := int(bflags&0x03) + 1
+		for i := 0; i < numFilters; i++ {
+			id, err := xzVarint(hdr, &hp)
+			if err != nil {
+				return nil, err
+			}
+			propSize, err := xzVarint(hdr, &hp)
+			if err != nil || hp+int(propSize) > hdrSize-4 {
+				return nil, errXzCorrupt
+			}
+			if id != xzFilterLZMA2 || i != numFilters-1 || propSize != 1 {
+				return nil, fmt.Errorf("xz: unsupported filter 0x%x", id)
+			}
+			dictProp = hdr[hp]
+			hp += int(propSize)
+		}
+		if dictProp > 40 {
+			return nil, errors.New("xz: invalid LZMA2 dictionary size")
+		}
+		pos += hdrSize
+
+		blockOut, n, err := lzma2Decode(data[pos:])
+		if err != nil {
+			return nil, err
+		}
+		if compSize != 0 && uint64(n) != compSize || uncompSize != 0 && uint64(len(blockOut)) != uncompSize {
+			return nil, errors.New("xz: block size mismatch")
+		}
+		pos += n
+
+		/* block padding up to a multiple of four */
+		for (pos-blockStart)%4 != 0 {
+			if pos >= len(data) || data[pos] != 0 {
+				return nil, errXzCorrupt
+			}
+			pos++
+		}
+
+		cs := xzCheckSize(checkType)
+		if pos+cs > len(data) {
+			return nil, errXzCorrupt
+		}
+		if err := xzVerify(checkType, blockOut, data[pos:pos+cs]); err != nil {
+			return nil, err
+		}
+		pos += cs
+		out = append(out, blockOut...)
+	}
+}
+
+func xzVerify(checkType byte, data, sum []byte) error {
+	var h hash.Hash
+	switch checkType {
+	case xzCheckNone:
+		return nil
+	case xzCheckCRC32:
+		h = crc32.NewIEEE()
+	case xzCheckCRC64:
+		h = crc64.New(crc64.MakeTable(crc64.ECMA))
+	case xzCheckSHA256:
+		h = sha256.New()
+	default:
+		/* unknown check types may be skipped per the specification */
+		return nil
+	}
+	h.Write(data)
+	got := h.Sum(nil)
+	if checkType == xzCheckCRC32 || checkType == xzCheckCRC64 {
+		/* CRCs are stored little endian, hash.Hash sums are big endian */
+		for i, j := 0, len(got)-1; i < j; i, j = i+1, j-1 {
+			got[i], got[j] = got[j], got[i]
+		}
+	}
+	if !bytes.Equal(got, sum) {
+		return errors.New("xz: block check mismatch")
+	}
+	return nil
+}
+
+/* decodes LZMA2 chunks until the end marker, returning the output and the bytes consumed */
+func lzma2Decode(data []byte) ([]byte, int, error) {
+	var d lzmaDecoder
+	pos := 0
+	needDictReset := true
+	needProps := true
+
+	for {
+		if pos >= len(data) {
+			return nil, 0, errXzCorrupt
+		}
+		control := data[pos]
+		pos++
+
+		if control == 0x00 {
+			return d.out, pos, nil
+		}
+
+		if control == 0x01 || control == 0x02 {
+			/* stored chunk, 0x01 also resets the dictionary */
+			if control == 0x02 && needDictReset {
+				return nil, 0, errXzCorrupt
+			}
+			if control == 0x01 {
+				d.dictStart = len(d.out)
+				needDictReset = false
+			}
+			if pos+2 > len(data) {
+				return nil, 0, errXzCorrupt
+			}
+			size := int(binary.BigEndian.Uint16(data[pos:])) + 1
+			pos += 2
+			if pos+size > len(data) {
+				return nil, 0, errXzCorrupt
+			}
+			d.out = append(d.out, data[pos:pos+size]...)
+			pos += size
+			continue
+		}
+
+		if control < 0x80 {
+			return nil, 0, fmt.Errorf("xz: invalid LZMA2 control byte 0x%x", control)
+		}
+		if pos+4 > len(data) {
+			return nil, 0, errXzCorrupt
+		}
+		unpacked := int(control&0x1f)<<16 + int(binary.BigEndian.Uint16(data[pos:])) + 1
+		packed := int(binary.BigEndian.Uint16(data[pos+2:])) + 1
+		pos += 4
+
+		reset := (control >> 5) & 0x03
+		if reset == 3 {
+			d.dictStart = len(d.out)
+			needDictReset = false
+		} else if needDictReset {
+			return nil, 0, errXzCorrupt
+		}
+		if reset >= 2 {
+			if pos >= len(data) {
+				return nil, 0, errXzCorrupt
+			}
+			if err := d.setProps(data[pos]); err != nil {
+				return nil, 0, err
+			}
+			pos++
+			needProps = false
+		} else if needProps {
+			return nil, 0, errXzCorrupt
+		}
+		if reset >= 1 {
+			d.resetState()
+		}
+
+		if pos+packed > len(data) {
+			return nil, 0, errXzCorrupt
+		}
+		if err := d.decodeChunk(data[pos:pos+packed], unpacked); err != nil {
+			return nil, 0, err
+		}
+		pos += packed
+	}
+}
+
+const (
+	lzmaStates          = 12
+	lzmaPosBitsMax      = 4
+	lzmaLenLowBits      = 3
+	lzmaLenMidBits      = 3
+	lzmaLenHighBits     = 8
+	lzmaLenToPosStates  = 4
+	lzmaStartPosModel   = 4
+	lzmaEndPosModel     = 14
+	lzmaFullDistances   = 1 << (lzmaEndPosModel >> 1)
+	lzmaAlignBits       = 4
+	lzmaMatchLenMin     = 2
+	lzmaProbInit        = 1 << 10
+	lzmaLiteralCoderLen = 0x300
+)
+
+type lzmaRangeDecoder struct {
+	data []byte
+	pos  int
+	rng  uint32
+	code uint32
+	err  error
+}
+
+func (rc *lzmaRangeDecoder) init(data []byte) error {
+	if len(data) < 5 || data[0] != 0 {
+		return errXzCorrupt
+	}
+	rc.data = data
+	rc.code = binary.BigEndian.Uint32(data[1:5])
+	rc.rng = 0xffffffff
+	rc.pos = 5
+	return nil
+}
+
+func (rc *lzmaRangeDecoder) normalize() {
+	if rc.rng < 1<<24 {
+		if rc.pos >= len(rc.data) {
+			rc.err = errXzCorrupt
+			return
+		}
+		rc.rng <<= 8
+		rc.code = rc.code<<8 | uint32(rc.data[rc.pos])
+		rc.pos++
+	}
+}
+
+func (rc *lzmaRangeDecoder) bit(p *uint16) uint32 {
+	bound := (rc.rng >> 11) * uint32(*p)
+	var b uint32
+	if rc.code < bound {
+		rc.rng = bound
+		*p += (1<<11 - *p) >> 5
+	} else {
+		rc.rng -= bound
+		rc.code -= bound
+		*p -= *p >> 5
+		b = 1
+	}
+	rc.normalize()
+	return b
+}
+
+func (rc *lzmaRangeDecoder) bittree(probs []uint16, bits uint) uint32 {
+	m := uint32(1)
+	for i := uint(0); i < bits; i++ {
+		m = m<<1 | rc.bit(&probs[m])
+	}
+	return m - 1<<bits
+}
+
+func (rc *lzmaRangeDecoder) bittreeReverse(probs []uint16, bits uint) uint32 {
+	m := uint32(1)
+	var sym uint32
+	for i := uint(0); i < bits; i++ {
+		b := rc.bit(&probs[m])
+		m = m<<1 | b
+		sym |= b << i
+	}
+	return sym
+}
+
+func (rc *lzmaRangeDecoder) direct(bits uint) uint32 {
+	var v uint32
+	for i := uint(0); i < bits; i++ {
+		rc.rng >>= 1
+		var b uint32
+		if rc.code >= rc.rng {
+			rc.code -= rc.rng
+			b = 1
+		}
+		v = v<<1 | b
+		rc.normalize()
+	}
+	return v
+}
+
+type lzmaLenDecoder struct {
+	choice  uint16
+	choice2 uint16
+	low     [1 << lzmaPosBitsMax][1 << lzmaLenLowBits]uint16
+	mid     [1 << lzmaPosBitsMax][1 << lzmaLenMidBits]uint16
+	high    [1 << lzmaLenHighBits]uint16
+}
+
+func (l *lzmaLenDecoder) reset() {
+	l.choice, l.choice2 = lzmaProbInit, lzmaProbInit
+	for i := range l.low {
+		for j := range l.low[i] {
+			l.low[i][j] = lzmaProbInit
+		}
+		for j := range l.mid[i] {
+			l.mid[i][j] = lzmaProbInit
+		}
+	}
+	for i := range l.high {
+		l.high[i] = lzmaProbInit
+	}
+}
+
+func (l *lzmaLenDecoder) decode(rc *lzmaRangeDecoder, posState uint32) uint32 {
+	if rc.bit(&l.choice) == 0 {
+		return rc.bittree(l.low[posState][:], lzmaLenLowBits)
+	}
+	if rc.bit(&l.choice2) == 0 {
+		return 1<<lzmaLenLowBits + rc.bittree(l.mid[posState][:], lzmaLenMidBits)
+	}
+	return 1<<lzmaLenLowBits + 1<<lzmaLenMidBits + rc.bittree(l.high[:], lzmaLenHighBits)
+}
+
+type lzmaDecoder struct {
+	out       []byte
+	dictStart int // matches may not reach behind a dictionary reset
+	lc, lp    uint
+	pb        uint
+
+	state      uint32
+	rep        [4]uint32
+	pendingLen int // match left unfinished at the end of the previous chunk
+
+	literal    []uint16
+	isMatch    [lzmaStates << lzmaPosBitsMax]uint16
+	isRep      [lzmaStates]uint16
+	isRep0     [lzmaStates]uint16
+	isRep1     [lzmaStates]uint16
+	isRep2     [lzmaStates]uint16
+	isRep0Long [lzmaStates << lzmaPosBitsMax]uint16
+	posSlot    [lzmaLenToPosStates][1 << 6]uint16
+	posSpecial [lzmaFullDistances - lzmaEndPosModel]uint16
+	align      [1 << lzmaAlignBits]uint16
+	matchLen   lzmaLenDecoder
+	repLen     lzmaLenDecoder
+}
+
+func (d *lzmaDecoder) setProps(props byte) error {
+	if props >= 9*5*5 {
+		return errors.New("xz: invalid LZMA properties")
+	}
+	d.lc = uint(props % 9)
+	props /= 9
+	d.lp = uint(props % 5)
+	d.pb = uint(props / 5)
+	if d.lc+d.lp > 4 {
+		return errors.New("xz: invalid LZMA2 lc/lp")
+	}
+	d.literal = make([]uint16, lzmaLiteralCoderLen<<(d.lc+d.lp))
+	return nil
+}
+
+func (d *lzmaDecoder) resetState() {
+	d.state = 0
+	d.rep = [4]uint32{}
+	d.pendingLen = 0
+	for i := range d.literal {
+		d.literal[i] = lzmaProbInit
+	}
+	fill := func(p []uint16) {
+		for i := range p {
+			p[i] = lzmaProbInit
+		}
+	}
+	fill(d.isMatch[:])
+	fill(d.isRep[:])
+	fill(d.isRep0[:])
+	fill(d.isRep1[:])
+	fill(d.isRep2[:])
+	fill(d.isRep0Long[:])
+	for i := range d.posSlot {
+		fill(d.posSlot[i][:])
+	}
+	fill(d.posSpecial[:])
+	fill(d.align[:])
+	d.matchLen.reset()
+	d.repLen.reset()
+}
+
+/* copies length bytes from distance rep0+1 back, stopping at the chunk limit */
+func (d *lzmaDecoder) repeat(length, limit int) error {
+	dist := int(d.rep[0]) + 1
+	if dist > len(d.out)-d.dictStart {
+		return errXzCorrupt
+	}
+	n := length
+	if room := limit - len(d.out); n > room {
+		n = room
+	}
+	src := len(d.out) - dist
+	for i := 0; i < n; i++ {
+		d.out = append(d.out, d.out[src+i])
+	}
+	d.pendingLen = length - n
+	return nil
+}
+
+func (d *lzmaDecoder) decodeChunk(data []byte, unpacked int) error {
+	var rc lzmaRangeDecoder
+	if err := rc.init(data); err != nil {
+		return err
+	}
+	limit := len(d.out) + unpacked
+
+	if d.pendingLen > 0 {
+		if err := d.repeat(d.pendingLen, limit); err != nil {
+			return err
+		}
+	}
+
+	for len(d.out) < limit {
+		if rc.err != nil {
+			return rc.err
+		}
+		/* position bits count from the last dictionary reset */
+		pos := uint32(len(d.out) - d.dictStart)
+		posState := pos & (1<<d.pb - 1)
+
+		if rc.bit(&d.isMatch[d.state<<lzmaPosBitsMax+posState]) == 0 {
+			var prev byte
+			if len(d.out) > d.dictStart {
+				prev = d.out[len(d.out)-1]
+			}
+			litState := (pos&(1<<d.lp-1))<<d.lc + uint32(prev)>>(8-d.lc)
+			probs := d.literal[lzmaLiteralCoderLen*litState:]
+
+			sym := uint32(1)
+			if d.state >= 7 {
+				dist := int(d.rep[0]) + 1
+				if dist > len(d.out)-d.dictStart {
+					return errXzCorrupt
+				}
+				matchByte := uint32(d.out[len(d.out)-dist])
+				for sym < 0x100 {
+					matchBit := (matchByte >> 7) & 1
+					matchByte <<= 1
+					b := rc.bit(&probs[(1+matchBit)<<8+sym])
+					sym = sym<<1 | b
+					if matchBit != b {
+						break
+					}
+				}
+			}
+			for sym < 0x100 {
+				sym = sym<<1 | rc.bit(&probs[sym])
+			}
+			d.out = append(d.out, byte(sym))
+
+			switch {
+			case d.state < 4:
+				d.state = 0
+			case d.state < 10:
+				d.state -= 3
+			default:
+				d.state -= 6
+			}
+			continue
+		}
+
+		var length uint32
+		if rc.bit(&d.isRep[d.state]) == 0 {
+			/* new match, the distance follows the length */
+			d.rep[3], d.rep[2], d.rep[1] = d.rep[2], d.rep[1], d.rep[0]
+			length = d.matchLen.decode(&rc, posState)
+			if d.state < 7 {
+				d.state = 7
+			} else {
+				d.state = 10
+			}
+
+			lenState := length
+			if lenState > lzmaLenToPosStates-1 {
+				lenState = lzmaLenToPosStates - 1
+			}
+			slot := rc.bittree(d.posSlot[lenState][:], 6)
+			if slot < lzmaStartPosModel {
+				d.rep[0] = slot
+			} else {
+				direct := uint(slot>>1) - 1
+				dist := (2 | slot&1) << direct
+				if slot < lzmaEndPosModel {
+					base := int(dist) - int(slot) - 1
+					m := 1
+					for i := uint(0); i < direct; i++ {
+						b := rc.bit(&d.posSpecial[base+m])
+						m = m<<1 | int(b)
+						dist += b << i
+					}
+				} else {
+					dist += rc.direct(direct-lzmaAlignBits) << lzmaAlignBits
+					dist += rc.bittreeReverse(d.align[:], lzmaAlignBits)
+				}
+				d.rep[0] = dist
+			}
+			if d.rep[0] == 0xffffffff {
+				/* end of payload marker */
+				break
+			}
+		} else {
+			if rc.bit(&d.isRep0[d.state]) == 0 {
+				if rc.bit(&d.isRep0Long[d.state<<lzmaPosBitsMax+posState]) == 0 {
+					/* short rep, a single byte at rep0 */
+					if d.state < 7 {
+						d.state = 9
+					} else {
+						d.state = 11
+					}
+					if err := d.repeat(1, limit); err != nil {
+						return err
+					}
+					continue
+				}
+			} else {
+				var dist uint32
+				if rc.bit(&d.isRep1[d.state]) == 0 {
+					dist = d.rep[1]
+				} else {
+					if rc.bit(&d.isRep2[d.state]) == 0 {
+						dist = d.rep[2]
+					} else {
+						dist = d.rep[3]
+						d.rep[3] = d.rep[2]
+					}
+					d.rep[2] = d.rep[1]
+				}
+				d.rep[1] = d.rep[0]
+				d.rep[0] = dist
+			}
+			length = d.repLen.decode(&rc, posState)
+			if d.state < 7 {
+				d.state = 8
+			} else {
+				d.state = 11
+			}
+		}
+
+		if err := d.repeat(int(length)+lzmaMatchLenMin, limit); err != nil {
+			return err
+		}
+	}
+	if rc.err != nil {
+		return rc.err
+	}
+	return nil
+}
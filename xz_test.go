@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+/* the plain bytes the fixtures in testdata were compressed from */
+func fixture(t *testing.T, files ...string) []byte {
+	var out []byte
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, data...)
+	}
+	return out
+}
+
+func TestXzDecompress(t *testing.T) {
+	tests := []struct {
+		file  string
+		plain []string
+	}{
+		{"testdata/copying.xz", []string{"COPYING"}},                      // CRC32 check
+		{"testdata/mixed.xz", []string{"COPYING", "testdata/random.bin"}}, // SHA-256 check, an incompressible tail
+	}
+	for _, tt := range tests {
+		got, err := xzDecompress(fixture(t, tt.file))
+		if err != nil {
+			t.Errorf("%s: %v", tt.file, err)
+			continue
+		}
+		if want := fixture(t, tt.plain...); !bytes.Equal(got, want) {
+			t.Errorf("%s: got %d bytes, want %d bytes of %v", tt.file, len(got), len(want), tt.plain)
+		}
+	}
+}
+
+func TestXzCorrupt(t *testing.T) {
+	data := fixture(t, "testdata/copying.xz")
+	data[len(data)/2] ^= 0x55
+	if _, err := xzDecompress(data); err == nil {
+		t.Error("corrupt stream decoded without an error")
+	}
+}
@@ -0,0 +1,829 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// A decoder for Zstandard frames (RFC 8878) as produced by
+// --compress-debug-sections=zstd. Dictionaries are not supported, ELF
+// section payloads never reference one.
+
+const (
+	zstdMagic         = 0xfd2fb528
+	zstdSkippableMask = 0xfffffff0
+	zstdSkippableBase = 0x184d2a50
+
+	zstdBlockRaw        = 0
+	zstdBlockRLE        = 1
+	zstdBlockCompressed = 2
+
+	zstdLitRaw        = 0
+	zstdLitRLE        = 1
+	zstdLitCompressed = 2
+	zstdLitTreeless   = 3
+
+	zstdModePredefined = 0
+	zstdModeRLE        = 1
+	zstdModeFSE        = 2
+	zstdModeRepeat     = 3
+
+	zstdHuffMaxBits = 11
+)
+
+var errZstdCorrupt = errors.New("zstd: corrupt data")
+
+/* default distributions used by the predefined sequence compression mode */
+var (
+	zstdLLDefault = []int16{4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1, 2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1, -1, -1, -1, -1}
+	zstdMLDefault = []int16{1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1, -1, -1}
+	zstdOFDefault = []int16{1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1}
+)
+
+var zstdLLBase = [36]uint32{
+	0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+	16, 18, 20, 22, 24, 28, 32, 40, 48, 64, 128, 256, 512, 1024, 2048, 4096,
+	8192, 16384, 32768, 65536,
+}
+
+var zstdLLBits = [36]uint8{
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	1, 1, 1, 1, 2, 2, 3, 3, 4, 6, 7, 8, 9, 10, 11, 12,
+	13, 14, 15, 16,
+}
+
+var zstdMLBase = [53]uint32{
+	3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18,
+	19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34,
+	35, 37, 39, 41, 43, 47, 51, 59, 67, 83, 99, 131, 259, 515, 1027, 2051,
+	4099, 8195, 16387, 32771, 65539,
+}
+
+var zstdMLBits = [53]uint8{
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	1, 1, 1, 1, 2, 2, 3, 3, 4, 4, 5, 7, 8, 9, 10, 11,
+	12, 13, 14, 15, 16,
+}
+
+/* n bits starting at bit off of data read as a little endian number, bits outside data read as zero */
+func zstdBits(data []byte, off, n int) uint64 {
+	if n == 0 {
+		return 0
+	}
+	if off < 0 {
+		if off+n <= 0 {
+			return 0
+		}
+		return zstdBits(data, 0, n+off) << uint(-off)
+	}
+
+	b := off >> 3
+	var v uint64
+	if b+8 <= len(data) {
+		v = binary.LittleEndian.Uint64(data[b:])
+	} else {
+		for i := 7; i >= 0; i-- {
+			v <<= 8
+			if b+i < len(data) {
+				v |= uint64(data[b+i])
+			}
+		}
+	}
+	return (v >> uint(off&7)) & (1<<uint(n) - 1)
+}
+
+/* Huffman and FSE streams are read backwards, starting below the highest set bit of the last byte */
+type zstdBackReader struct {
+	data []byte
+	pos  int // bits [0, pos) are still unread, negative once the stream is overconsumed
+}
+
+func newZstdBackReader(data []byte) (*zstdBackReader, error) {
+	if len(data) == 0 || data[len(data)-1] == 0 {
+		return nil, errZstdCorrupt
+	}
+	return &zstdBackReader{data: data, pos: (len(data)-1)*8 + bits.Len8(data[len(data)-1]) - 1}, nil
+}
+
+func (r *zstdBackReader) read(n uint) uint64 {
+	r.pos -= int(n)
+	return zstdBits(r.data, r.pos, int(n))
+}
+
+func (r *zstdBackReader) peek(n uint) uint64 {
+	return zstdBits(r.data, r.pos-int(n), int(n))
+}
+
+type fseTable struct {
+	log    uint
+	symbol []uint8
+	nbBits []uint8
+	base   []uint16
+}
+
+/* spreads a normalized distribution over the decoding table */
+func buildFSETable(norm []int16, log uint) (*fseTable, error) {
+	size := 1 << log
+	t := &fseTable{
+		log:    log,
+		symbol: make([]uint8, size),
+		nbBits: make([]uint8, size),
+		base:   make([]uint16, size),
+	}
+
+	next := make([]int, len(norm))
+	high := size - 1
+	for s, n := range norm {
+		if n == -1 {
+			/* "less than one" probabilities take a single cell at the top */
+			t.symbol[high] = uint8(s)
+			high--
+			next[s] = 1
+		}
+	}
+
+	pos := 0
+	step := size>>1 + size>>3 + 3
+	for s, n := range norm {
+		if n <= 0 {
+			continue
+		}
+		next[s] = int(n)
+		for i := 0; i < int(n); i++ {
+			t.symbol[pos] = uint8(s)
+			for pos = (pos + step) & (size - 1); pos > high; pos = (pos + step) & (size - 1) {
+			}
+		}
+	}
+	if pos != 0 {
+		return nil, errZstdCorrupt
+	}
+
+	for i := 0; i < size; i++ {
+		s := t.symbol[i]
+		state := next[s]
+		next[s]++
+		nb := log - uint(bits.Len(uint(state))-1)
+		t.nbBits[i] = uint8(nb)
+		t.base[i] = uint16(state<<nb - size)
+	}
+	return t, nil
+}
+
+/* FSE table description, returns the table and the number of bytes it occupied */
+func readFSETable(data []byte, maxLog uint, maxSym int) (*fseTable, int, error) {
+	if len(data) == 0 {
+		return nil, 0, errZstdCorrupt
+	}
+	log := uint(zstdBits(data, 0, 4)) + 5
+	if log > maxLog {
+		return nil, 0, errZstdCorrupt
+	}
+	off := 4
+
+	var norm []int16
+	remaining := 1 << log
+	for remaining > 0 && len(norm) <= maxSym {
+		nb := bits.Len(uint(remaining + 1))
+		val := int(zstdBits(data, off, nb))
+		off += nb
+
+		lowMask := 1<<uint(nb-1) - 1
+		threshold := 1<<uint(nb) - 1 - (remaining + 1)
+		if val&lowMask < threshold {
+			off--
+			val &= lowMask
+		} else if val > lowMask {
+			val -= threshold
+		}
+
+		prob := int16(val - 1)
+		if prob < 0 {
+			remaining += int(prob)
+		} else {
+			remaining -= int(prob)
+		}
+		norm = append(norm, prob)
+
+		if prob == 0 {
+			for {
+				repeat := int(zstdBits(data, off, 2))
+				off += 2
+				for i := 0; i < repeat; i++ {
+					norm = append(norm, 0)
+				}
+				if repeat != 3 {
+					break
+				}
+			}
+		}
+	}
+
+	used := (off + 7) / 8
+	if remaining != 0 || len(norm) > maxSym+1 || used > len(data) {
+		return nil, 0, errZstdCorrupt
+	}
+	t, err := buildFSETable(norm, log)
+	return t, used, err
+}
+
+/* RLE mode: every state decodes the same symbol without reading bits */
+func rleFSETable(sym byte) *fseTable {
+	return &fseTable{symbol: []uint8{sym}, nbBits: []uint8{0}, base: []uint16{0}}
+}
+
+type fseState struct {
+	t     *fseTable
+	state uint32
+}
+
+func (s *fseState) init(t *fseTable, r *zstdBackReader) {
+	s.t = t
+	s.state = uint32(r.read(t.log))
+}
+
+func (s *fseState) symbol() uint8 {
+	return s.t.symbol[s.state]
+}
+
+func (s *fseState) update(r *zstdBackReader) {
+	s.state = uint32(s.t.base[s.state]) + uint32(r.read(uint(s.t.nbBits[s.state])))
+}
+
+type huffTable struct {
+	maxBits uint
+	symbol  []uint8
+	nbBits  []uint8
+}
+
+/* Huffman tree description, returns the table and the number of bytes it occupied */
+func readHuffTable(data []byte) (*huffTable, int, error) {
+	if len(data) == 0 {
+		return nil, 0, errZstdCorrupt
+	}
+	var weights []uint8
+	hdr := int(data[0])
+	used := 1
+
+	if hdr >= 128 {
+		/* weights stored directly, two per byte */
+		n := hdr - 127
+		used += (n + 1) / 2
+		if used > len(data) {
+			return nil, 0, errZstdCorrupt
+		}
+		for i := 0; i < n; i++ {
+			b := data[1+i/2]
+			if i%2 == 0 {
+				weights = append(weights, b>>4)
+			} else {
+				weights = append(weights, b&0x0f)
+			}
+		}
+	} else {
+		/* weights compressed with FSE, two interleaved states */
+		used += hdr
+		if used > len(data) {
+			return nil, 0, errZstdCorrupt
+		}
+		t, n, err := readFSETable(data[1:used], 6, 255)
+		if err != nil {
+			return nil, 0, err
+		}
+		r, err := newZstdBackReader(data[1+n : used])
+		if err != nil {
+			return nil, 0, err
+		}
+		var s1, s2 fseState
+		s1.init(t, r)
+		s2.init(t, r)
+		for len(weights) < 255 {
+			weights = append(weights, s1.symbol())
+			s1.update(r)
+			if r.pos < 0 {
+				weights = append(weights, s2.symbol())
+				break
+			}
+			weights = append(weights, s2.symbol())
+			s2.update(r)
+			if r.pos < 0 {
+				weights = append(weights, s1.symbol())
+				break
+			}
+		}
+	}
+
+	/* the last symbol's weight is implied by completing the total to a power of two */
+	total := 0
+	for _, w := range weights {
+		if w > zstdHuffMaxBits {
+			return nil, 0, errZstdCorrupt
+		}
+		if w > 0 {
+			total += 1 << (w - 1)
+		}
+	}
+	if total == 0 {
+		return nil, 0, errZstdCorrupt
+	}
+	maxBits := uint(bits.Len(uint(total)))
+	rest := 1<<maxBits - total
+	if maxBits > zstdHuffMaxBits || rest&(rest-1) != 0 {
+		return nil, 0, errZstdCorrupt
+	}
+	weights = append(weights, uint8(bits.Len(uint(rest))))
+
+	/* lower weights take the lower codes, symbols ascending within a weight */
+	t := &huffTable{
+		maxBits: maxBits,
+		symbol:  make([]uint8, 1<<maxBits),
+		nbBits:  make([]uint8, 1<<maxBits),
+	}
+	pos := 0
+	for w := uint8(1); w <= uint8(maxBits); w++ {
+		for s, sw := range weights {
+			if sw != w {
+				continue
+			}
+			n := 1 << (w - 1)
+			for i := 0; i < n; i++ {
+				t.symbol[pos+i] = uint8(s)
+				t.nbBits[pos+i] = uint8(maxBits + 1 - uint(w))
+			}
+			pos += n
+		}
+	}
+	return t, used, nil
+}
+
+func (t *huffTable) decodeStream(data []byte, out []byte) error {
+	r, err := newZstdBackReader(data)
+	if err != nil {
+		return err
+	}
+	for i := range out {
+		v := r.peek(t.maxBits)
+		out[i] = t.symbol[v]
+		r.pos -= int(t.nbBits[v])
+	}
+	if r.pos != 0 {
+		return errZstdCorrupt
+	}
+	return nil
+}
+
+type zstdDecoder struct {
+	out        []byte
+	frameStart int
+	rep        [3]int
+	huff       *huffTable
+	ll, of, ml *fseTable
+}
+
+/* decompresses every frame of data, skippable frames are ignored */
+func zstdDecompress(data []byte) ([]byte, error) {
+	var d zstdDecoder
+	pos := 0
+	for pos < len(data) {
+		if pos+4 > len(data) {
+			return nil, errZstdCorrupt
+		}
+		magic := binary.LittleEndian.Uint32(data[pos:])
+		if magic&zstdSkippableMask == zstdSkippableBase {
+			if pos+8 > len(data) {
+				return nil, errZstdCorrupt
+			}
+			pos += 8 + int(binary.LittleEndian.Uint32(data[pos+4:]))
+			continue
+		}
+		if magic != zstdMagic {
+			return nil, errors.New("zstd: bad frame magic")
+		}
+		n, err := d.decodeFrame(data[pos+4:])
+		if err != nil {
+			return nil, err
+		}
+		pos += 4 + n
+	}
+	return d.out, nil
+}
+
+func (d *zstdDecoder) decodeFrame(data []byte) (int, error) {
+	if len(data) < 1 {
+		return 0, errZstdCorrupt
+	}
+	desc := data[0]
+	pos := 1
+	fcsFlag := desc >> 6
+	single := desc&0x20 != 0
+	checksum := desc&0x04 != 0
+	if desc&0x08 != 0 {
+		return 0, errors.New("zstd: reserved frame header bit set")
+	}
+	if !single {
+		pos++ // window descriptor
+	}
+	dictIDSize := [4]int{0, 1, 2, 4}[desc&0x03]
+	if pos+dictIDSize > len(data) {
+		return 0, errZstdCorrupt
+	}
+	var dictID uint32
+	for i := 0; i < dictIDSize; i++ {
+		dictID |= uint32(data[pos+i]) << (8 * uint(i))
+	}
+	if dictID != 0 {
+		return 0, fmt.Errorf("zstd: frame needs dictionary %d", dictID)
+	}
+	pos += dictIDSize
+
+	fcsSize := [4]int{0, 2, 4, 8}[fcsFlag]
+	if fcsFlag == 0 && single {
+		fcsSize = 1
+	}
+	pos += fcsSize
+	if pos > len(data) {
+		return 0, errZstdCorrupt
+	}
+
+	d.frameStart = len(d.out)
+	d.rep = [3]int{1, 4, 8}
+	d.huff, d.ll, d.of, d.ml = nil, nil, nil, nil
+
+	for {
+		if pos+3 > len(data) {
+			return 0, errZstdCorrupt
+		}
+		hdr := uint32(data[pos]) | uint32(data[pos+1])<<8 | uint32(data[pos+2])<<16
+		pos += 3
+		last := hdr&1 != 0
+		size := int(hdr >> 3)
+
+		switch (hdr >> 1) & 3 {
+		case zstdBlockRaw:
+			if pos+size > len(data) {
+				return 0, errZstdCorrupt
+			}
+			d.out = append(d.out, data[pos:pos+size]...)
+			pos += size
+		case zstdBlockRLE:
+			if pos >= len(data) {
+				return 0, errZstdCorrupt
+			}
+			for i := 0; i < size; i++ {
+				d.out = append(d.out, data[pos])
+			}
+			pos++
+		case zstdBlockCompressed:
+			if pos+size > len(data) {
+				return 0, errZstdCorrupt
+			}
+			if err := d.decodeBlock(data[pos : pos+size]); err != nil {
+				return 0, err
+			}
+			pos += size
+		default:
+			return 0, errors.New("zstd: reserved block type")
+		}
+		if last {
+			break
+		}
+	}
+
+	if checksum {
+		if pos+4 > len(data) {
+			return 0, errZstdCorrupt
+		}
+		if uint32(xxhash64(d.out[d.frameStart:])) != binary.LittleEndian.Uint32(data[pos:]) {
+			return 0, errors.New("zstd: content checksum mismatch")
+		}
+		pos += 4
+	}
+	return pos, nil
+}
+
+func (d *zstdDecoder) decodeBlock(block []byte) error {
+	lits, n, err := d.decodeLiterals(block)
+	if err != nil {
+		return err
+	}
+	return d.decodeSequences(block[n:], lits)
+}
+
+/* literals section, returns the regenerated literals and the section size */
+func (d *zstdDecoder) decodeLiterals(block []byte) ([]byte, int, error) {
+	if len(block) == 0 {
+		return nil, 0, errZstdCorrupt
+	}
+	litType := block[0] & 3
+	sizeFormat := (block[0] >> 2) & 3
+
+	if litType == zstdLitRaw || litType == zstdLitRLE {
+		var regen, hdrSize int
+		switch sizeFormat {
+		case 0, 2:
+			regen, hdrSize = int(block[0]>>3), 1
+		case 1:
+			hdrSize = 2
+		case 3:
+			hdrSize = 3
+		}
+		if hdrSize > len(block) {
+			return nil, 0, errZstdCorrupt
+		}
+		if hdrSize > 1 {
+			regen = int(zstdBits(block, 4, hdrSize*8-4))
+		}
+		if litType == zstdLitRLE {
+			if hdrSize >= len(block) {
+				return nil, 0, errZstdCorrupt
+			}
+			lits := make([]byte, regen)
+			for i := range lits {
+				lits[i] = block[hdrSize]
+			}
+			return lits, hdrSize + 1, nil
+		}
+		if hdrSize+regen > len(block) {
+			return nil, 0, errZstdCorrupt
+		}
+		return block[hdrSize : hdrSize+regen], hdrSize + regen, nil
+	}
+
+	/* Huffman coded literals, 1 or 4 streams */
+	var hdrSize, sizeBits int
+	streams := 4
+	switch sizeFormat {
+	case 0:
+		hdrSize, sizeBits, streams = 3, 10, 1
+	case 1:
+		hdrSize, sizeBits = 3, 10
+	case 2:
+		hdrSize, sizeBits = 4, 14
+	case 3:
+		hdrSize, sizeBits = 5, 18
+	}
+	if hdrSize > len(block) {
+		return nil, 0, errZstdCorrupt
+	}
+	regen := int(zstdBits(block, 4, sizeBits))
+	comp := int(zstdBits(block, 4+sizeBits, sizeBits))
+	if hdrSize+comp > len(block) {
+		return nil, 0, errZstdCorrupt
+	}
+	payload := block[hdrSize : hdrSize+comp]
+
+	if litType == zstdLitCompressed {
+		t, n, err := readHuffTable(payload)
+		if err != nil {
+			return nil, 0, err
+		}
+		d.huff = t
+		payload = payload[n:]
+	} else if d.huff == nil {
+		return nil, 0, errors.New("zstd: treeless literals without a previous table")
+	}
+
+	lits := make([]byte, regen)
+	if streams == 1 {
+		if err := d.huff.decodeStream(payload, lits); err != nil {
+			return nil, 0, err
+		}
+		return lits, hdrSize + comp, nil
+	}
+
+	if len(payload) < 6 {
+		return nil, 0, errZstdCorrupt
+	}
+	var sizes [4]int
+	total := 0
+	for i := 0; i < 3; i++ {
+		sizes[i] = int(binary.LittleEndian.Uint16(payload[2*i:]))
+		total += sizes[i]
+	}
+	sizes[3] = len(payload) - 6 - total
+	if sizes[3] < 0 {
+		return nil, 0, errZstdCorrupt
+	}
+	per := (regen + 3) / 4
+	if 3*per > regen {
+		return nil, 0, errZstdCorrupt
+	}
+	in := payload[6:]
+	out := 0
+	for i := 0; i < 4; i++ {
+		n := per
+		if i == 3 {
+			n = regen - 3*per
+		}
+		if err := d.huff.decodeStream(in[:sizes[i]], lits[out:out+n]); err != nil {
+			return nil, 0, err
+		}
+		in = in[sizes[i]:]
+		out += n
+	}
+	return lits, hdrSize + comp, nil
+}
+
+/* picks the table for one of the sequence symbol types according to its compression mode */
+func (d *zstdDecoder) sequenceTable(mode byte, prev *fseTable, def []int16, defLog, maxLog uint, maxSym int, data []byte) (*fseTable, int, error) {
+	switch mode {
+	case zstdModePredefined:
+		t, err := buildFSETable(def, defLog)
+		return t, 0, err
+	case zstdModeRLE:
+		if len(data) == 0 {
+			return nil, 0, errZstdCorrupt
+		}
+		return rleFSETable(data[0]), 1, nil
+	case zstdModeFSE:
+		return readFSETable(data, maxLog, maxSym)
+	default:
+		if prev == nil {
+			return nil, 0, errors.New("zstd: repeated sequence table without a previous one")
+		}
+		return prev, 0, nil
+	}
+}
+
+func (d *zstdDecoder) decodeSequences(data []byte, lits []byte) error {
+	if len(data) == 0 {
+		return errZstdCorrupt
+	}
+	nbSeq := int(data[0])
+	pos := 1
+	switch {
+	case nbSeq == 0:
+		d.out = append(d.out, lits...)
+		return nil
+	case nbSeq == 255:
+		if pos+2 > len(data) {
+			return errZstdCorrupt
+		}
+		nbSeq = int(binary.LittleEndian.Uint16(data[pos:])) + 0x7f00
+		pos += 2
+	case nbSeq >= 128:
+		if pos >= len(data) {
+			return errZstdCorrupt
+		}
+		nbSeq = (nbSeq-128)<<8 + int(data[pos])
+		pos++
+	}
+
+	if pos >= len(data) {
+		return errZstdCorrupt
+	}
+	modes := data[pos]
+	pos++
+	var err error
+	var n int
+	if d.ll, n, err = d.sequenceTable(modes>>6, d.ll, zstdLLDefault, 6, 9, 35, data[pos:]); err != nil {
+		return err
+	}
+	pos += n
+	if d.of, n, err = d.sequenceTable((modes>>4)&3, d.of, zstdOFDefault, 5, 8, 31, data[pos:]); err != nil {
+		return err
+	}
+	pos += n
+	if d.ml, n, err = d.sequenceTable((modes>>2)&3, d.ml, zstdMLDefault, 6, 9, 52, data[pos:]); err != nil {
+		return err
+	}
+	pos += n
+
+	r, err := newZstdBackReader(data[pos:])
+	if err != nil {
+		return err
+	}
+	var ll, of, ml fseState
+	ll.init(d.ll, r)
+	of.init(d.of, r)
+	ml.init(d.ml, r)
+
+	for i := 0; i < nbSeq; i++ {
+		ofCode, mlCode, llCode := of.symbol(), ml.symbol(), ll.symbol()
+		if ofCode > 31 || int(mlCode) >= len(zstdMLBase) || int(llCode) >= len(zstdLLBase) {
+			return errZstdCorrupt
+		}
+		ofValue := int(1<<ofCode + r.read(uint(ofCode)))
+		matchLen := int(zstdMLBase[mlCode] + uint32(r.read(uint(zstdMLBits[mlCode]))))
+		litLen := int(zstdLLBase[llCode] + uint32(r.read(uint(zstdLLBits[llCode]))))
+
+		offset := d.repeatOffset(ofValue, litLen)
+
+		if litLen > len(lits) {
+			return errZstdCorrupt
+		}
+		d.out = append(d.out, lits[:litLen]...)
+		lits = lits[litLen:]
+
+		if offset <= 0 || offset > len(d.out)-d.frameStart {
+			return errZstdCorrupt
+		}
+		from := len(d.out) - offset
+		for j := 0; j < matchLen; j++ {
+			d.out = append(d.out, d.out[from+j])
+		}
+
+		if i+1 < nbSeq {
+			ll.update(r)
+			ml.update(r)
+			of.update(r)
+		}
+		if r.pos < 0 {
+			return errZstdCorrupt
+		}
+	}
+	if r.pos != 0 {
+		return errZstdCorrupt
+	}
+	d.out = append(d.out, lits...)
+	return nil
+}
+
+/* resolves an offset value against the repeat offset history, updating it */
+func (d *zstdDecoder) repeatOffset(ofValue, litLen int) int {
+	if ofValue > 3 {
+		offset := ofValue - 3
+		d.rep = [3]int{offset, d.rep[0], d.rep[1]}
+		return offset
+	}
+
+	idx := ofValue - 1
+	if litLen == 0 {
+		idx++
+	}
+	switch idx {
+	case 0:
+		return d.rep[0]
+	case 1:
+		d.rep = [3]int{d.rep[1], d.rep[0], d.rep[2]}
+	case 2:
+		d.rep = [3]int{d.rep[2], d.rep[0], d.rep[1]}
+	case 3:
+		d.rep = [3]int{d.rep[0] - 1, d.rep[0], d.rep[1]}
+	}
+	return d.rep[0]
+}
+
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+func xxRound(acc, lane uint64) uint64 {
+	return bits.RotateLeft64(acc+lane*xxPrime2, 31) * xxPrime1
+}
+
+/* XXH64 with seed 0, zstd keeps the low 32 bits as the content checksum */
+func xxhash64(b []byte) uint64 {
+	n := uint64(len(b))
+	var h uint64
+	if len(b) >= 32 {
+		v1 := xxPrime1
+		v1 += xxPrime2
+		v2 := xxPrime2
+		v3 := uint64(0)
+		v4 := uint64(0)
+		v4 -= xxPrime1
+		for ; len(b) >= 32; b = b[32:] {
+			v1 = xxRound(v1, binary.LittleEndian.Uint64(b))
+			v2 = xxRound(v2, binary.LittleEndian.Uint64(b[8:]))
+			v3 = xxRound(v3, binary.LittleEndian.Uint64(b[16:]))
+			v4 = xxRound(v4, binary.LittleEndian.Uint64(b[24:]))
+		}
+		h = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) + bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		for _, v := range []uint64{v1, v2, v3, v4} {
+			h ^= xxRound(0, v)
+			h = h*xxPrime1 + xxPrime4
+		}
+	} else {
+		h = xxPrime5
+	}
+	h += n
+
+	for ; len(b) >= 8; b = b[8:] {
+		h ^= xxRound(0, binary.LittleEndian.Uint64(b))
+		h = bits.RotateLeft64(h, 27)*xxPrime1 + xxPrime4
+	}
+	if len(b) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(b)) * xxPrime1
+		h = bits.RotateLeft64(h, 23)*xxPrime2 + xxPrime3
+		b = b[4:]
+	}
+	for _, c := range b {
+		h ^= uint64(c) * xxPrime5
+		h = bits.RotateLeft64(h, 11) * xxPrime1
+	}
+
+	h ^= h >> 33
+	h *= xxPrime2
+	h ^= h >> 29
+	h *= xxPrime3
+	h ^= h >> 32
+	return h
+}
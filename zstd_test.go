@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestZstdDecompress(t *testing.T) {
+	copying, mixed := fixture(t, "testdata/copying.zst"), fixture(t, "testdata/mixed.zst")
+	skippable := []byte{0x50, 0x2a, 0x4d, 0x18, 3, 0, 0, 0, 'a', 'b', 'c'}
+
+	tests := []struct {
+		name  string
+		data  []byte
+		plain []string
+	}{
+		{"copying.zst", copying, []string{"COPYING"}},                    // level 19, content checksum
+		{"mixed.zst", mixed, []string{"COPYING", "testdata/random.bin"}}, // no checksum, an incompressible tail
+		{"two frames", append(append(append([]byte{}, copying...), skippable...), mixed...),
+			[]string{"COPYING", "COPYING", "testdata/random.bin"}},
+	}
+	for _, tt := range tests {
+		got, err := zstdDecompress(tt.data)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if want := fixture(t, tt.plain...); !bytes.Equal(got, want) {
+			t.Errorf("%s: got %d bytes, want %d bytes of %v", tt.name, len(got), len(want), tt.plain)
+		}
+	}
+}
+
+func TestZstdCorrupt(t *testing.T) {
+	data := fixture(t, "testdata/copying.zst")
+	data[len(data)/2] ^= 0x55
+	if _, err := zstdDecompress(data); err == nil {
+		t.Error("corrupt frame decoded without an error")
+	}
+}